@@ -1,9 +1,6 @@
 package controllers
 
 import (
-	"context"
-	"fmt"
-
 	"github.com/fgrehm/kot"
 	configv1 "github.com/fgrehm/kot/playground/api/v1"
 	corev1 "k8s.io/api/core/v1"
@@ -17,41 +14,15 @@ import (
 //+kubebuilder:rbac:groups=config.playground.kot,resources=orgnamespaces/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=config.playground.kot,resources=orgnamespaces/finalizers,verbs=update
 
-var IndexByImportedSecret = kot.Indexer{
-	GVK:   configv1.GroupVersion.WithKind("OrgNamespace"),
-	Field: ".importedSecret",
-	IndexFn: func(resource kot.Object) []string {
-		imported := []string{}
-		orgNamespace := resource.(*configv1.OrgNamespace)
-		for _, secretRef := range orgNamespace.Spec.ImportSecrets {
-			imported = append(imported, fmt.Sprintf("%s/%s", secretRef.Namespace, secretRef.Name))
-		}
-		return imported
-	},
-}
-
-var secretWatcher = kot.Watch(&kot.ResourceWatcher{
+var secretWatcher = kot.Watch(&kot.ReferenceWatcher{
 	Watches: &corev1.Secret{},
-	When:    kot.ResourceVersionChangedPredicate{},
-	Enqueue: func(ctn kot.Container, obj kot.Object) ([]kot.ReconcileRequest, error) {
-		client := kot.ClientDep(ctn)
-		list := &configv1.OrgNamespaceList{}
-		secret := obj.(*corev1.Secret)
-
-		ref := fmt.Sprintf("%s/%s", secret.Namespace, secret.Name)
-		filter := kot.MatchingFields{".importedSecret": ref}
-
-		ctx := context.Background()
-		if err := client.List(ctx, list, filter); err != nil {
-			return nil, err
-		}
-
-		reqs := make([]kot.ReconcileRequest, len(list.Items))
-		for i, item := range list.Items {
-			reqs[i].Name = item.Name
-			reqs[i].Namespace = item.Namespace
+	References: func(parent kot.Object) []kot.ObjectKey {
+		orgNamespace := parent.(*configv1.OrgNamespace)
+		refs := []kot.ObjectKey{}
+		for _, secretRef := range orgNamespace.Spec.ImportSecrets {
+			refs = append(refs, kot.ObjectKey{Namespace: secretRef.Namespace, Name: secretRef.Name})
 		}
-		return reqs, nil
+		return refs
 	},
 })
 
@@ -67,23 +38,21 @@ var nsReconciler = kot.Reconcile(&kot.One{
 		kot.CopyAnnotations(orgNs, ns)
 	}),
 
-	// Finalizer: kot.WaitForChildrenFinalizer{
-	// 	corev1.SchemeGroupVersion.WithKind("Namespace"),
-	// },
+	// Keep the OrgNamespace around while its namespace is terminating
+	Finalize: &kot.WaitForChildrenFinalizer{
+		GVKs: []kot.GVK{corev1.SchemeGroupVersion.WithKind("Namespace")},
+	},
 })
 
-var limitsReconciler = kot.Reconcile(&kot.One{
-	GVK: corev1.SchemeGroupVersion.WithKind("LimitRange"),
+var limitsReconciler = kot.ReconcileOne(kot.TypedOne[*configv1.OrgNamespace, *corev1.LimitRange]{
+	// The namespace has to exist before anything gets created in it
+	DependsOn: kot.Dependencies{kot.OnGVK(corev1.SchemeGroupVersion.WithKind("Namespace"))},
 
-	If: kot.SimpleIf(func(ctx kot.Context) bool {
-		orgNs := ctx.Resource().(*configv1.OrgNamespace)
-		return orgNs.Spec.DefaultResources != nil
-	}),
-
-	Reconcile: kot.SimpleReconcileOne(func(ctx kot.Context, child kot.Object) {
-		orgNs := ctx.Resource().(*configv1.OrgNamespace)
-		lr := child.(*corev1.LimitRange)
+	If: func(ctx kot.Context, orgNs *configv1.OrgNamespace) (bool, error) {
+		return orgNs.Spec.DefaultResources != nil, nil
+	},
 
+	Reconcile: func(ctx kot.Context, orgNs *configv1.OrgNamespace, lr *corev1.LimitRange) (kot.Result, error) {
 		lr.Name = "container-defaults"
 		lr.Namespace = orgNs.Name
 		lr.Spec.Limits = []corev1.LimitRangeItem{}
@@ -113,12 +82,15 @@ var limitsReconciler = kot.Reconcile(&kot.One{
 			DefaultRequest: defaultRequest,
 			Default:        defaultLimit,
 		}}
-	}),
+		return kot.Result{}, nil
+	},
 })
 
 var secretsReconciler = kot.Reconcile(&kot.List{
 	GVK: corev1.SchemeGroupVersion.WithKind("Secret"),
 
+	DependsOn: kot.Dependencies{kot.OnGVK(corev1.SchemeGroupVersion.WithKind("Namespace"))},
+
 	// If: kot.SimpleIf(func(ctx kot.Context) bool {
 	// 	orgNs := ctx.Resource().(*configv1.OrgNamespace)
 	// 	return orgNs.Spec.DefaultResources != nil
@@ -50,7 +50,7 @@ var _ = Describe("Finalizer behavior", func() {
 				return nil, err
 			}
 			return owner.Finalizers, nil
-		}).Should(ContainElement("kot-fin"))
+		}).Should(ContainElement("kot.io/delay"))
 
 		Eventually(func() error {
 			if err := client.Reload(ctx, owner); err != nil {
@@ -65,7 +65,19 @@ var _ = Describe("Finalizer behavior", func() {
 				return nil, err
 			}
 			return owner.Finalizers, nil
-		}).ShouldNot(ContainElement("kot-fin"))
+		}).ShouldNot(ContainElement("kot.io/delay"))
+	})
+
+	It("replaces the legacy finalizer", func() {
+		owner.Finalizers = []string{"kot-fin"}
+		Expect(client.CreateAndWait(ctx, owner)).To(Succeed())
+
+		Eventually(func() ([]string, error) {
+			if err := client.Reload(ctx, owner); err != nil {
+				return nil, err
+			}
+			return owner.Finalizers, nil
+		}).Should(Equal([]string{"kot.io/delay"}))
 	})
 
 	It("handles finalizer execution", func() {
@@ -76,7 +88,7 @@ var _ = Describe("Finalizer behavior", func() {
 				return nil, err
 			}
 			return owner.Finalizers, nil
-		}).Should(ContainElement("kot-fin"))
+		}).Should(ContainElement("kot.io/delay"))
 
 		Expect(client.Delete(ctx, owner)).To(Succeed())
 
@@ -92,7 +104,7 @@ var _ = Describe("Finalizer behavior", func() {
 				return nil, err
 			}
 			return owner.Finalizers, nil
-		}, "1s").Should(ContainElement("kot-fin"))
+		}, "1s").Should(ContainElement("kot.io/delay"))
 
 		Eventually(func() error {
 			return client.Reload(ctx, owner)
@@ -4,10 +4,12 @@ import (
 	"context"
 
 	testapi "github.com/fgrehm/kot/internal/testapi/v1"
+	"github.com/fgrehm/kot/pkg/conditions"
 	"github.com/fgrehm/kot/pkg/kotclient"
 	"github.com/fgrehm/kot/pkg/kottesting"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -67,4 +69,22 @@ var _ = Describe("Controller status resolution", func() {
 			return *val, nil
 		}).Should(Equal("modified"))
 	})
+
+	It("sets conditions from reconciler outcomes", func() {
+		Expect(client.CreateAndWait(ctx, owner)).To(Succeed())
+		Eventually(func() (metav1.ConditionStatus, error) {
+			if err := client.Reload(ctx, owner); err != nil {
+				return "", err
+			}
+			ready := apimeta.FindStatusCondition(owner.Status.Conditions, conditions.TypeReady)
+			if ready == nil {
+				return "", nil
+			}
+			return ready.Status, nil
+		}).Should(Equal(metav1.ConditionTrue))
+
+		cond := apimeta.FindStatusCondition(owner.Status.Conditions, "ConfigMapReconciled")
+		Expect(cond).NotTo(BeNil())
+		Expect(cond.ObservedGeneration).To(Equal(owner.Generation))
+	})
 })
@@ -30,6 +30,16 @@ type SimpleCRDStatus struct {
 	KnownSecretValue    *string `json:"knownSecretValue"`
 	NamespaceAnnotation string  `json:"namespaceAnnotation"`
 	Finalizing          bool    `json:"finalizing"`
+
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+func (in *SimpleCRD) GetConditions() []metav1.Condition {
+	return in.Status.Conditions
+}
+
+func (in *SimpleCRD) SetConditions(conditions []metav1.Condition) {
+	in.Status.Conditions = conditions
 }
 
 type SimpleCRDList struct {
@@ -43,6 +53,7 @@ func (in *SimpleCRD) DeepCopyInto(out *SimpleCRD) {
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 func (in *SimpleCRD) DeepCopy() *SimpleCRD {
@@ -107,6 +118,17 @@ func (in *SimpleCRDSpec) DeepCopy() *SimpleCRDSpec {
 	return out
 }
 
+func (in *SimpleCRDStatus) DeepCopyInto(out *SimpleCRDStatus) {
+	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
 func init() {
 	SchemeBuilder.Register(&SimpleCRD{}, &SimpleCRDList{})
 }
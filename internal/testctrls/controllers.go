@@ -16,11 +16,10 @@ var StaticValue = "0123456789"
 var nsWatcher = kot.Watch(&kot.ResourceWatcher{
 	Watches: &corev1.Namespace{},
 	When:    kot.ResourceVersionChangedPredicate{},
-	Enqueue: func(ctn kot.Container, obj kot.Object) ([]kot.ReconcileRequest, error) {
+	Enqueue: func(ctx context.Context, ctn kot.Container, obj kot.Object) ([]kot.ReconcileRequest, error) {
 		client := kot.ClientDep(ctn)
 		list := &testapi.SimpleCRDList{}
 
-		ctx := context.Background()
 		ns := obj.(*corev1.Namespace)
 		if err := client.List(ctx, list, kot.InNamespace(ns.Name)); err != nil {
 			return nil, err
@@ -140,6 +139,8 @@ var statusResolver = kot.ActionFn(func(ctx kot.Context) (kot.Result, error) {
 })
 
 var delayFinalizer = &kot.SimpleFinalizer{
+	Name: "kot.io/delay",
+
 	EnabledFn: func(ctx kot.Context) (bool, error) {
 		return kot.HasAnnotation(ctx.Resource(), "delay"), nil
 	},
@@ -2,13 +2,16 @@ package kot
 
 import (
 	"github.com/fgrehm/kot/pkg/action"
+	"github.com/fgrehm/kot/pkg/conditions"
 	"github.com/fgrehm/kot/pkg/controller"
 	"github.com/fgrehm/kot/pkg/deps"
 	wkdeps "github.com/fgrehm/kot/pkg/deps/wellknown"
 	"github.com/fgrehm/kot/pkg/indexing"
 	"github.com/fgrehm/kot/pkg/kotclient"
+	"github.com/fgrehm/kot/pkg/ownership"
 	"github.com/fgrehm/kot/pkg/reconcile"
 	"github.com/fgrehm/kot/pkg/setup"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
 	apiutil "sigs.k8s.io/controller-runtime/pkg/client/apiutil"
 	runtimepredicate "sigs.k8s.io/controller-runtime/pkg/predicate"
@@ -27,11 +30,20 @@ type Container = deps.Container
 type Config = setup.Config
 
 type Controller = controller.Controller
+type PlanOptions = controller.PlanOptions
+
+const (
+	PausedAnnotation               = controller.PausedAnnotation
+	ReconcileRequestedAtAnnotation = controller.ReconcileRequestedAtAnnotation
+)
 
 type Reconciler = reconcile.Reconciler
 type Reconcilers = []reconcile.Reconciler
 
 type ResourceWatcher = reconcile.ResourceWatcherConfig
+type TimerWatcher = reconcile.TimerWatcherConfig
+type ChannelWatcher = reconcile.ChannelWatcherConfig
+type ReferenceWatcher = reconcile.ReferenceWatcherConfig
 type ResourceVersionChangedPredicate = runtimepredicate.ResourceVersionChangedPredicate
 type GenerationChangedPredicate = runtimepredicate.GenerationChangedPredicate
 type AnnotationChangedPredicate = runtimepredicate.AnnotationChangedPredicate
@@ -42,24 +54,72 @@ type ReconcileRequest = runtimereconcile.Request
 type One = reconcile.OneReconcilerConfig
 type List = reconcile.ListReconcilerConfig
 type Custom = reconcile.CustomReconcilerConfig
+
+// Typed variants of reconciler and watcher configs, the GVKs involved are
+// derived from the scheme.
+type TypedOne[P, C Object] reconcile.TypedOneConfig[P, C]
+type TypedList[P Object, L ObjectList] reconcile.TypedListConfig[P, L]
+type TypedResourceWatcher[T Object] reconcile.TypedResourceWatcherConfig[T]
 type StatusResolvers = []reconcile.StatusResolver
 
+type Dependency = reconcile.Dependency
+type Dependencies = []reconcile.Dependency
+
+type OwnershipStrategy = ownership.Strategy
+type AdoptionPolicy = ownership.AdoptionPolicy
+type Discriminator = reconcile.Discriminator
+
+const (
+	AdoptNever       = ownership.AdoptNever
+	AdoptIfUnowned   = ownership.AdoptIfUnowned
+	AdoptIfAnnotated = ownership.AdoptIfAnnotated
+)
+
 type Finalizer = reconcile.Finalizer
+type NamedFinalizer = reconcile.NamedFinalizer
 type Finalizers = []reconcile.Finalizer
+type WaitForChildrenFinalizer = reconcile.WaitForChildrenFinalizer
+type FinalizerTimeoutPolicy = reconcile.FinalizerTimeoutPolicy
+
+const (
+	FinalizerTimeoutFlag        = reconcile.FinalizerTimeoutFlag
+	FinalizerTimeoutForceRemove = reconcile.FinalizerTimeoutForceRemove
+)
 
 type Object = runtimeclient.Object
 type ObjectList = runtimeclient.ObjectList
 
+type GVK = kotclient.GVK
 type ClientKey = kotclient.Key
+type ApplyOptions = kotclient.ApplyOptions
+type PatchOptions = kotclient.PatchOptions
+type DriftOptions = kotclient.DriftOptions
+type SyncListReport = kotclient.SyncListReport
 type MatchingFields = kotclient.MatchingFields
+type ObjectKey = runtimeclient.ObjectKey
 
 type Indexer = indexing.Indexer
 
+type Condition = metav1.Condition
+type ConditionsObject = conditions.Object
+
 var (
 	Watch     = reconcile.MustCreateWatcher
 	Reconcile = reconcile.MustCreateReconciler
 
+	OnName = reconcile.OnName
+	OnGVK  = reconcile.OnGVK
+
 	ListChildrenOption = indexing.ListChildrenOption
+	ListSyncReport     = reconcile.ListSyncReport
+
+	OwnerReferences  = ownership.OwnerReferences
+	OwnerLabels      = ownership.Labels
+	OwnerAnnotations = ownership.Annotations
+
+	ByName   = reconcile.ByName
+	ByLabels = reconcile.ByLabels
+	ByRole   = reconcile.ByRole
 
 	Setup = setup.Run
 
@@ -71,7 +131,10 @@ var (
 
 	InNamespace = kotclient.InNamespace
 
-	ClientDep = wkdeps.Client
+	ClientDep        = wkdeps.Client
+	EventRecorderDep = wkdeps.EventRecorder
+
+	SetCondition = conditions.Set
 )
 
 func HasAnnotation(obj Object, name string) bool {
@@ -122,6 +185,29 @@ func CopyAnnotations(src, dest Object) {
 	dest.SetAnnotations(destAnnotations)
 }
 
+func ReconcileOne[P, C Object](cfg TypedOne[P, C]) Reconciler {
+	typedCfg := reconcile.TypedOneConfig[P, C](cfg)
+	return reconcile.MustCreateReconciler(&typedCfg)
+}
+
+func ReconcileList[P Object, L ObjectList](cfg TypedList[P, L]) Reconciler {
+	typedCfg := reconcile.TypedListConfig[P, L](cfg)
+	return reconcile.MustCreateReconciler(&typedCfg)
+}
+
+func WatchResource[T Object](cfg TypedResourceWatcher[T]) reconcile.Watcher {
+	typedCfg := reconcile.TypedResourceWatcherConfig[T](cfg)
+	return reconcile.MustCreateWatcher(&typedCfg)
+}
+
+func ControllerFor[P Object](c Controller) *Controller {
+	return controller.For[P](c)
+}
+
+func TypedAction[P Object](fn func(Context, P) (Result, error)) ActionFn {
+	return action.Typed(fn)
+}
+
 func SimpleAction(fn func(Context)) ActionFn {
 	return func(ctx Context) (Result, error) {
 		fn(ctx)
@@ -150,10 +236,17 @@ func SimpleIf(fn func(Context) bool) reconcile.ReconcileIfFunc {
 }
 
 type SimpleFinalizer struct {
+	// Name registered on resources while the finalizer is pending, finalizers
+	// without a name share the legacy "kot-fin" one.
+	Name       string
 	EnabledFn  func(ctx action.Context) (bool, error)
 	FinalizeFn func(ctx action.Context) (bool, action.Result, error)
 }
 
+func (f *SimpleFinalizer) FinalizerName() string {
+	return f.Name
+}
+
 func (f *SimpleFinalizer) Enabled(ctx action.Context) (bool, error) {
 	if f.EnabledFn == nil {
 		return true, nil
@@ -19,6 +19,10 @@ func (a *CompositeAction) Run(ctx Context) (Result, error) {
 	result := Result{}
 
 	for _, action := range a.actions {
+		// Wrapped actions trace the action they wrap
+		if _, wrapped := action.(*WrapAction); !wrapped {
+			action = Traced(action)
+		}
 		actionRes, err := action.Run(ctx)
 		result = result.Merge(actionRes)
 		if err != nil {
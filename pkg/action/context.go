@@ -4,6 +4,8 @@ import (
 	"context"
 
 	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
 )
@@ -15,6 +17,9 @@ var _ context.Context = &defaultContext{}
 // resourceCtxKey is how we find runtimeclient.Object in a context.Context
 type resourceCtxKey struct{}
 
+// recorderCtxKey is how we find record.EventRecorder in a context.Context
+type recorderCtxKey struct{}
+
 // Context used to pass information between actions
 type Context interface {
 	context.Context
@@ -53,6 +58,38 @@ func (c *defaultContext) Resource() runtimeclient.Object {
 	panic("runtimeclient.Object not found on provided context")
 }
 
+// resourceFrom returns the resource carried by ctx, if any.
+func resourceFrom(ctx context.Context) runtimeclient.Object {
+	if v, ok := ctx.Value(resourceCtxKey{}).(runtimeclient.Object); ok {
+		return v
+	}
+	return nil
+}
+
 func (c *defaultContext) WithResource(obj runtimeclient.Object) Context {
 	return &defaultContext{context.WithValue(c, resourceCtxKey{}, obj)}
 }
+
+// WithEventRecorder returns a new Context, derived from ctx, which carries
+// the recorder used for events of the resource being reconciled.
+func WithEventRecorder(ctx context.Context, recorder record.EventRecorder) context.Context {
+	return context.WithValue(ctx, recorderCtxKey{}, recorder)
+}
+
+// EventRecorderFrom returns the recorder carried by ctx, events are discarded
+// if none was set.
+func EventRecorderFrom(ctx context.Context) record.EventRecorder {
+	if v, ok := ctx.Value(recorderCtxKey{}).(record.EventRecorder); ok {
+		return v
+	}
+	return nopRecorder{}
+}
+
+type nopRecorder struct{}
+
+func (nopRecorder) Event(runtime.Object, string, string, string) {}
+
+func (nopRecorder) Eventf(runtime.Object, string, string, string, ...interface{}) {}
+
+func (nopRecorder) AnnotatedEventf(runtime.Object, map[string]string, string, string, string, ...interface{}) {
+}
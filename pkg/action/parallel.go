@@ -0,0 +1,105 @@
+package action
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// ParallelAction runs actions concurrently. Actions share the same Context, so
+// they should not modify the resource being reconciled.
+type ParallelAction struct {
+	allowErrors bool
+	maxWorkers  int
+	actions     []Action
+}
+
+func Parallel(actions ...Action) *ParallelAction {
+	return &ParallelAction{actions: actions}
+}
+
+func (a *ParallelAction) Run(ctx Context) (Result, error) {
+	var (
+		results = make([]Result, len(a.actions))
+		errs    = make([]error, len(a.actions))
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+		next    = 0
+		stop    = false
+	)
+
+	// Actions are started in order, no new action is started once one of them
+	// halts or fails without errors being allowed
+	take := func() (int, bool) {
+		mu.Lock()
+		defer mu.Unlock()
+		if stop || next >= len(a.actions) {
+			return 0, false
+		}
+		next++
+		return next - 1, true
+	}
+
+	workers := a.maxWorkers
+	if workers <= 0 || workers > len(a.actions) {
+		workers = len(a.actions)
+	}
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				i, ok := take()
+				if !ok {
+					return
+				}
+
+				action := a.actions[i]
+				// Wrapped actions trace the action they wrap
+				if _, wrapped := action.(*WrapAction); !wrapped {
+					action = Traced(action)
+				}
+				res, err := action.Run(ctx)
+
+				mu.Lock()
+				results[i], errs[i] = res, err
+				if res.Halt || (err != nil && !a.allowErrors) {
+					stop = true
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	result := Result{}.Merge(results...)
+	result.Halt = false // "Consume" the halt, if any
+
+	allErrors := []string{}
+	for _, err := range errs {
+		if err == nil {
+			continue
+		}
+		if !a.allowErrors {
+			return result, err
+		}
+		allErrors = append(allErrors, err.Error())
+	}
+	if len(allErrors) == 0 {
+		return result, nil
+	}
+
+	return result, fmt.Errorf(`one or more errors occurred: ["%s"]`, strings.Join(allErrors, `", "`))
+}
+
+func (a *ParallelAction) AllowErrors() *ParallelAction {
+	a.allowErrors = true
+	return a
+}
+
+// MaxWorkers bounds how many actions run at the same time, all of them are
+// started at once by default.
+func (a *ParallelAction) MaxWorkers(n int) *ParallelAction {
+	a.maxWorkers = n
+	return a
+}
@@ -0,0 +1,128 @@
+package action_test
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/fgrehm/kot/pkg/action"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ParallelAction", func() {
+	Describe("Run", func() {
+		It("calls each action's Run method", func() {
+			var mu sync.Mutex
+			called := []string{}
+			record := func(name string) action.ActionFn {
+				return func(action.Context) (action.Result, error) {
+					mu.Lock()
+					defer mu.Unlock()
+					called = append(called, name)
+					return action.Result{}, nil
+				}
+			}
+
+			result, err := action.Parallel(record("A"), record("B"), record("C")).Run(action.NewBackgroundContext())
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result).To(Equal(action.Result{}))
+			Expect(called).To(ConsistOf("A", "B", "C"))
+		})
+
+		It("merges results", func() {
+			parallel := action.Parallel(
+				action.ActionFn(func(action.Context) (action.Result, error) {
+					return action.Result{Requeue: true}, nil
+				}),
+				action.ActionFn(func(action.Context) (action.Result, error) {
+					return action.Result{RequeueAfter: time.Minute * 1}, nil
+				}),
+			)
+
+			result, err := parallel.Run(action.NewBackgroundContext())
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result).To(Equal(action.Result{Requeue: true, RequeueAfter: time.Minute * 1}))
+		})
+
+		It("bounds the number of actions running at the same time", func() {
+			var running, maxRunning int32
+			actions := []action.Action{}
+			for i := 0; i < 6; i++ {
+				actions = append(actions, action.ActionFn(func(action.Context) (action.Result, error) {
+					current := atomic.AddInt32(&running, 1)
+					for {
+						max := atomic.LoadInt32(&maxRunning)
+						if current <= max || atomic.CompareAndSwapInt32(&maxRunning, max, current) {
+							break
+						}
+					}
+					time.Sleep(10 * time.Millisecond)
+					atomic.AddInt32(&running, -1)
+					return action.Result{}, nil
+				}))
+			}
+
+			_, err := action.Parallel(actions...).MaxWorkers(2).Run(action.NewBackgroundContext())
+			Expect(err).NotTo(HaveOccurred())
+			Expect(maxRunning).To(BeNumerically("==", 2))
+		})
+
+		It("does not start new actions after halting", func() {
+			called := []string{}
+			parallel := action.Parallel(
+				action.ActionFn(func(action.Context) (action.Result, error) {
+					called = append(called, "A")
+					return action.Result{Requeue: true, Halt: true}, nil
+				}),
+				action.ActionFn(func(action.Context) (action.Result, error) {
+					called = append(called, "B")
+					return action.Result{RequeueAfter: time.Minute * 1}, nil
+				}),
+			).MaxWorkers(1)
+
+			result, err := parallel.Run(action.NewBackgroundContext())
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result).To(Equal(action.Result{Requeue: true, Halt: false}))
+			Expect(called).To(Equal([]string{"A"}))
+		})
+
+		It("aborts on the first error by default", func() {
+			called := []string{}
+			parallel := action.Parallel(
+				action.ActionFn(func(action.Context) (action.Result, error) {
+					called = append(called, "A")
+					return action.Result{}, errors.New("error-1")
+				}),
+				action.ActionFn(func(action.Context) (action.Result, error) {
+					called = append(called, "B")
+					return action.Result{}, errors.New("error-2")
+				}),
+			).MaxWorkers(1)
+
+			result, err := parallel.Run(action.NewBackgroundContext())
+			Expect(err).To(MatchError("error-1"))
+			Expect(result).To(Equal(action.Result{}))
+			Expect(called).To(Equal([]string{"A"}))
+		})
+
+		It("collects errors in order if errors are allowed", func() {
+			parallel := action.Parallel(
+				action.ActionFn(func(action.Context) (action.Result, error) {
+					time.Sleep(10 * time.Millisecond)
+					return action.Result{}, errors.New("error-1")
+				}),
+				action.ActionFn(func(action.Context) (action.Result, error) {
+					return action.Result{}, nil
+				}),
+				action.ActionFn(func(action.Context) (action.Result, error) {
+					return action.Result{}, errors.New("error-2")
+				}),
+			).AllowErrors()
+
+			_, err := parallel.Run(action.NewBackgroundContext())
+			Expect(err).To(MatchError(`one or more errors occurred: ["error-1", "error-2"]`))
+		})
+	})
+})
@@ -0,0 +1,98 @@
+package action
+
+import (
+	"context"
+	"reflect"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const TracerName = "github.com/fgrehm/kot"
+
+// SpanNamer is implemented by actions that want to name their tracing span,
+// otherwise the name of the action type is used.
+type SpanNamer interface {
+	SpanName() string
+}
+
+// gvkOwner is implemented by actions that manage objects of a GVK, like
+// resource reconcilers.
+type gvkOwner interface {
+	OwnedGVK() schema.GroupVersionKind
+}
+
+// Traced runs the action on its own tracing span, nested under the span found
+// on ctx if any.
+func Traced(action Action) Action {
+	if _, ok := action.(*tracedAction); ok {
+		return action
+	}
+	return &tracedAction{action}
+}
+
+type tracedAction struct {
+	Action
+}
+
+func (a *tracedAction) Run(ctx Context) (Result, error) {
+	// Wrapped actions are described by the action they wrap
+	target := a.Action
+	for {
+		wrap, ok := target.(*WrapAction)
+		if !ok {
+			break
+		}
+		target = wrap.innerAction
+	}
+
+	attrs := []attribute.KeyValue{}
+	if resource := resourceFrom(ctx); resource != nil {
+		attrs = append(attrs, attribute.String("kot.parent", runtimeclient.ObjectKeyFromObject(resource).String()))
+	}
+	if owner, ok := target.(gvkOwner); ok {
+		attrs = append(attrs, attribute.String("kot.owned_gvk", owner.OwnedGVK().String()))
+	}
+
+	spanCtx, span := otel.Tracer(TracerName).Start(ctx, spanName(target), trace.WithAttributes(attrs...))
+	defer span.End()
+
+	res, err := a.Action.Run(withSpanContext(ctx, spanCtx))
+	span.SetAttributes(
+		attribute.Bool("kot.result.requeue", res.Requeue),
+		attribute.String("kot.result.requeue_after", res.RequeueAfter.String()),
+		attribute.Bool("kot.result.halt", res.Halt),
+	)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	} else {
+		span.SetStatus(codes.Ok, "")
+	}
+	return res, err
+}
+
+// withSpanContext carries the span started on spanCtx over to ctx. Only
+// contexts created by the package are derived from spanCtx, other Context
+// implementations reach actions as they are and their spans are not nested.
+func withSpanContext(ctx Context, spanCtx context.Context) Context {
+	if _, ok := ctx.(*defaultContext); ok {
+		return &defaultContext{spanCtx}
+	}
+	return ctx
+}
+
+func spanName(action Action) string {
+	if namer, ok := action.(SpanNamer); ok {
+		return namer.SpanName()
+	}
+	t := reflect.TypeOf(action)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.String()
+}
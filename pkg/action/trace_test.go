@@ -0,0 +1,97 @@
+package action_test
+
+import (
+	"errors"
+
+	"github.com/fgrehm/kot/pkg/action"
+	"github.com/fgrehm/kot/pkg/kottesting"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type namedAction struct {
+	action.ActionFn
+	name string
+}
+
+func (a *namedAction) SpanName() string {
+	return a.name
+}
+
+type customContext struct {
+	action.Context
+}
+
+var _ = Describe("Tracing", func() {
+	var spans *kottesting.SpanRecorder
+
+	BeforeEach(func() {
+		spans = kottesting.RecordSpans()
+	})
+
+	AfterEach(func() {
+		spans.Stop()
+	})
+
+	It("runs each action of a composite on its own span", func() {
+		ctx := action.NewBackgroundContext().WithResource(&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "parent", Namespace: "ns"},
+		})
+		composite := action.Composite(
+			&namedAction{name: "first", ActionFn: func(action.Context) (action.Result, error) {
+				return action.Result{Requeue: true}, nil
+			}},
+			&namedAction{name: "second", ActionFn: func(action.Context) (action.Result, error) {
+				return action.Result{}, errors.New("boom")
+			}},
+		).AllowErrors()
+
+		_, err := composite.Run(ctx)
+		Expect(err).To(HaveOccurred())
+		Expect(spans.SpanNames()).To(Equal([]string{"first", "second"}))
+
+		first := spans.FindSpan("first")
+		Expect(first.Attributes()).To(ContainElement(attribute.String("kot.parent", "ns/parent")))
+		Expect(first.Attributes()).To(ContainElement(attribute.Bool("kot.result.requeue", true)))
+		Expect(first.Status().Code).To(Equal(codes.Ok))
+
+		second := spans.FindSpan("second")
+		Expect(second.Status().Code).To(Equal(codes.Error))
+		Expect(second.Status().Description).To(Equal("boom"))
+	})
+
+	It("nests spans of wrapped actions", func() {
+		inner := &namedAction{name: "inner", ActionFn: func(action.Context) (action.Result, error) {
+			return action.Result{}, nil
+		}}
+		wrapped := action.Wrap(inner, func(ctx action.Context, inner action.Action) (action.Result, error) {
+			return inner.Run(ctx)
+		})
+
+		_, err := action.Composite(action.Composite(wrapped)).Run(action.NewBackgroundContext())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(spans.SpanNames()).To(Equal([]string{"inner", "action.CompositeAction"}))
+
+		innerSpan := spans.FindSpan("inner")
+		compositeSpan := spans.FindSpan("action.CompositeAction")
+		Expect(innerSpan.Parent().SpanID()).To(Equal(compositeSpan.SpanContext().SpanID()))
+	})
+
+	It("keeps custom context implementations", func() {
+		ctx := &customContext{Context: action.NewBackgroundContext()}
+		var received action.Context
+		traced := action.Traced(&namedAction{name: "custom", ActionFn: func(ctx action.Context) (action.Result, error) {
+			received = ctx
+			return action.Result{}, nil
+		}})
+
+		_, err := traced.Run(ctx)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(received).To(BeIdenticalTo(ctx))
+		Expect(spans.SpanNames()).To(Equal([]string{"custom"}))
+	})
+})
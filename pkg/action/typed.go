@@ -0,0 +1,29 @@
+package action
+
+import (
+	"fmt"
+
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ResourceAs returns the resource being reconciled as a P, failing if it is of
+// a different type.
+func ResourceAs[P runtimeclient.Object](ctx Context) (P, error) {
+	resource, ok := ctx.Resource().(P)
+	if !ok {
+		return resource, fmt.Errorf("expected resource of type %T, got %T", resource, ctx.Resource())
+	}
+	return resource, nil
+}
+
+// Typed adapts a function that receives the resource being reconciled as a P
+// to an action.
+func Typed[P runtimeclient.Object](fn func(ctx Context, resource P) (Result, error)) ActionFn {
+	return func(ctx Context) (Result, error) {
+		resource, err := ResourceAs[P](ctx)
+		if err != nil {
+			return Result{}, err
+		}
+		return fn(ctx, resource)
+	}
+}
@@ -0,0 +1,32 @@
+package action_test
+
+import (
+	"github.com/fgrehm/kot/pkg/action"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+var _ = Describe("Typed", func() {
+	It("hands the typed resource to the function", func() {
+		ctx := action.NewBackgroundContext().WithResource(&corev1.Namespace{ObjectMeta: ctrl.ObjectMeta{Name: "ns"}})
+
+		name := ""
+		_, err := action.Typed(func(ctx action.Context, ns *corev1.Namespace) (action.Result, error) {
+			name = ns.Name
+			return action.Result{}, nil
+		}).Run(ctx)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(name).To(Equal("ns"))
+	})
+
+	It("fails if the resource is of a different type", func() {
+		ctx := action.NewBackgroundContext().WithResource(&corev1.Secret{})
+
+		_, err := action.Typed(func(ctx action.Context, ns *corev1.Namespace) (action.Result, error) {
+			return action.Result{}, nil
+		}).Run(ctx)
+		Expect(err).To(MatchError("expected resource of type *v1.Namespace, got *v1.Secret"))
+	})
+})
@@ -12,5 +12,10 @@ func Wrap(action Action, aroundFn AroundFunc) *WrapAction {
 }
 
 func (a *WrapAction) Run(ctx Context) (Result, error) {
-	return a.aroundFn(ctx, a.innerAction)
+	inner := a.innerAction
+	// Wrapped actions trace the action they wrap
+	if _, wrapped := inner.(*WrapAction); !wrapped {
+		inner = Traced(inner)
+	}
+	return a.aroundFn(ctx, inner)
 }
@@ -0,0 +1,240 @@
+package conditions
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/fgrehm/kot/pkg/action"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	TypeReady              = "Ready"
+	TypePaused             = "Paused"
+	TypeReconcileRequested = "ReconcileRequested"
+
+	ReasonReconciled     = "Reconciled"
+	ReasonReconcileError = "ReconcileError"
+	ReasonInProgress     = "InProgress"
+	ReasonSkipped        = "DependencyNotReady"
+	ReasonPaused         = "Paused"
+	ReasonResumed        = "Resumed"
+	ReasonRequestHandled = "RequestHandled"
+)
+
+// Object is implemented by resources that keep a list of metav1.Condition on
+// their status.
+type Object interface {
+	runtimeclient.Object
+	GetConditions() []metav1.Condition
+	SetConditions(conditions []metav1.Condition)
+}
+
+// Tracker collects the conditions resolved during a reconciliation pass.
+type Tracker struct {
+	mu sync.Mutex
+	// reconcilers keeps track of condition types set from reconciler outcomes,
+	// in the order they were recorded
+	reconcilers []string
+	conditions  map[string]metav1.Condition
+	overrides   map[string]metav1.Condition
+}
+
+func NewTracker() *Tracker {
+	return &Tracker{
+		conditions: map[string]metav1.Condition{},
+		overrides:  map[string]metav1.Condition{},
+	}
+}
+
+// Record sets the condition of a reconciler based on the result of its run.
+func (t *Tracker) Record(reconcilerName string, res action.Result, err error) {
+	cond := metav1.Condition{
+		Type:    ReconcilerType(reconcilerName),
+		Status:  metav1.ConditionTrue,
+		Reason:  ReasonReconciled,
+		Message: fmt.Sprintf("%s reconciled", reconcilerName),
+	}
+	if err != nil {
+		cond.Status = metav1.ConditionFalse
+		cond.Reason = ReasonReconcileError
+		cond.Message = err.Error()
+	} else if res.Requeue || res.RequeueAfter > 0 {
+		cond.Status = metav1.ConditionUnknown
+		cond.Reason = ReasonInProgress
+		cond.Message = fmt.Sprintf("%s requested a requeue", reconcilerName)
+	}
+
+	t.record(cond)
+}
+
+// RecordSkipped sets the condition of a reconciler that did not run because
+// one of its dependencies is not ready.
+func (t *Tracker) RecordSkipped(reconcilerName, reason string) {
+	t.record(metav1.Condition{
+		Type:    ReconcilerType(reconcilerName),
+		Status:  metav1.ConditionUnknown,
+		Reason:  ReasonSkipped,
+		Message: reason,
+	})
+}
+
+func (t *Tracker) record(cond metav1.Condition) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if _, ok := t.conditions[cond.Type]; !ok {
+		t.reconcilers = append(t.reconcilers, cond.Type)
+	}
+	t.conditions[cond.Type] = cond
+}
+
+// Set adds a condition or overrides one that has been computed from reconciler
+// outcomes, including Ready.
+func (t *Tracker) Set(cond metav1.Condition) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.overrides[cond.Type] = cond
+}
+
+// Apply writes the conditions collected so far to obj, lastTransitionTime is
+// only changed for conditions that changed status.
+func (t *Tracker) Apply(obj Object) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	conditions := obj.GetConditions()
+	generation := obj.GetGeneration()
+	set := func(cond metav1.Condition) {
+		cond.ObservedGeneration = generation
+		apimeta.SetStatusCondition(&conditions, cond)
+	}
+
+	reconcilerConds := []metav1.Condition{}
+	for _, condType := range t.reconcilers {
+		cond := t.conditions[condType]
+		if override, ok := t.overrides[condType]; ok {
+			cond = override
+		}
+		reconcilerConds = append(reconcilerConds, cond)
+		set(cond)
+	}
+
+	overrideTypes := []string{}
+	for condType := range t.overrides {
+		if _, ok := t.conditions[condType]; !ok && condType != TypeReady {
+			overrideTypes = append(overrideTypes, condType)
+		}
+	}
+	sort.Strings(overrideTypes)
+	for _, condType := range overrideTypes {
+		set(t.overrides[condType])
+	}
+
+	if ready, ok := t.overrides[TypeReady]; ok {
+		set(ready)
+	} else if len(reconcilerConds) > 0 {
+		set(readyCondition(reconcilerConds))
+	}
+
+	obj.SetConditions(conditions)
+}
+
+func readyCondition(reconcilerConds []metav1.Condition) metav1.Condition {
+	failed, inProgress := []string{}, []string{}
+	for _, cond := range reconcilerConds {
+		switch cond.Status {
+		case metav1.ConditionFalse:
+			failed = append(failed, cond.Type)
+		case metav1.ConditionUnknown:
+			inProgress = append(inProgress, cond.Type)
+		}
+	}
+
+	if len(failed) > 0 {
+		return metav1.Condition{
+			Type:    TypeReady,
+			Status:  metav1.ConditionFalse,
+			Reason:  ReasonReconcileError,
+			Message: fmt.Sprintf("not ready: %s", strings.Join(failed, ", ")),
+		}
+	}
+	if len(inProgress) > 0 {
+		return metav1.Condition{
+			Type:    TypeReady,
+			Status:  metav1.ConditionUnknown,
+			Reason:  ReasonInProgress,
+			Message: fmt.Sprintf("in progress: %s", strings.Join(inProgress, ", ")),
+		}
+	}
+	return metav1.Condition{
+		Type:    TypeReady,
+		Status:  metav1.ConditionTrue,
+		Reason:  ReasonReconciled,
+		Message: "all reconcilers succeeded",
+	}
+}
+
+// ReconcilerType returns the condition type used for the outcome of a
+// reconciler, for example "config-map" becomes "ConfigMapReconciled".
+func ReconcilerType(reconcilerName string) string {
+	words := strings.FieldsFunc(reconcilerName, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	condType := ""
+	for _, word := range words {
+		runes := []rune(word)
+		runes[0] = unicode.ToUpper(runes[0])
+		condType += string(runes)
+	}
+	return condType + "Reconciled"
+}
+
+// trackerCtxKey is how we find the Tracker in a context.Context
+type trackerCtxKey struct{}
+
+// NewContext returns a new Context, derived from ctx, which carries the
+// provided Tracker.
+func NewContext(ctx context.Context, tracker *Tracker) context.Context {
+	return context.WithValue(ctx, trackerCtxKey{}, tracker)
+}
+
+// FromContext returns the Tracker carried by ctx, or nil if conditions are not
+// being tracked.
+func FromContext(ctx context.Context) *Tracker {
+	if v, ok := ctx.Value(trackerCtxKey{}).(*Tracker); ok {
+		return v
+	}
+	return nil
+}
+
+// Set adds or overrides a condition for the resource being reconciled, it is a
+// no-op if conditions are not being tracked.
+func Set(ctx context.Context, cond metav1.Condition) {
+	if tracker := FromContext(ctx); tracker != nil {
+		tracker.Set(cond)
+	}
+}
+
+// Record sets the condition of a reconciler based on the result of its run, it
+// is a no-op if conditions are not being tracked.
+func Record(ctx context.Context, reconcilerName string, res action.Result, err error) {
+	if tracker := FromContext(ctx); tracker != nil {
+		tracker.Record(reconcilerName, res, err)
+	}
+}
+
+// RecordSkipped sets the condition of a reconciler that did not run, it is a
+// no-op if conditions are not being tracked.
+func RecordSkipped(ctx context.Context, reconcilerName, reason string) {
+	if tracker := FromContext(ctx); tracker != nil {
+		tracker.RecordSkipped(reconcilerName, reason)
+	}
+}
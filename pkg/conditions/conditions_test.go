@@ -0,0 +1,109 @@
+package conditions_test
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	testapi "github.com/fgrehm/kot/internal/testapi/v1"
+	"github.com/fgrehm/kot/pkg/action"
+	"github.com/fgrehm/kot/pkg/conditions"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("Tracker", func() {
+	var (
+		tracker *conditions.Tracker
+		obj     *testapi.SimpleCRD
+	)
+
+	BeforeEach(func() {
+		tracker = conditions.NewTracker()
+		obj = &testapi.SimpleCRD{}
+		obj.Generation = 3
+	})
+
+	find := func(condType string) *metav1.Condition {
+		return apimeta.FindStatusCondition(obj.Status.Conditions, condType)
+	}
+
+	It("sets a condition per reconciler and a Ready condition", func() {
+		tracker.Record("config-map", action.Result{}, nil)
+		tracker.Record("Secret", action.Result{}, nil)
+		tracker.Apply(obj)
+
+		Expect(obj.Status.Conditions).To(HaveLen(3))
+		Expect(find("ConfigMapReconciled").Status).To(Equal(metav1.ConditionTrue))
+		Expect(find("SecretReconciled").Status).To(Equal(metav1.ConditionTrue))
+
+		ready := find(conditions.TypeReady)
+		Expect(ready.Status).To(Equal(metav1.ConditionTrue))
+		Expect(ready.Reason).To(Equal(conditions.ReasonReconciled))
+		Expect(ready.ObservedGeneration).To(Equal(int64(3)))
+		Expect(ready.LastTransitionTime.IsZero()).To(BeFalse())
+	})
+
+	It("marks Ready as false if a reconciler fails", func() {
+		tracker.Record("Secret", action.Result{}, nil)
+		tracker.Record("ConfigMap", action.Result{}, errors.New("boom"))
+		tracker.Apply(obj)
+
+		cond := find("ConfigMapReconciled")
+		Expect(cond.Status).To(Equal(metav1.ConditionFalse))
+		Expect(cond.Reason).To(Equal(conditions.ReasonReconcileError))
+		Expect(cond.Message).To(Equal("boom"))
+
+		ready := find(conditions.TypeReady)
+		Expect(ready.Status).To(Equal(metav1.ConditionFalse))
+		Expect(ready.Message).To(Equal("not ready: ConfigMapReconciled"))
+	})
+
+	It("marks Ready as unknown if a reconciler requeues", func() {
+		tracker.Record("ConfigMap", action.Result{RequeueAfter: time.Second}, nil)
+		tracker.Apply(obj)
+
+		Expect(find("ConfigMapReconciled").Status).To(Equal(metav1.ConditionUnknown))
+		Expect(find(conditions.TypeReady).Status).To(Equal(metav1.ConditionUnknown))
+	})
+
+	It("keeps the last transition time if status did not change", func() {
+		transitionedAt := metav1.NewTime(time.Now().Add(-time.Hour).Truncate(time.Second))
+		obj.Status.Conditions = []metav1.Condition{
+			{Type: conditions.TypeReady, Status: metav1.ConditionTrue, Reason: conditions.ReasonReconciled, LastTransitionTime: transitionedAt, ObservedGeneration: 2},
+			{Type: "ConfigMapReconciled", Status: metav1.ConditionFalse, Reason: conditions.ReasonReconcileError, LastTransitionTime: transitionedAt, ObservedGeneration: 2},
+		}
+
+		tracker.Record("ConfigMap", action.Result{}, nil)
+		tracker.Apply(obj)
+
+		ready := find(conditions.TypeReady)
+		Expect(ready.LastTransitionTime).To(Equal(transitionedAt))
+		Expect(ready.ObservedGeneration).To(Equal(int64(3)))
+
+		cond := find("ConfigMapReconciled")
+		Expect(cond.LastTransitionTime).NotTo(Equal(transitionedAt))
+	})
+
+	It("allows adding and overriding conditions", func() {
+		ctx := conditions.NewContext(context.Background(), tracker)
+
+		conditions.Record(ctx, "ConfigMap", action.Result{}, errors.New("boom"))
+		conditions.Set(ctx, metav1.Condition{Type: "Degraded", Status: metav1.ConditionFalse, Reason: "AllGood"})
+		conditions.Set(ctx, metav1.Condition{Type: conditions.TypeReady, Status: metav1.ConditionTrue, Reason: "Custom"})
+		tracker.Apply(obj)
+
+		Expect(find("Degraded").Status).To(Equal(metav1.ConditionFalse))
+		Expect(find("Degraded").ObservedGeneration).To(Equal(int64(3)))
+		Expect(find(conditions.TypeReady).Reason).To(Equal("Custom"))
+	})
+
+	It("ignores conditions if they are not being tracked", func() {
+		ctx := context.Background()
+		Expect(conditions.FromContext(ctx)).To(BeNil())
+		conditions.Set(ctx, metav1.Condition{Type: "Degraded"})
+		conditions.Record(ctx, "ConfigMap", action.Result{}, nil)
+	})
+})
@@ -4,17 +4,32 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/fgrehm/kot/pkg/action"
+	"github.com/fgrehm/kot/pkg/conditions"
 	"github.com/fgrehm/kot/pkg/deps"
 	wkdeps "github.com/fgrehm/kot/pkg/deps/wellknown"
+	"github.com/fgrehm/kot/pkg/indexing"
 	"github.com/fgrehm/kot/pkg/kotclient"
+	"github.com/fgrehm/kot/pkg/metrics"
+	"github.com/fgrehm/kot/pkg/ownership"
 	"github.com/fgrehm/kot/pkg/reconcile"
 	"github.com/go-logr/logr"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	corev1 "k8s.io/api/core/v1"
 	apiruntime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	runtimebuilder "sigs.k8s.io/controller-runtime/pkg/builder"
 	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	runtimepredicate "sigs.k8s.io/controller-runtime/pkg/predicate"
+	runtimesource "sigs.k8s.io/controller-runtime/pkg/source"
 )
 
 type Controller struct {
@@ -26,11 +41,52 @@ type Controller struct {
 	Finalizers      []reconcile.Finalizer
 	Deps            deps.Container
 
-	action action.Action
-	mgr    ctrl.Manager
-	scheme *apiruntime.Scheme
-	client kotclient.Client
-	log    logr.Logger
+	// For sets the type of the resources being reconciled, it can be used
+	// instead of GVK, which is then derived from the scheme.
+	For runtimeclient.Object
+	// FieldManager used for server side apply requests made by reconcilers,
+	// defaults to one derived from the GVK of the controller.
+	FieldManager string
+	// StatusPatch configures how status changes are sent to the API.
+	StatusPatch kotclient.PatchOptions
+	// MaxConcurrentReconcilers enables running up to this many reconcilers at the
+	// same time, they run one after another by default.
+	MaxConcurrentReconcilers int
+	// FinalizerBackoff and FinalizerMaxBackoff bound how long failed finalizers
+	// wait before being retried, see reconcile.FinalizerSet.WithBackoff.
+	FinalizerBackoff    time.Duration
+	FinalizerMaxBackoff time.Duration
+	// FinalizerTimeout enables applying FinalizerTimeoutPolicy to finalizers
+	// that are pending for longer than this, defaults to flagging them.
+	FinalizerTimeout       time.Duration
+	FinalizerTimeoutPolicy reconcile.FinalizerTimeoutPolicy
+	// Plan enables plan mode for all resources, it can also be enabled for a
+	// single resource with the PlanAnnotation.
+	Plan *PlanOptions
+	// Predicates filter events of the reconciled resources, updates of the
+	// ReconcileRequestedAtAnnotation always go through.
+	Predicates []runtimepredicate.Predicate
+	// FinalizeWhenPaused keeps running finalizers of resources that have the
+	// PausedAnnotation, so that they can still be deleted.
+	FinalizeWhenPaused bool
+
+	action          action.Action
+	pausedAction    action.Action
+	prepareErr      error
+	trackConditions bool
+	recorder        record.EventRecorder
+	name            string
+	mgr             ctrl.Manager
+	scheme          *apiruntime.Scheme
+	client          kotclient.Client
+	log             logr.Logger
+}
+
+// For returns a controller for resources of type P, which is a pointer to an
+// API type registered on the scheme.
+func For[P runtimeclient.Object](c Controller) *Controller {
+	c.For = reconcile.New[P]()
+	return &c
 }
 
 func (c *Controller) ParentGVK() kotclient.GVK {
@@ -47,20 +103,49 @@ func (c *Controller) OwnedGVKs() []kotclient.GVK {
 	return gvks
 }
 
+// Indexers returns the field indexes watchers rely on, they are only complete
+// once the controller is.
+func (c *Controller) Indexers() []indexing.Indexer {
+	indexers := []indexing.Indexer{}
+	for _, w := range c.Watchers {
+		if iw, ok := w.(reconcile.IndexingWatcher); ok {
+			indexers = append(indexers, iw.Indexer())
+		}
+	}
+	return indexers
+}
+
 func (c *Controller) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	if c.action == nil {
 		return ctrl.Result{}, errors.New("controller has not been prepared")
 	}
 
+	ctx = metrics.WithController(ctx, c.name)
+	ctx, span := otel.Tracer(action.TracerName).Start(ctx, fmt.Sprintf("Reconcile %s", c.name), trace.WithAttributes(
+		attribute.String("kot.controller", c.name),
+		attribute.String("kot.parent", req.NamespacedName.String()),
+	))
+	defer span.End()
+	start := time.Now()
+
+	res, err := c.reconcile(ctx, req)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	metrics.ObserveReconcile(ctx, start, err)
+	return res, err
+}
+
+func (c *Controller) reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	log := c.log.WithValues("resource", req.NamespacedName.String())
 	log.Info("started reconciliation")
 
 	client := c.client
-	runtimeParentObj, err := c.scheme.New(c.GVK)
+	parentObject, err := kotclient.NewObject(c.scheme, c.GVK)
 	if err != nil {
 		return ctrl.Result{}, err
 	}
-	parentObject := runtimeParentObj.(runtimeclient.Object)
 	if err := client.Get(ctx, req.NamespacedName, parentObject); err != nil {
 		if kotclient.IsNotFound(err) {
 			log.Info("skipping reconciliation because resource can't be found")
@@ -70,12 +155,40 @@ func (c *Controller) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Resu
 	}
 
 	ctx = ctrl.LoggerInto(deps.NewContext(ctx, c.Deps), log)
+	ctx = kotclient.WithFieldManager(ctx, c.FieldManager)
+	ctx = reconcile.NewSyncReportsContext(ctx, reconcile.NewSyncReports())
+	if c.trackConditions {
+		ctx = conditions.NewContext(ctx, conditions.NewTracker())
+	}
+	plan := c.planFor(parentObject)
+	paused := IsPaused(parentObject)
 	actionCtx := action.NewContext(ctx).WithResource(parentObject)
-	actionRes, err := c.action.Run(actionCtx)
+	if plan != nil {
+		log.Info("planning changes")
+		actionCtx = action.NewContext(kotclient.WithPlan(actionCtx, plan))
+	} else if c.recorder != nil {
+		// Events are not recorded for changes that are only planned
+		actionCtx = action.NewContext(action.WithEventRecorder(actionCtx, c.recorder))
+	}
+	setOperationalConditions(actionCtx, parentObject, paused)
+
+	controllerAction := c.action
+	if paused {
+		log.Info("skipping reconcilers because resource is paused")
+		controllerAction = c.pausedAction
+	}
+	actionRes, err := controllerAction.Run(actionCtx)
 	res := ctrl.Result{Requeue: actionRes.Requeue, RequeueAfter: actionRes.RequeueAfter}
 
+	if plan != nil {
+		if planErr := c.recordPlan(ctx, log, parentObject, plan); planErr != nil {
+			log.Error(planErr, "failed to record plan")
+		}
+	}
+
 	if err != nil {
 		log.Error(err, "error reconciling")
+		action.EventRecorderFrom(actionCtx).Eventf(parentObject, corev1.EventTypeWarning, reconcile.ReasonReconcileFailed, "Reconciliation failed: %s", err)
 	}
 
 	return res, err
@@ -83,11 +196,44 @@ func (c *Controller) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Resu
 
 func (c *Controller) Prepare(ctn deps.Container) {
 	c.Deps = ctn
+	c.prepareErr = nil
 	c.mgr = wkdeps.Manager(ctn)
 	c.scheme = wkdeps.Scheme(ctn)
 	c.client = wkdeps.Client(ctn)
+	c.recorder = wkdeps.EventRecorder(ctn)
+
+	if c.GVK == (kotclient.GVK{}) {
+		if c.For == nil {
+			c.prepareErr = errors.New("GVK is not set")
+			return
+		}
+		gvk, err := apiutil.GVKForObject(c.For, c.scheme)
+		if err != nil {
+			c.prepareErr = err
+			return
+		}
+		c.GVK = gvk
+	}
+	// Only children fall back to unstructured objects, a parent kind missing
+	// from the scheme is most likely a mistake
+	if !c.scheme.Recognizes(c.GVK) {
+		c.prepareErr = fmt.Errorf("kind '%s' is not registered on the scheme", c.GVK)
+		return
+	}
+
+	// Conditions are only tracked for resources that can hold them
+	if obj, err := c.scheme.New(c.GVK); err == nil {
+		_, c.trackConditions = obj.(conditions.Object)
+	}
+
+	finalizers := c.buildFinalizersAction()
+	c.action = c.buildControllerAction(finalizers)
+	c.pausedAction = c.buildPausedAction(finalizers)
 
-	c.action = c.buildControllerAction()
+	c.name = strings.ToLower(c.GVK.GroupKind().String())
+	if c.FieldManager == "" {
+		c.FieldManager = fmt.Sprintf("kot-%s", c.name)
+	}
 
 	group := c.GVK.Group
 	if group == "" {
@@ -97,7 +243,7 @@ func (c *Controller) Prepare(ctn deps.Container) {
 	c.log = c.mgr.GetLogger().WithName(ctrlName)
 }
 
-func (c *Controller) buildControllerAction() action.Action {
+func (c *Controller) buildControllerAction(finalizers action.Action) action.Action {
 	actions := []action.Action{}
 
 	if c.BeforeAll != nil {
@@ -107,27 +253,60 @@ func (c *Controller) buildControllerAction() action.Action {
 
 	// Compose finalizers and reconcilers, just so that halting them don't result
 	// in halting status resolution
-	actions = append(actions, action.Composite(
-		c.buildFinalizersAction(),
+	reconcilers := action.Composite(
+		finalizers,
 		c.buildReconcilersAction(),
-	))
+	)
 
-	if c.StatusResolvers != nil && len(c.StatusResolvers) > 0 {
-		actions = append(actions, reconcile.CreateStatusUpdater(c.Deps, c.StatusResolvers...))
+	if len(c.StatusResolvers) > 0 || c.trackConditions {
+		updater := reconcile.CreateStatusUpdater(c.Deps, c.StatusResolvers...).WithPatchOptions(c.StatusPatch)
+		actions = append(actions, withStatusUpdate(reconcilers, updater))
+	} else {
+		actions = append(actions, reconcilers)
 	}
 
 	return action.Composite(actions...)
 }
 
+// withStatusUpdate runs the status updater even if reconciliation fails, so
+// that failures end up on the status of the resource. The reconciliation error
+// is the one returned.
+func withStatusUpdate(reconcilers, updater action.Action) action.Action {
+	return action.ActionFn(func(ctx action.Context) (action.Result, error) {
+		res, err := reconcilers.Run(ctx)
+		statusRes, statusErr := action.Traced(updater).Run(ctx)
+		res = res.Merge(statusRes)
+		if err == nil {
+			return res, statusErr
+		}
+		if statusErr != nil {
+			ctrl.LoggerFrom(ctx).Error(statusErr, "failed to update status of resource that failed to reconcile")
+		}
+		return res, err
+	})
+}
+
 func (c *Controller) buildFinalizersAction() action.Action {
 	all := []reconcile.Finalizer{}
 	for _, reconciler := range c.Reconcilers {
 		if fin := reconciler.Finalizer(); fin != nil {
 			all = append(all, fin)
 		}
+		if gc := reconcile.ChildrenCollector(reconciler); gc != nil {
+			all = append(all, gc)
+		}
 	}
 	all = append(all, c.Finalizers...)
-	return reconcile.CreateFinalizerSet(c.Deps, all...)
+
+	set := reconcile.CreateFinalizerSet(c.Deps, all...).WithBackoff(c.FinalizerBackoff, c.FinalizerMaxBackoff)
+	if c.FinalizerTimeout > 0 {
+		policy := c.FinalizerTimeoutPolicy
+		if policy == "" {
+			policy = reconcile.FinalizerTimeoutFlag
+		}
+		set = set.WithTimeout(c.FinalizerTimeout, policy)
+	}
+	return set
 }
 
 func (c *Controller) buildReconcilersAction() action.Action {
@@ -135,11 +314,55 @@ func (c *Controller) buildReconcilersAction() action.Action {
 	for _, reconciler := range c.Reconcilers {
 		// TODO: Move to factory
 		deps.SafeInject(c.Deps, reconciler)
-		recActions = append(recActions, reconciler)
+		if err := reconcile.InjectionErr(reconciler); err != nil {
+			c.prepareErr = err
+			return action.ActionFn(func(action.Context) (action.Result, error) {
+				return action.Result{}, err
+			})
+		}
+		recActions = append(recActions, observeReconciler(reconciler))
+	}
+	if err := reconcile.ValidateDiscriminators(c.Reconcilers); err != nil {
+		c.prepareErr = err
+		return action.ActionFn(func(action.Context) (action.Result, error) {
+			return action.Result{}, err
+		})
+	}
+
+	for _, reconciler := range c.Reconcilers {
+		if len(reconcile.Dependencies(reconciler)) == 0 {
+			continue
+		}
+		// Only build a graph if reconcilers depend on each other
+		dag, err := newDAGAction(c.Reconcilers, recActions, c.MaxConcurrentReconcilers)
+		if err != nil {
+			c.prepareErr = err
+			return action.ActionFn(func(action.Context) (action.Result, error) {
+				return action.Result{}, err
+			})
+		}
+		return dag
+	}
+
+	if c.MaxConcurrentReconcilers > 1 {
+		return action.Parallel(recActions...).MaxWorkers(c.MaxConcurrentReconcilers).AllowErrors()
 	}
 	return action.Composite(recActions...).AllowErrors()
 }
 
+// observeReconciler keeps track of the outcome of the reconciler so that it
+// ends up on metrics and on the status conditions of the resource.
+func observeReconciler(reconciler reconcile.Reconciler) action.Action {
+	name := reconcile.ReconcilerName(reconciler)
+	return action.Wrap(reconciler, func(ctx action.Context, inner action.Action) (action.Result, error) {
+		start := time.Now()
+		res, err := inner.Run(ctx)
+		metrics.ObserveReconciler(ctx, name, start, err)
+		conditions.Record(ctx, name, res, err)
+		return res, err
+	})
+}
+
 func (c *Controller) MustComplete(ctn deps.Container) {
 	if err := c.Complete(ctn); err != nil {
 		panic(err)
@@ -148,23 +371,64 @@ func (c *Controller) MustComplete(ctn deps.Container) {
 
 func (c *Controller) Complete(ctn deps.Container) error {
 	c.Prepare(ctn)
+	if c.prepareErr != nil {
+		return c.prepareErr
+	}
 
-	owner, err := c.scheme.New(c.GVK)
+	owner, err := kotclient.NewObject(c.scheme, c.GVK)
 	if err != nil {
 		return err
 	}
-	runtimeCtrl := ctrl.NewControllerManagedBy(c.mgr).For(owner.(runtimeclient.Object))
+	runtimeCtrl := ctrl.NewControllerManagedBy(c.mgr)
+	if predicate := c.parentPredicate(); predicate != nil {
+		runtimeCtrl = runtimeCtrl.For(owner, runtimebuilder.WithPredicates(predicate))
+	} else {
+		runtimeCtrl = runtimeCtrl.For(owner)
+	}
+
+	// Reconcilers of the same GVK share watches
+	type ownedWatch struct {
+		gvk              kotclient.GVK
+		garbageCollected bool
+	}
+	watched := map[ownedWatch]bool{}
+	for _, r := range c.Reconcilers {
+		rec, ok := r.(reconcile.ResourceReconciler)
+		if !ok {
+			continue
+		}
+		watch := ownedWatch{gvk: rec.OwnedGVK(), garbageCollected: true}
+		if owning, ok := rec.(reconcile.OwningReconciler); ok {
+			watch.garbageCollected = owning.OwnershipStrategy().GarbageCollected()
+		}
+		if watched[watch] {
+			continue
+		}
+		watched[watch] = true
 
-	for _, gvk := range c.OwnedGVKs() {
-		obj, err := c.scheme.New(gvk)
+		obj, err := kotclient.NewObject(c.scheme, watch.gvk)
 		if err != nil {
 			return err
 		}
-		runtimeCtrl = runtimeCtrl.Owns(obj.(runtimeclient.Object))
+		// Children not owned through owner references are mapped to their owner
+		if !watch.garbageCollected {
+			runtimeCtrl = runtimeCtrl.Watches(&runtimesource.Kind{Type: obj}, ownership.EnqueueOwner(c.GVK))
+		} else {
+			runtimeCtrl = runtimeCtrl.Owns(obj)
+		}
 	}
 
 	for _, w := range c.Watchers {
+		if pw, ok := w.(reconcile.ParentWatcher); ok {
+			pw.WatchParents(c.GVK)
+		}
+		if cw, ok := w.(reconcile.ControllerWatcher); ok {
+			cw.WatchForController(c.name)
+		}
 		deps.SafeInject(c.Deps, w)
+		if err := reconcile.InjectionErr(w); err != nil {
+			return err
+		}
 		runtimeCtrl = runtimeCtrl.Watches(
 			w.Source(),
 			w.Handler(),
@@ -4,18 +4,28 @@ import (
 	"context"
 	"errors"
 
+	testapi "github.com/fgrehm/kot/internal/testapi/v1"
 	"github.com/fgrehm/kot/pkg/action"
+	"github.com/fgrehm/kot/pkg/conditions"
 	"github.com/fgrehm/kot/pkg/controller"
 	"github.com/fgrehm/kot/pkg/deps"
 	wkdeps "github.com/fgrehm/kot/pkg/deps/wellknown"
 	"github.com/fgrehm/kot/pkg/kotclient"
+	"github.com/fgrehm/kot/pkg/kottesting"
 	"github.com/fgrehm/kot/pkg/kottesting/gomock"
+	"github.com/fgrehm/kot/pkg/metrics"
 	"github.com/fgrehm/kot/pkg/reconcile"
 	"github.com/golang/mock/gomock"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	corev1 "k8s.io/api/core/v1"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+	runtimepredicate "sigs.k8s.io/controller-runtime/pkg/predicate"
 )
 
 var _ = Describe("Controller", func() {
@@ -26,8 +36,9 @@ var _ = Describe("Controller", func() {
 		runtimeReq ctrl.Request
 		kotCtrl    *controller.Controller
 
-		client *kotmocks.MockClient
-		mgr    *kotmocks.MockManager
+		client   *kotmocks.MockClient
+		mgr      *kotmocks.MockManager
+		recorder *record.FakeRecorder
 	)
 
 	BeforeEach(func() {
@@ -37,6 +48,7 @@ var _ = Describe("Controller", func() {
 		mockedEnv := kotmocks.NewEnv(mCtrl, GinkgoWriter)
 		client = mockedEnv.Client
 		mgr = mockedEnv.Manager
+		recorder = mockedEnv.Recorder
 
 		wkdeps.SetManager(mgr)
 
@@ -69,6 +81,75 @@ var _ = Describe("Controller", func() {
 		})
 	})
 
+	Describe("For", func() {
+		It("derives the GVK from the type of the resource", func() {
+			kotCtrl = controller.For[*corev1.Namespace](controller.Controller{
+				BeforeAll: action.Typed(func(ctx action.Context, ns *corev1.Namespace) (action.Result, error) {
+					return action.Result{}, nil
+				}),
+			})
+			kotCtrl.Prepare(deps.Build())
+
+			Expect(kotCtrl.ParentGVK()).To(Equal(corev1.SchemeGroupVersion.WithKind("Namespace")))
+			Expect(kotCtrl.FieldManager).To(Equal("kot-namespace"))
+		})
+
+		It("fails to complete if the type is not registered on the scheme", func() {
+			kotCtrl = controller.For[*unregisteredResource](controller.Controller{})
+			Expect(kotCtrl.Complete(deps.Build())).To(MatchError(ContainSubstring("no kind is registered")))
+		})
+
+		It("fails to complete if the GVK is not registered on the scheme", func() {
+			kotCtrl = &controller.Controller{GVK: corev1.SchemeGroupVersion.WithKind("Namespaces")}
+			Expect(kotCtrl.Complete(deps.Build())).To(MatchError("kind '/v1, Kind=Namespaces' is not registered on the scheme"))
+		})
+
+		It("fails to complete if no GVK is set", func() {
+			kotCtrl = &controller.Controller{}
+			Expect(kotCtrl.Complete(deps.Build())).To(MatchError("GVK is not set"))
+		})
+	})
+
+	Describe("Complete", func() {
+		It("fails if reconcilers of the same GVK can't tell their children apart", func() {
+			cmGVK := corev1.SchemeGroupVersion.WithKind("ConfigMap")
+			newRec := func(d reconcile.Discriminator) reconcile.Reconciler {
+				return &reconcile.OneReconciler{OneReconcilerConfig: &reconcile.OneReconcilerConfig{GVK: cmGVK, Discriminator: d}}
+			}
+
+			kotCtrl.Reconcilers = []reconcile.Reconciler{newRec(reconcile.ByRole("a")), newRec(nil)}
+			Expect(kotCtrl.Complete(deps.Build())).To(MatchError(`reconciler "ConfigMap" needs a discriminator, more than one reconciler manages '/v1, Kind=ConfigMap'`))
+
+			kotCtrl.Reconcilers = []reconcile.Reconciler{newRec(reconcile.ByRole("a")), newRec(reconcile.ByRole("a"))}
+			Expect(kotCtrl.Complete(deps.Build())).To(MatchError(`reconciler "ConfigMap(role=a)" has the same discriminator as another reconciler of '/v1, Kind=ConfigMap': role=a`))
+
+			named := newRec(reconcile.ByRole("b")).(*reconcile.OneReconciler)
+			named.Name = "ConfigMap(role=a)"
+			kotCtrl.Reconcilers = []reconcile.Reconciler{newRec(reconcile.ByRole("a")), named}
+			Expect(kotCtrl.Complete(deps.Build())).To(MatchError(`reconciler "ConfigMap(role=a)" has the same name as another reconciler of '/v1, Kind=ConfigMap'`))
+		})
+
+		It("fails if a reconciler can't set itself up", func() {
+			kotCtrl.Reconcilers = []reconcile.Reconciler{reconcile.MustCreateReconciler(&reconcile.TypedOneConfig[*corev1.Namespace, *unregisteredResource]{
+				Reconcile: func(action.Context, *corev1.Namespace, *unregisteredResource) (action.Result, error) {
+					return action.Result{}, nil
+				},
+			})}
+			Expect(kotCtrl.Complete(deps.Build())).To(MatchError(ContainSubstring("failed to find GVK of reconciled objects")))
+		})
+
+		It("fails if a watcher can't set itself up", func() {
+			kotCtrl.Watchers = []reconcile.Watcher{reconcile.MustCreateWatcher(&reconcile.ResourceWatcherConfig{
+				Watches: &unregisteredResource{},
+				When:    runtimepredicate.ResourceVersionChangedPredicate{},
+				Enqueue: func(context.Context, deps.Container, runtimeclient.Object) ([]ctrl.Request, error) {
+					return nil, nil
+				},
+			})}
+			Expect(kotCtrl.Complete(deps.Build())).To(MatchError(ContainSubstring("failed to find GVK of watched objects")))
+		})
+	})
+
 	Describe("Reconcile", func() {
 		Context("unprepared", func() {
 			It("fails", func() {
@@ -125,6 +206,8 @@ var _ = Describe("Controller", func() {
 				expectedErr := errors.New("expected")
 				kotCtrl.Reconcilers = []reconcile.Reconciler{&errorAction{expectedErr}, &dummyAction{}}
 				kotCtrl.Prepare(deps.Build())
+				reconcilerErrors := metrics.ReconcilerErrors.WithLabelValues("namespace", "errorAction")
+				errorsBefore := testutil.ToFloat64(reconcilerErrors)
 
 				client.EXPECT().Get(gomock.Any(), gomock.Any(), gomock.Any()).SetArg(2, corev1.Namespace{})
 
@@ -132,11 +215,52 @@ var _ = Describe("Controller", func() {
 				result, err := kotCtrl.Reconcile(ctx, req)
 				Expect(err).To(MatchError(`one or more errors occurred: ["expected"]`))
 				Expect(result).To(Equal(ctrl.Result{}))
+				Expect(recorder.Events).To(Receive(HavePrefix("Warning ReconcileFailed")))
+				Expect(testutil.ToFloat64(reconcilerErrors)).To(Equal(errorsBefore + 1))
+
+				rec := kotCtrl.Reconcilers[1].(*dummyAction)
+				Expect(rec.timesRan).To(Equal(1))
+			})
+
+			It("runs reconcilers concurrently if enabled", func() {
+				kotCtrl.MaxConcurrentReconcilers = 2
+				kotCtrl.Reconcilers = []reconcile.Reconciler{
+					&errorAction{errors.New("err-1")},
+					&dummyAction{},
+					&errorAction{errors.New("err-2")},
+				}
+				kotCtrl.Prepare(deps.Build())
+
+				client.EXPECT().Get(gomock.Any(), gomock.Any(), gomock.Any()).SetArg(2, corev1.Namespace{})
+
+				req := ctrl.Request{NamespacedName: kotclient.Key{Name: "name"}}
+				result, err := kotCtrl.Reconcile(ctx, req)
+				Expect(err).To(MatchError(`one or more errors occurred: ["err-1", "err-2"]`))
+				Expect(result).To(Equal(ctrl.Result{}))
 
 				rec := kotCtrl.Reconcilers[1].(*dummyAction)
 				Expect(rec.timesRan).To(Equal(1))
 			})
 
+			It("traces the reconciliation", func() {
+				spans := kottesting.RecordSpans()
+				defer spans.Stop()
+
+				kotCtrl.Reconcilers = []reconcile.Reconciler{&dummyAction{}}
+				kotCtrl.Prepare(deps.Build())
+
+				client.EXPECT().Get(gomock.Any(), gomock.Any(), gomock.Any()).SetArg(2, corev1.Namespace{})
+
+				req := ctrl.Request{NamespacedName: kotclient.Key{Name: "name"}}
+				_, err := kotCtrl.Reconcile(ctx, req)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(spans.SpanNames()).To(ContainElements("kotclient.Get", "controller_test.dummyAction", "Reconcile namespace"))
+				root := spans.FindSpan("Reconcile namespace")
+				Expect(spans.FindSpan("kotclient.Get").Parent().SpanID()).To(Equal(root.SpanContext().SpanID()))
+				Expect(spans.FindSpan("controller_test.dummyAction").SpanContext().TraceID()).To(Equal(root.SpanContext().TraceID()))
+			})
+
 			It("collects multiple reconciliation errors", func() {
 				kotCtrl.Reconcilers = []reconcile.Reconciler{
 					&errorAction{errors.New("err-1")},
@@ -184,6 +308,195 @@ var _ = Describe("Controller", func() {
 			})
 		})
 
+		Context("plan mode", func() {
+			var plan *kotclient.Plan
+
+			BeforeEach(func() {
+				plan = nil
+				kotCtrl.Reconcilers = []reconcile.Reconciler{reconcile.MustCreateReconciler(&reconcile.CustomReconcilerConfig{
+					Name: "planned",
+					Reconcile: func(ctx action.Context) (action.Result, error) {
+						plan = kotclient.PlanFrom(ctx)
+						return action.Result{}, nil
+					},
+				})}
+			})
+
+			It("is disabled by default", func() {
+				kotCtrl.Prepare(deps.Build())
+				client.EXPECT().Get(gomock.Any(), gomock.Any(), gomock.Any()).SetArg(2, corev1.Namespace{})
+
+				_, err := kotCtrl.Reconcile(ctx, ctrl.Request{NamespacedName: kotclient.Key{Name: "name"}})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(plan).To(BeNil())
+			})
+
+			It("records planned changes on a ConfigMap if enabled through the annotation", func() {
+				kotCtrl.Prepare(deps.Build())
+				ns := corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+					Name:        "name",
+					UID:         "ns-uid",
+					Annotations: map[string]string{controller.PlanAnnotation: "dry-run"},
+				}}
+
+				client.EXPECT().Get(gomock.Any(), kotclient.Key{Name: "name"}, gomock.Any()).SetArg(2, ns)
+				client.EXPECT().Get(gomock.Any(), kotclient.Key{Namespace: "default", Name: "kot-plan-namespace-name"}, gomock.Any()).
+					Return(kotclient.NewNotFound(kotclient.GR{}, "kot-plan-namespace-name"))
+				client.EXPECT().Create(gomock.Any(), gomock.Any()).Do(func(_ interface{}, obj runtimeclient.Object, _ ...interface{}) error {
+					cm := obj.(*corev1.ConfigMap)
+					Expect(cm.Data).To(Equal(map[string]string{controller.PlanDataKey: "[]"}))
+					Expect(cm.OwnerReferences).To(HaveLen(1))
+					return nil
+				})
+
+				_, err := kotCtrl.Reconcile(ctx, ctrl.Request{NamespacedName: kotclient.Key{Name: "name"}})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(plan).NotTo(BeNil())
+				Expect(plan.DryRun).To(BeTrue())
+			})
+
+			It("can be enabled for all resources", func() {
+				kotCtrl.Plan = &controller.PlanOptions{Namespace: "plans"}
+				kotCtrl.Prepare(deps.Build())
+
+				client.EXPECT().Get(gomock.Any(), kotclient.Key{Name: "name"}, gomock.Any()).SetArg(2, corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "name"}})
+				client.EXPECT().Get(gomock.Any(), kotclient.Key{Namespace: "plans", Name: "kot-plan-namespace-name"}, gomock.Any()).
+					Return(kotclient.NewNotFound(kotclient.GR{}, "kot-plan-namespace-name"))
+				client.EXPECT().Create(gomock.Any(), gomock.Any())
+
+				_, err := kotCtrl.Reconcile(ctx, ctrl.Request{NamespacedName: kotclient.Key{Name: "name"}})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(plan).NotTo(BeNil())
+				Expect(plan.DryRun).To(BeFalse())
+			})
+
+			It("keeps reconciling after planning to add finalizers", func() {
+				kotCtrl.Plan = &controller.PlanOptions{Namespace: "plans"}
+				kotCtrl.Finalizers = []reconcile.Finalizer{&namedFinalizer{}}
+				kotCtrl.Prepare(deps.Build())
+				client.EXPECT().Scheme().Return(mgr.GetScheme()).AnyTimes()
+
+				// Fetched again to diff the planned update
+				client.EXPECT().Get(gomock.Any(), kotclient.Key{Name: "name"}, gomock.Any()).SetArg(2, corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "name"}}).Times(2)
+				client.EXPECT().Get(gomock.Any(), kotclient.Key{Namespace: "plans", Name: "kot-plan-namespace-name"}, gomock.Any()).
+					Return(kotclient.NewNotFound(kotclient.GR{}, "kot-plan-namespace-name"))
+				client.EXPECT().Create(gomock.Any(), gomock.Any())
+
+				_, err := kotCtrl.Reconcile(ctx, ctrl.Request{NamespacedName: kotclient.Key{Name: "name"}})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(plan).NotTo(BeNil())
+				changes := plan.Changes()
+				Expect(changes).To(HaveLen(1))
+				Expect(changes[0].Operation).To(Equal(kotclient.SyncUpdate))
+				Expect(string(changes[0].Diff)).To(ContainSubstring("example.com/cleanup"))
+			})
+		})
+
+		Context("paused resources", func() {
+			var (
+				reconciler *dummyAction
+				resolver   *dummyAction
+			)
+
+			BeforeEach(func() {
+				reconciler, resolver = &dummyAction{}, &dummyAction{}
+				kotCtrl.Reconcilers = []reconcile.Reconciler{reconciler}
+				kotCtrl.StatusResolvers = []action.Action{resolver}
+			})
+
+			It("does not run reconcilers nor status resolvers", func() {
+				kotCtrl.Prepare(deps.Build())
+				ns := corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+					Name:        "name",
+					Annotations: map[string]string{controller.PausedAnnotation: "true"},
+				}}
+				client.EXPECT().Get(gomock.Any(), gomock.Any(), gomock.Any()).SetArg(2, ns)
+
+				_, err := kotCtrl.Reconcile(ctx, ctrl.Request{NamespacedName: kotclient.Key{Name: "name"}})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(reconciler.timesRan).To(Equal(0))
+				Expect(resolver.timesRan).To(Equal(0))
+			})
+
+			It("runs reconcilers if the annotation is set to false", func() {
+				kotCtrl.Prepare(deps.Build())
+				ns := corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+					Name:        "name",
+					Annotations: map[string]string{controller.PausedAnnotation: "false"},
+				}}
+				client.EXPECT().Get(gomock.Any(), gomock.Any(), gomock.Any()).SetArg(2, ns).Times(2)
+
+				_, err := kotCtrl.Reconcile(ctx, ctrl.Request{NamespacedName: kotclient.Key{Name: "name"}})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(reconciler.timesRan).To(Equal(1))
+				Expect(resolver.timesRan).To(Equal(1))
+			})
+
+			It("reflects the annotations on status conditions", func() {
+				Expect(testapi.AddToScheme(mgr.GetScheme())).To(Succeed())
+				kotCtrl.GVK = testapi.GroupVersion.WithKind("SimpleCRD")
+				wkdeps.SetClient(client)
+				kotCtrl.Prepare(deps.Build())
+
+				crd := testapi.SimpleCRD{ObjectMeta: metav1.ObjectMeta{
+					Name:        "name",
+					Annotations: map[string]string{controller.PausedAnnotation: ""},
+				}}
+				client.EXPECT().Get(gomock.Any(), gomock.Any(), gomock.Any()).SetArg(2, crd)
+				client.EXPECT().Reload(gomock.Any(), gomock.Any()).SetArg(1, crd)
+				client.EXPECT().PatchStatus(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Do(func(_, _ interface{}, obj runtimeclient.Object, _ interface{}) error {
+					crd.Status = obj.(*testapi.SimpleCRD).Status
+					return nil
+				})
+
+				_, err := kotCtrl.Reconcile(ctx, ctrl.Request{NamespacedName: kotclient.Key{Name: "name"}})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(reconciler.timesRan).To(Equal(0))
+				Expect(apimeta.IsStatusConditionTrue(crd.Status.Conditions, conditions.TypePaused)).To(BeTrue())
+
+				crd.Annotations = map[string]string{controller.ReconcileRequestedAtAnnotation: "now"}
+				client.EXPECT().Get(gomock.Any(), gomock.Any(), gomock.Any()).SetArg(2, crd)
+				client.EXPECT().Reload(gomock.Any(), gomock.Any()).SetArg(1, crd)
+				client.EXPECT().PatchStatus(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Do(func(_, _ interface{}, obj runtimeclient.Object, _ interface{}) error {
+					crd.Status = obj.(*testapi.SimpleCRD).Status
+					return nil
+				})
+
+				_, err = kotCtrl.Reconcile(ctx, ctrl.Request{NamespacedName: kotclient.Key{Name: "name"}})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(reconciler.timesRan).To(Equal(1))
+				Expect(apimeta.IsStatusConditionFalse(crd.Status.Conditions, conditions.TypePaused)).To(BeTrue())
+				requested := apimeta.FindStatusCondition(crd.Status.Conditions, conditions.TypeReconcileRequested)
+				Expect(requested).NotTo(BeNil())
+				Expect(requested.Message).To(Equal("handled reconciliation requested at now"))
+			})
+		})
+
+		Context("status conditions", func() {
+			It("records reconciler failures on status", func() {
+				Expect(testapi.AddToScheme(mgr.GetScheme())).To(Succeed())
+				kotCtrl.GVK = testapi.GroupVersion.WithKind("SimpleCRD")
+				kotCtrl.Reconcilers = []reconcile.Reconciler{&errorAction{}, &dummyAction{}}
+				wkdeps.SetClient(client)
+				kotCtrl.Prepare(deps.Build())
+
+				crd := testapi.SimpleCRD{ObjectMeta: metav1.ObjectMeta{Name: "name"}}
+				client.EXPECT().Get(gomock.Any(), gomock.Any(), gomock.Any()).SetArg(2, crd)
+				client.EXPECT().Reload(gomock.Any(), gomock.Any()).SetArg(1, crd)
+				client.EXPECT().PatchStatus(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Do(func(_, _ interface{}, obj runtimeclient.Object, _ interface{}) error {
+					crd.Status = obj.(*testapi.SimpleCRD).Status
+					return nil
+				})
+
+				_, err := kotCtrl.Reconcile(ctx, ctrl.Request{NamespacedName: kotclient.Key{Name: "name"}})
+				Expect(err).To(MatchError(ContainSubstring("error from error reconciler")))
+				ready := apimeta.FindStatusCondition(crd.Status.Conditions, conditions.TypeReady)
+				Expect(ready).NotTo(BeNil())
+				Expect(ready.Status).To(Equal(metav1.ConditionFalse))
+				Expect(ready.Reason).To(Equal(conditions.ReasonReconcileError))
+			})
+		})
+
 		Context("status resolution", func() {
 			It("does not error if resource can't be found", func() {
 				kotCtrl.StatusResolvers = []action.Action{&errorAction{}}
@@ -0,0 +1,175 @@
+package controller
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/fgrehm/kot/pkg/action"
+	"github.com/fgrehm/kot/pkg/conditions"
+	"github.com/fgrehm/kot/pkg/reconcile"
+)
+
+type dagNode struct {
+	name         string
+	action       action.Action
+	dependencies []*dagNode
+}
+
+// dagAction runs reconcilers after the ones they depend on, reconcilers are
+// skipped if a dependency failed, is not ready yet or has been skipped.
+type dagAction struct {
+	// layers of nodes that only depend on nodes of previous layers
+	layers     [][]*dagNode
+	maxWorkers int
+}
+
+type nodeOutcome struct {
+	res     action.Result
+	err     error
+	skipped bool
+}
+
+func (o nodeOutcome) ready() bool {
+	return !o.skipped && o.err == nil && !o.res.Requeue && o.res.RequeueAfter == 0
+}
+
+func (o nodeOutcome) String() string {
+	switch {
+	case o.skipped:
+		return "skipped"
+	case o.err != nil:
+		return "failed"
+	default:
+		return "not ready"
+	}
+}
+
+func newDAGAction(reconcilers []reconcile.Reconciler, actions []action.Action, maxWorkers int) (*dagAction, error) {
+	nodes := make([]*dagNode, len(reconcilers))
+	for i, r := range reconcilers {
+		nodes[i] = &dagNode{name: reconcile.ReconcilerName(r), action: actions[i]}
+	}
+
+	for i, r := range reconcilers {
+		for _, dep := range reconcile.Dependencies(r) {
+			matched := false
+			for j, other := range reconcilers {
+				if dep.Matches(other) {
+					matched = true
+					nodes[i].dependencies = append(nodes[i].dependencies, nodes[j])
+				}
+			}
+			if !matched {
+				return nil, fmt.Errorf("reconciler %q depends on %q, which is not registered", nodes[i].name, dep)
+			}
+		}
+	}
+
+	// Group nodes in layers, keeping the order in which they were registered
+	layers := [][]*dagNode{}
+	placed := map[*dagNode]bool{}
+	for len(placed) < len(nodes) {
+		layer := []*dagNode{}
+		for _, node := range nodes {
+			if placed[node] {
+				continue
+			}
+			depsPlaced := true
+			for _, dep := range node.dependencies {
+				if !placed[dep] {
+					depsPlaced = false
+					break
+				}
+			}
+			if depsPlaced {
+				layer = append(layer, node)
+			}
+		}
+
+		if len(layer) == 0 {
+			cycle := []string{}
+			for _, node := range nodes {
+				if !placed[node] {
+					cycle = append(cycle, node.name)
+				}
+			}
+			return nil, fmt.Errorf("reconcilers have circular dependencies: %s", strings.Join(cycle, ", "))
+		}
+		for _, node := range layer {
+			placed[node] = true
+		}
+		layers = append(layers, layer)
+	}
+
+	return &dagAction{layers: layers, maxWorkers: maxWorkers}, nil
+}
+
+func (a *dagAction) Run(ctx action.Context) (action.Result, error) {
+	var (
+		log      = ctx.Logger()
+		mu       sync.Mutex
+		outcomes = map[*dagNode]nodeOutcome{}
+		errs     = []string{}
+		result   = action.Result{}
+	)
+
+	for _, layer := range a.layers {
+		layerActions := []action.Action{}
+		for _, node := range layer {
+			if reason := a.skipReason(node, outcomes); reason != "" {
+				log.Info("skipping reconciler", "reconciler", node.name, "reason", reason)
+				conditions.RecordSkipped(ctx, node.name, reason)
+				outcomes[node] = nodeOutcome{skipped: true}
+				continue
+			}
+
+			node := node
+			layerActions = append(layerActions, action.Wrap(node.action, func(ctx action.Context, inner action.Action) (action.Result, error) {
+				res, err := inner.Run(ctx)
+				mu.Lock()
+				outcomes[node] = nodeOutcome{res: res, err: err}
+				mu.Unlock()
+				return res, err
+			}))
+		}
+
+		// Errors are collected from the outcomes so that they are not nested
+		var layerRes action.Result
+		if a.maxWorkers > 1 {
+			layerRes, _ = action.Parallel(layerActions...).MaxWorkers(a.maxWorkers).AllowErrors().Run(ctx)
+		} else {
+			layerRes, _ = action.Composite(layerActions...).AllowErrors().Run(ctx)
+		}
+		result = result.Merge(layerRes)
+
+		halted := false
+		for _, node := range layer {
+			outcome := outcomes[node]
+			if outcome.err != nil {
+				errs = append(errs, outcome.err.Error())
+			}
+			if outcome.res.Halt {
+				halted = true
+			}
+		}
+		if halted {
+			break
+		}
+	}
+
+	result.Halt = false // "Consume" the halt, if any
+	if len(errs) == 0 {
+		return result, nil
+	}
+	return result, fmt.Errorf(`one or more errors occurred: ["%s"]`, strings.Join(errs, `", "`))
+}
+
+func (a *dagAction) skipReason(node *dagNode, outcomes map[*dagNode]nodeOutcome) string {
+	for _, dep := range node.dependencies {
+		if outcome := outcomes[dep]; !outcome.ready() {
+			return fmt.Sprintf("dependency %s is %s", dep.name, outcome)
+		}
+	}
+	return ""
+}
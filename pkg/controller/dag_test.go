@@ -0,0 +1,163 @@
+package controller_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/fgrehm/kot/pkg/action"
+	"github.com/fgrehm/kot/pkg/controller"
+	"github.com/fgrehm/kot/pkg/deps"
+	wkdeps "github.com/fgrehm/kot/pkg/deps/wellknown"
+	"github.com/fgrehm/kot/pkg/kotclient"
+	"github.com/fgrehm/kot/pkg/kottesting/gomock"
+	"github.com/fgrehm/kot/pkg/reconcile"
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var _ = Describe("Controller reconciler dependencies", func() {
+	var (
+		mCtrl *gomock.Controller
+
+		kotCtrl *controller.Controller
+		client  *kotmocks.MockClient
+
+		mu     sync.Mutex
+		called []string
+	)
+
+	named := func(name string, result action.Result, err error, dependsOn ...reconcile.Dependency) reconcile.Reconciler {
+		return reconcile.MustCreateReconciler(&reconcile.CustomReconcilerConfig{
+			Name:      name,
+			DependsOn: dependsOn,
+			Reconcile: func(action.Context) (action.Result, error) {
+				mu.Lock()
+				defer mu.Unlock()
+				called = append(called, name)
+				return result, err
+			},
+		})
+	}
+
+	reconcileOnce := func() error {
+		client.EXPECT().Get(gomock.Any(), gomock.Any(), gomock.Any()).SetArg(2, corev1.Namespace{})
+		req := ctrl.Request{NamespacedName: kotclient.Key{Name: "name"}}
+		_, err := kotCtrl.Reconcile(context.Background(), req)
+		return err
+	}
+
+	BeforeEach(func() {
+		mCtrl = gomock.NewController(GinkgoT())
+
+		mockedEnv := kotmocks.NewEnv(mCtrl, GinkgoWriter)
+		client = mockedEnv.Client
+		wkdeps.SetManager(mockedEnv.Manager)
+
+		kotCtrl = &controller.Controller{
+			GVK: corev1.SchemeGroupVersion.WithKind("Namespace"),
+		}
+		called = []string{}
+	})
+
+	AfterEach(func() {
+		mCtrl.Finish()
+		deps.Clear()
+	})
+
+	It("runs reconcilers after the ones they depend on", func() {
+		kotCtrl.Reconcilers = []reconcile.Reconciler{
+			named("secrets", action.Result{}, nil, reconcile.OnName("namespace")),
+			named("limits", action.Result{}, nil, reconcile.OnName("namespace"), reconcile.OnName("secrets")),
+			named("namespace", action.Result{}, nil),
+		}
+		kotCtrl.Prepare(deps.Build())
+
+		Expect(reconcileOnce()).To(Succeed())
+		Expect(called).To(Equal([]string{"namespace", "secrets", "limits"}))
+	})
+
+	It("skips dependents if a dependency fails", func() {
+		kotCtrl.Reconcilers = []reconcile.Reconciler{
+			named("namespace", action.Result{}, errors.New("boom")),
+			named("secrets", action.Result{}, nil, reconcile.OnName("namespace")),
+			named("limits", action.Result{}, nil, reconcile.OnName("secrets")),
+			named("independent", action.Result{}, nil),
+		}
+		kotCtrl.Prepare(deps.Build())
+
+		Expect(reconcileOnce()).To(MatchError(`one or more errors occurred: ["boom"]`))
+		Expect(called).To(Equal([]string{"namespace", "independent"}))
+	})
+
+	It("skips dependents if a dependency is not ready", func() {
+		kotCtrl.Reconcilers = []reconcile.Reconciler{
+			named("namespace", action.Result{Requeue: true}, nil),
+			named("secrets", action.Result{}, nil, reconcile.OnName("namespace")),
+		}
+		kotCtrl.Prepare(deps.Build())
+
+		Expect(reconcileOnce()).To(Succeed())
+		Expect(called).To(Equal([]string{"namespace"}))
+	})
+
+	It("supports depending on the GVK owned by a reconciler", func() {
+		nsReconciler := reconcile.MustCreateReconciler(&reconcile.OneReconcilerConfig{
+			GVK: corev1.SchemeGroupVersion.WithKind("Namespace"),
+			Reconcile: func(action.Context, runtimeclient.Object) (action.Result, error) {
+				mu.Lock()
+				defer mu.Unlock()
+				called = append(called, "namespace")
+				return action.Result{}, nil
+			},
+		})
+		kotCtrl.Reconcilers = []reconcile.Reconciler{
+			named("secrets", action.Result{}, nil, reconcile.OnGVK(corev1.SchemeGroupVersion.WithKind("Namespace"))),
+			nsReconciler,
+		}
+		kotCtrl.Prepare(deps.Build())
+
+		client.EXPECT().List(gomock.Any(), gomock.Any(), gomock.Any())
+		client.EXPECT().Create(gomock.Any(), gomock.Any())
+		Expect(reconcileOnce()).To(Succeed())
+		Expect(called).To(Equal([]string{"namespace", "secrets"}))
+	})
+
+	It("runs independent reconcilers concurrently if enabled", func() {
+		kotCtrl.MaxConcurrentReconcilers = 2
+		kotCtrl.Reconcilers = []reconcile.Reconciler{
+			named("namespace", action.Result{}, nil),
+			named("secrets", action.Result{}, nil, reconcile.OnName("namespace")),
+			named("limits", action.Result{}, nil, reconcile.OnName("namespace")),
+		}
+		kotCtrl.Prepare(deps.Build())
+
+		Expect(reconcileOnce()).To(Succeed())
+		Expect(called[0]).To(Equal("namespace"))
+		Expect(called).To(ConsistOf("namespace", "secrets", "limits"))
+	})
+
+	It("fails to complete if dependencies are circular", func() {
+		kotCtrl.Reconcilers = []reconcile.Reconciler{
+			named("namespace", action.Result{}, nil, reconcile.OnName("limits")),
+			named("secrets", action.Result{}, nil, reconcile.OnName("namespace")),
+			named("limits", action.Result{}, nil, reconcile.OnName("secrets")),
+		}
+
+		err := kotCtrl.Complete(deps.Build())
+		Expect(err).To(MatchError("reconcilers have circular dependencies: namespace, secrets, limits"))
+	})
+
+	It("fails to complete if a dependency is not registered", func() {
+		kotCtrl.Reconcilers = []reconcile.Reconciler{
+			named("secrets", action.Result{}, nil, reconcile.OnName("namespace")),
+		}
+
+		err := kotCtrl.Complete(deps.Build())
+		Expect(err).To(MatchError(`reconciler "secrets" depends on "namespace", which is not registered`))
+	})
+})
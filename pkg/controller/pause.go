@@ -0,0 +1,99 @@
+package controller
+
+import (
+	"fmt"
+
+	"github.com/fgrehm/kot/pkg/action"
+	"github.com/fgrehm/kot/pkg/conditions"
+	"github.com/fgrehm/kot/pkg/reconcile"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	runtimepredicate "sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+const (
+	// PausedAnnotation stops reconcilers and status resolvers from running for
+	// a resource, unless it is set to "false". Finalizers only run if enabled
+	// with Controller.FinalizeWhenPaused.
+	PausedAnnotation = "kot.io/paused"
+	// ReconcileRequestedAtAnnotation forces a reconciliation pass whenever its
+	// value changes, even if the update is filtered out by predicates. Any
+	// value works, like the current time.
+	ReconcileRequestedAtAnnotation = "kot.io/reconcile-requested-at"
+)
+
+// ReconcileRequestedPredicate lets updates that change the
+// ReconcileRequestedAtAnnotation through.
+var ReconcileRequestedPredicate = runtimepredicate.Funcs{
+	CreateFunc:  func(event.CreateEvent) bool { return false },
+	DeleteFunc:  func(event.DeleteEvent) bool { return false },
+	GenericFunc: func(event.GenericEvent) bool { return false },
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		if e.ObjectOld == nil || e.ObjectNew == nil {
+			return false
+		}
+		requestedAt := e.ObjectNew.GetAnnotations()[ReconcileRequestedAtAnnotation]
+		return requestedAt != "" && requestedAt != e.ObjectOld.GetAnnotations()[ReconcileRequestedAtAnnotation]
+	},
+}
+
+// IsPaused tells if reconciliation of obj is paused by the PausedAnnotation.
+func IsPaused(obj runtimeclient.Object) bool {
+	value, found := obj.GetAnnotations()[PausedAnnotation]
+	return found && value != "false"
+}
+
+// parentPredicate filters events of the reconciled resources with the
+// predicates of the controller, nil if there are none.
+func (c *Controller) parentPredicate() runtimepredicate.Predicate {
+	if len(c.Predicates) == 0 {
+		return nil
+	}
+	return runtimepredicate.Or(ReconcileRequestedPredicate, runtimepredicate.And(c.Predicates...))
+}
+
+// buildPausedAction only keeps status conditions up to date, along with
+// finalizers if enabled.
+func (c *Controller) buildPausedAction(finalizers action.Action) action.Action {
+	actions := []action.Action{}
+	if c.FinalizeWhenPaused {
+		actions = append(actions, finalizers)
+	}
+	if c.trackConditions {
+		actions = append(actions, reconcile.CreateStatusUpdater(c.Deps).WithPatchOptions(c.StatusPatch))
+	}
+	return action.Composite(actions...)
+}
+
+// setOperationalConditions reflects the PausedAnnotation and the
+// ReconcileRequestedAtAnnotation on the status conditions of parent.
+func setOperationalConditions(ctx action.Context, parent runtimeclient.Object, paused bool) {
+	if paused {
+		conditions.Set(ctx, metav1.Condition{
+			Type:    conditions.TypePaused,
+			Status:  metav1.ConditionTrue,
+			Reason:  conditions.ReasonPaused,
+			Message: fmt.Sprintf("reconciliation is paused by the %s annotation", PausedAnnotation),
+		})
+		return
+	}
+
+	if obj, ok := parent.(conditions.Object); ok && apimeta.FindStatusCondition(obj.GetConditions(), conditions.TypePaused) != nil {
+		conditions.Set(ctx, metav1.Condition{
+			Type:    conditions.TypePaused,
+			Status:  metav1.ConditionFalse,
+			Reason:  conditions.ReasonResumed,
+			Message: "reconciliation is not paused",
+		})
+	}
+	if requestedAt := parent.GetAnnotations()[ReconcileRequestedAtAnnotation]; requestedAt != "" {
+		conditions.Set(ctx, metav1.Condition{
+			Type:    conditions.TypeReconcileRequested,
+			Status:  metav1.ConditionTrue,
+			Reason:  conditions.ReasonRequestHandled,
+			Message: fmt.Sprintf("handled reconciliation requested at %s", requestedAt),
+		})
+	}
+}
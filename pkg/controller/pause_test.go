@@ -0,0 +1,43 @@
+package controller_test
+
+import (
+	"github.com/fgrehm/kot/pkg/controller"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+)
+
+var _ = Describe("ReconcileRequestedPredicate", func() {
+	var nsRequestedAt = func(value string) *corev1.Namespace {
+		ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "ns"}}
+		if value != "" {
+			ns.Annotations = map[string]string{controller.ReconcileRequestedAtAnnotation: value}
+		}
+		return ns
+	}
+
+	It("lets updates of the annotation through", func() {
+		predicate := controller.ReconcileRequestedPredicate
+
+		Expect(predicate.Update(event.UpdateEvent{ObjectOld: nsRequestedAt(""), ObjectNew: nsRequestedAt("1")})).To(BeTrue())
+		Expect(predicate.Update(event.UpdateEvent{ObjectOld: nsRequestedAt("1"), ObjectNew: nsRequestedAt("2")})).To(BeTrue())
+		Expect(predicate.Update(event.UpdateEvent{ObjectOld: nsRequestedAt("1"), ObjectNew: nsRequestedAt("1")})).To(BeFalse())
+		Expect(predicate.Update(event.UpdateEvent{ObjectOld: nsRequestedAt("1"), ObjectNew: nsRequestedAt("")})).To(BeFalse())
+		Expect(predicate.Create(event.CreateEvent{Object: nsRequestedAt("1")})).To(BeFalse())
+	})
+})
+
+var _ = Describe("IsPaused", func() {
+	It("checks the annotation", func() {
+		ns := &corev1.Namespace{}
+		Expect(controller.IsPaused(ns)).To(BeFalse())
+
+		ns.Annotations = map[string]string{controller.PausedAnnotation: "false"}
+		Expect(controller.IsPaused(ns)).To(BeFalse())
+
+		ns.Annotations = map[string]string{controller.PausedAnnotation: ""}
+		Expect(controller.IsPaused(ns)).To(BeTrue())
+	})
+})
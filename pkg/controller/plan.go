@@ -0,0 +1,86 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/fgrehm/kot/pkg/kotclient"
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+const (
+	// PlanAnnotation enables plan mode for a single resource when set to
+	// "true", or to "dry-run" to also send writes with DryRun=All.
+	PlanAnnotation = "kot.io/plan"
+	// PlanDataKey holds the JSON encoded changes on plan ConfigMaps.
+	PlanDataKey = "plan.json"
+
+	defaultPlanNamespace = "default"
+)
+
+// PlanOptions configures plan mode, in which writes made while reconciling
+// are recorded on logs and on a ConfigMap instead of being persisted.
+type PlanOptions struct {
+	// DryRun sends writes to the API with DryRun=All, so that they are
+	// validated and defaulted by the server.
+	DryRun bool
+	// Namespace of the ConfigMaps holding plans of cluster-scoped resources,
+	// defaults to "default".
+	Namespace string
+}
+
+// planFor returns the Plan to record writes made while reconciling parent on,
+// nil if plan mode is not enabled.
+func (c *Controller) planFor(parent runtimeclient.Object) *kotclient.Plan {
+	switch parent.GetAnnotations()[PlanAnnotation] {
+	case "true":
+		return &kotclient.Plan{DryRun: c.Plan != nil && c.Plan.DryRun}
+	case "dry-run":
+		return &kotclient.Plan{DryRun: true}
+	}
+	if c.Plan != nil {
+		return &kotclient.Plan{DryRun: c.Plan.DryRun}
+	}
+	return nil
+}
+
+// PlanConfigMapKey returns where the plan of a resource is written to.
+func (c *Controller) PlanConfigMapKey(parent runtimeclient.Object) kotclient.Key {
+	namespace := parent.GetNamespace()
+	if namespace == "" {
+		namespace = defaultPlanNamespace
+		if c.Plan != nil && c.Plan.Namespace != "" {
+			namespace = c.Plan.Namespace
+		}
+	}
+	name := fmt.Sprintf("kot-plan-%s-%s", strings.ToLower(c.GVK.Kind), parent.GetName())
+	return kotclient.Key{Namespace: namespace, Name: name}
+}
+
+// recordPlan logs the planned changes and writes them to a ConfigMap owned by
+// parent, ctx must not carry the plan.
+func (c *Controller) recordPlan(ctx context.Context, log logr.Logger, parent runtimeclient.Object, plan *kotclient.Plan) error {
+	changes := plan.Changes()
+	for _, change := range changes {
+		log.Info("planned change", "operation", change.Operation, "gvk", change.GVK, "key", change.Key, "diff", string(change.Diff), "error", change.Error)
+	}
+
+	data, err := json.Marshal(changes)
+	if err != nil {
+		return err
+	}
+
+	key := c.PlanConfigMapKey(parent)
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: key.Name, Namespace: key.Namespace}}
+	_, err = controllerutil.CreateOrUpdate(ctx, c.client, cm, func() error {
+		cm.Data = map[string]string{PlanDataKey: string(data)}
+		return controllerutil.SetOwnerReference(parent, cm, c.scheme)
+	})
+	return err
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/fgrehm/kot/pkg/reconcile"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
 )
 
 func TestController(t *testing.T) {
@@ -56,3 +57,21 @@ func (a *haltAction) Run(ctx action.Context) (action.Result, error) {
 func (a *haltAction) Finalizer() reconcile.Finalizer {
 	return nil
 }
+
+type namedFinalizer struct{}
+
+func (f *namedFinalizer) Enabled(ctx action.Context) (bool, error) {
+	return true, nil
+}
+
+func (f *namedFinalizer) Finalize(ctx action.Context) (bool, action.Result, error) {
+	return true, action.Result{}, nil
+}
+
+func (f *namedFinalizer) FinalizerName() string {
+	return "example.com/cleanup"
+}
+
+type unregisteredResource struct {
+	corev1.ConfigMap
+}
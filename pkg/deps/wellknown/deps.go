@@ -4,19 +4,25 @@ import (
 	"github.com/fgrehm/kot/pkg/deps"
 	"github.com/fgrehm/kot/pkg/kotclient"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 )
 
 const (
-	mgrKey    = "kot-ctrl-runtime-mgr"
-	clientKey = "kot-client"
-	schemeKey = "kot-scheme"
+	mgrKey      = "kot-ctrl-runtime-mgr"
+	clientKey   = "kot-client"
+	schemeKey   = "kot-scheme"
+	recorderKey = "kot-event-recorder"
 )
 
+// EventRecorderName is the component reported on events recorded by kot
+const EventRecorderName = "kot"
+
 func SetManager(mgr ctrl.Manager) {
 	deps.Set(mgrKey, mgr)
 	deps.Set(schemeKey, mgr.GetScheme())
 	SetClient(kotclient.Decorate(mgr.GetClient()))
+	SetEventRecorder(mgr.GetEventRecorderFor(EventRecorderName))
 }
 
 func Manager(ctn interface{}) ctrl.Manager {
@@ -38,3 +44,16 @@ func SetClient(client kotclient.Client) {
 func Client(ctn interface{}) kotclient.Client {
 	return deps.Get(ctn, clientKey).(kotclient.Client)
 }
+
+func SetEventRecorder(recorder record.EventRecorder) {
+	deps.Set(recorderKey, recorder)
+}
+
+// EventRecorder returns the registered recorder or nil if there is none.
+func EventRecorder(ctn interface{}) record.EventRecorder {
+	recorder, err := deps.C(ctn).SafeGet(recorderKey)
+	if err != nil {
+		return nil
+	}
+	return recorder.(record.EventRecorder)
+}
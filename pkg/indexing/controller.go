@@ -2,10 +2,9 @@ package indexing
 
 import (
 	"context"
-	"fmt"
-	"reflect"
 
 	"github.com/fgrehm/kot/pkg/kotclient"
+	"github.com/fgrehm/kot/pkg/ownership"
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	ctrl "sigs.k8s.io/controller-runtime"
@@ -47,6 +46,8 @@ func IndexControllers(ctx context.Context, mgr ctrl.Manager, controllers ...Cont
 	return kotidx.index(ctx, mgr)
 }
 
+// ListChildrenOption filters children of owner, whether they are owned through
+// owner references, labels or annotations.
 func ListChildrenOption(owner runtimeclient.Object) runtimeclient.ListOption {
 	return kotclient.MatchingFields{IndexedControllerField: string(owner.GetUID())}
 }
@@ -56,7 +57,7 @@ func (i kotIndexer) index(ctx context.Context, mgr ctrl.Manager) error {
 	indexedGVKs := map[kotclient.GVK]struct{}{}
 	indexer := mgr.GetFieldIndexer()
 	for _, gvk := range i.ownedResources {
-		runtimeObj, err := mgr.GetScheme().New(gvk)
+		obj, err := kotclient.NewObject(mgr.GetScheme(), gvk)
 		if err != nil {
 			return err
 		}
@@ -69,10 +70,6 @@ func (i kotIndexer) index(ctx context.Context, mgr ctrl.Manager) error {
 		// otherwise, track it and index it
 		indexedGVKs[gvk] = struct{}{}
 
-		obj, ok := runtimeObj.(runtimeclient.Object)
-		if !ok {
-			return fmt.Errorf("unable to cast %s to runtimeclient.Object", reflect.TypeOf(runtimeObj))
-		}
 		if err := indexer.IndexField(ctx, obj, IndexedControllerField, i.indexControllerFn); err != nil {
 			return err
 		}
@@ -88,11 +85,19 @@ func (i kotIndexer) indexControllerFn(resource runtimeclient.Object) []string {
 	}
 
 	owner := metav1.GetControllerOf(metaObj)
-	if !i.isParentResource(owner) {
-		return nil
+	if i.isParentResource(owner) {
+		return []string{string(owner.UID)}
+	}
+
+	// Children owned through labels or annotations
+	if ref := ownership.OwnerOf(resource); ref != nil {
+		apiVersion, kind := ref.GVK.ToAPIVersionAndKind()
+		if _, exists := i.parentResources[parentKey{apiVersion, kind}]; exists {
+			return []string{string(ref.UID)}
+		}
 	}
 
-	return []string{string(owner.UID)}
+	return nil
 }
 
 func (i kotIndexer) isParentResource(ownerRef *metav1.OwnerReference) bool {
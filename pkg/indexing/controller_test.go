@@ -8,6 +8,7 @@ import (
 	"github.com/fgrehm/kot/pkg/indexing"
 	"github.com/fgrehm/kot/pkg/kotclient"
 	"github.com/fgrehm/kot/pkg/kottesting"
+	"github.com/fgrehm/kot/pkg/ownership"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	corev1 "k8s.io/api/core/v1"
@@ -84,6 +85,24 @@ var _ = Describe("Controller indexer", func() {
 		Expect(list.Items).To(HaveLen(2))
 	})
 
+	It("indexes child resources owned through labels", func() {
+		Expect(client.CreateAndWait(ctx, owner)).To(Succeed())
+
+		other := &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{GenerateName: "other-ns-"},
+		}
+		Expect(client.CreateAndWait(ctx, other)).To(Succeed())
+
+		cm := buildConfigMap(other.Name)
+		Expect(ownership.Labels.SetOwner(owner, cm, mgr.GetScheme())).To(Succeed())
+		Expect(client.CreateAndWait(ctx, cm)).To(Succeed())
+
+		list := &corev1.ConfigMapList{}
+		Expect(client.List(ctx, list, indexing.ListChildrenOption(owner))).To(Succeed())
+		Expect(list.Items).To(HaveLen(1))
+		Expect(list.Items[0].Namespace).To(Equal(other.Name))
+	})
+
 	It("does not index resources that have an unknown parent", func() {
 		secret := &corev1.Secret{
 			ObjectMeta: metav1.ObjectMeta{Name: "fake-parent", Namespace: defaultNamespace},
@@ -2,8 +2,6 @@ package indexing
 
 import (
 	"context"
-	"fmt"
-	"reflect"
 
 	"github.com/fgrehm/kot/pkg/kotclient"
 	ctrl "sigs.k8s.io/controller-runtime"
@@ -26,14 +24,10 @@ func MustIndexAll(ctx context.Context, mgr ctrl.Manager, indexers ...Indexer) {
 func IndexAll(ctx context.Context, mgr ctrl.Manager, indexers ...Indexer) error {
 	indexer := mgr.GetFieldIndexer()
 	for _, i := range indexers {
-		runtimeObj, err := mgr.GetScheme().New(i.GVK)
+		obj, err := kotclient.NewObject(mgr.GetScheme(), i.GVK)
 		if err != nil {
 			return err
 		}
-		obj, ok := runtimeObj.(runtimeclient.Object)
-		if !ok {
-			return fmt.Errorf("unable to cast %s to runtimeclient.Object", reflect.TypeOf(runtimeObj))
-		}
 		if err := indexer.IndexField(ctx, obj, i.Field, i.IndexFn); err != nil {
 			return err
 		}
@@ -0,0 +1,110 @@
+package kotclient
+
+import (
+	"context"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	apiruntime "k8s.io/apimachinery/pkg/runtime"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+const DefaultFieldManager = "kot"
+
+// ApplyOptions configures server side apply requests.
+type ApplyOptions struct {
+	// FieldManager owns the fields sent on the request, when empty the field
+	// manager set on the context is used and then DefaultFieldManager.
+	FieldManager string
+	// Force takes ownership of fields that are managed by someone else instead
+	// of failing with a conflict.
+	Force bool
+}
+
+// fieldManagerCtxKey is how we find the field manager in a context.Context
+type fieldManagerCtxKey struct{}
+
+// WithFieldManager returns a new Context, derived from ctx, which carries the
+// field manager to be used for server side apply requests.
+func WithFieldManager(ctx context.Context, fieldManager string) context.Context {
+	return context.WithValue(ctx, fieldManagerCtxKey{}, fieldManager)
+}
+
+// FieldManagerFrom returns the field manager carried by ctx, or
+// DefaultFieldManager if none was set.
+func FieldManagerFrom(ctx context.Context) string {
+	if v, ok := ctx.Value(fieldManagerCtxKey{}).(string); ok && v != "" {
+		return v
+	}
+	return DefaultFieldManager
+}
+
+func (c *client) Apply(ctx context.Context, obj runtimeclient.Object, opts ApplyOptions) error {
+	patchObj, err := ApplyPatchObject(obj, c.Scheme())
+	if err != nil {
+		return err
+	}
+
+	fieldManager := opts.FieldManager
+	if fieldManager == "" {
+		fieldManager = FieldManagerFrom(ctx)
+	}
+	patchOpts := []runtimeclient.PatchOption{runtimeclient.FieldOwner(fieldManager)}
+	if opts.Force {
+		patchOpts = append(patchOpts, runtimeclient.ForceOwnership)
+	}
+
+	if err := c.Patch(ctx, patchObj, runtimeclient.Apply, patchOpts...); err != nil {
+		return err
+	}
+
+	if u, ok := obj.(*unstructured.Unstructured); ok {
+		u.Object = patchObj.Object
+		return nil
+	}
+	return apiruntime.DefaultUnstructuredConverter.FromUnstructured(patchObj.Object, obj)
+}
+
+// ApplyPatchObject builds the body of a server side apply request for obj. Fields
+// populated by the API server and empty values are dropped so that only fields
+// explicitly set end up being owned by the field manager.
+func ApplyPatchObject(obj runtimeclient.Object, scheme *apiruntime.Scheme) (*unstructured.Unstructured, error) {
+	gvk, err := apiutil.GVKForObject(obj, scheme)
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := apiruntime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return nil, err
+	}
+	patchObj := &unstructured.Unstructured{Object: apiruntime.DeepCopyJSON(content)}
+	patchObj.SetGroupVersionKind(gvk)
+
+	for _, field := range serverFields {
+		unstructured.RemoveNestedField(patchObj.Object, field...)
+	}
+	pruneEmpty(patchObj.Object)
+
+	return patchObj, nil
+}
+
+func pruneEmpty(obj map[string]interface{}) {
+	for k, v := range obj {
+		switch value := v.(type) {
+		case nil:
+			delete(obj, k)
+		case map[string]interface{}:
+			pruneEmpty(value)
+			if len(value) == 0 {
+				delete(obj, k)
+			}
+		case []interface{}:
+			for _, item := range value {
+				if m, ok := item.(map[string]interface{}); ok {
+					pruneEmpty(m)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package kotclient_test
+
+import (
+	"github.com/fgrehm/kot/pkg/kotclient"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+)
+
+var _ = Describe("ApplyPatchObject", func() {
+	It("only keeps fields that were explicitly set", func() {
+		deploy := &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            "app",
+				Namespace:       "default",
+				UID:             "some-uid",
+				ResourceVersion: "10",
+			},
+			Spec: appsv1.DeploymentSpec{
+				Template: corev1.PodTemplateSpec{
+					Spec: corev1.PodSpec{
+						Containers: []corev1.Container{{Name: "app", Image: "app:latest"}},
+					},
+				},
+			},
+			Status: appsv1.DeploymentStatus{Replicas: 2},
+		}
+
+		patchObj, err := kotclient.ApplyPatchObject(deploy, clientgoscheme.Scheme)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(patchObj.GetAPIVersion()).To(Equal("apps/v1"))
+		Expect(patchObj.GetKind()).To(Equal("Deployment"))
+		Expect(patchObj.Object).To(Equal(map[string]interface{}{
+			"apiVersion": "apps/v1",
+			"kind":       "Deployment",
+			"metadata": map[string]interface{}{
+				"name":      "app",
+				"namespace": "default",
+			},
+			"spec": map[string]interface{}{
+				"template": map[string]interface{}{
+					"spec": map[string]interface{}{
+						"containers": []interface{}{
+							map[string]interface{}{"name": "app", "image": "app:latest"},
+						},
+					},
+				},
+			},
+		}))
+	})
+})
@@ -3,26 +3,91 @@ package kotclient
 import (
 	"context"
 
-	"k8s.io/apimachinery/pkg/api/equality"
 	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 type Client interface {
 	runtimeclient.Client
-
 	Reload(ctx context.Context, resource runtimeclient.Object) error
 	UpdateStatus(ctx context.Context, resource runtimeclient.Object) error
-	SyncList(ctx context.Context, listBefore, listAfter runtimeclient.ObjectList, processor ListSyncProcessFunc) error
+	Apply(ctx context.Context, obj runtimeclient.Object, opts ApplyOptions) error
+	PatchObject(ctx context.Context, objBefore, objAfter runtimeclient.Object, opts PatchOptions) error
+	PatchStatus(ctx context.Context, objBefore, objAfter runtimeclient.Object, opts PatchOptions) error
+	SyncList(ctx context.Context, listBefore, listAfter runtimeclient.ObjectList, processor ListSyncProcessFunc, opts ...SyncListOption) (*SyncListReport, error)
 }
 
 type ListSyncProcessFunc = func(obj runtimeclient.Object) error
 
+type SyncListOption func(*syncListOptions)
+
+type syncListOptions struct {
+	apply    *ApplyOptions
+	patch    PatchOptions
+	drift    DriftOptions
+	observer SyncListObserverFunc
+}
+
+// SyncOperation identifies the write SyncList made for an object.
+type SyncOperation string
+
+const (
+	SyncCreate SyncOperation = "create"
+	SyncUpdate SyncOperation = "update"
+	SyncDelete SyncOperation = "delete"
+)
+
+// SyncListObserverFunc is notified of every write made by SyncList, err is set
+// if the write failed.
+type SyncListObserverFunc func(op SyncOperation, obj runtimeclient.Object, err error)
+
+// WithApply makes SyncList write new and changed objects with server side
+// apply requests instead of creates and updates.
+func WithApply(opts ApplyOptions) SyncListOption {
+	return func(o *syncListOptions) {
+		o.apply = &opts
+	}
+}
+
+// WithPatchOptions configures how SyncList patches objects that changed.
+func WithPatchOptions(opts PatchOptions) SyncListOption {
+	return func(o *syncListOptions) {
+		o.patch = opts
+	}
+}
+
+// WithDriftOptions configures how SyncList detects objects that changed.
+func WithDriftOptions(opts DriftOptions) SyncListOption {
+	return func(o *syncListOptions) {
+		o.drift = opts
+	}
+}
+
+// WithObserver notifies fn of every write made by SyncList.
+func WithObserver(fn SyncListObserverFunc) SyncListOption {
+	return func(o *syncListOptions) {
+		o.observer = fn
+	}
+}
+
+func (o *syncListOptions) observe(op SyncOperation, obj runtimeclient.Object, err error) error {
+	if o.observer != nil {
+		o.observer(op, obj, err)
+	}
+	return err
+}
+
+type objChange struct {
+	before runtimeclient.Object
+	after  runtimeclient.Object
+	drift  Drift
+}
+
 type client struct {
 	runtimeclient.Client
 }
 
 func Decorate(cli runtimeclient.Client) Client {
-	return &client{cli}
+	return &client{&planningClient{&tracingClient{cli}}}
 }
 
 func (c *client) Reload(ctx context.Context, resource runtimeclient.Object) error {
@@ -34,30 +99,53 @@ func (c *client) UpdateStatus(ctx context.Context, resource runtimeclient.Object
 	return c.Status().Update(ctx, resource)
 }
 
-func (c *client) SyncList(ctx context.Context, listBefore, listAfter runtimeclient.ObjectList, processor ListSyncProcessFunc) error {
+func (c *client) SyncList(ctx context.Context, listBefore, listAfter runtimeclient.ObjectList, processor ListSyncProcessFunc, opts ...SyncListOption) (*SyncListReport, error) {
 	var (
 		objsToCreate = []runtimeclient.Object{}
 		objsToUpdate = []runtimeclient.Object{}
+		objsToPatch  = []objChange{}
+		options      = &syncListOptions{}
+		report       = &SyncListReport{}
 	)
 
+	for _, opt := range opts {
+		opt(options)
+	}
+
 	listBeforeIdx, err := IndexListByUID(listBefore)
 	if err != nil {
-		return err
+		return report, err
 	}
 
 	objsAfter, err := ExtractList(listAfter)
 	if err != nil {
-		return err
+		return report, err
 	}
+
 	for _, o := range objsAfter {
 		obj := o.(runtimeclient.Object)
+		objUID := string(obj.GetUID())
 		if processor != nil {
 			if err := processor(obj); err != nil {
-				return err
+				op := SyncUpdate
+				if objUID == "" {
+					op = SyncCreate
+				}
+				report.record(op, obj, err)
+				// Keep whatever exists around
+				delete(listBeforeIdx, objUID)
+				continue
 			}
+			// The processor might have adopted an existing object
+			objUID = string(obj.GetUID())
+		}
+
+		if err := StampLastAppliedHash(obj, options.drift); err != nil {
+			report.record(SyncUpdate, obj, err)
+			delete(listBeforeIdx, objUID)
+			continue
 		}
 
-		objUID := string(obj.GetUID())
 		if objUID == "" {
 			objsToCreate = append(objsToCreate, obj)
 			continue
@@ -73,28 +161,61 @@ func (c *client) SyncList(ctx context.Context, listBefore, listAfter runtimeclie
 
 		delete(listBeforeIdx, objUID)
 		if !obj.GetDeletionTimestamp().IsZero() {
+			report.skipped(obj, SkippedBeingDeleted)
 			continue
 		}
-		if !equality.Semantic.DeepEqual(prevObj, obj) {
-			objsToUpdate = append(objsToUpdate, obj)
+
+		drift, err := DetectDrift(prevObj, obj, options.drift)
+		if err != nil {
+			report.record(SyncUpdate, obj, err)
+			continue
+		}
+		if !drift.Changed() {
+			report.skipped(obj, SkippedUnchanged)
+			continue
 		}
+		objsToPatch = append(objsToPatch, objChange{before: prevObj, after: obj, drift: drift})
 	}
 
 	for _, obj := range objsToCreate {
-		if err := c.Create(ctx, obj); err != nil {
-			return err
+		if options.apply != nil {
+			err = c.Apply(ctx, obj, *options.apply)
+		} else {
+			err = c.Create(ctx, obj)
 		}
+		report.record(SyncCreate, obj, options.observe(SyncCreate, obj, err))
 	}
+
 	for _, obj := range objsToUpdate {
-		if err := c.Update(ctx, obj); err != nil {
-			return err
+		if options.apply != nil {
+			err = c.Apply(ctx, obj, *options.apply)
+		} else {
+			err = c.Update(ctx, obj)
 		}
+		report.record(SyncUpdate, obj, options.observe(SyncUpdate, obj, err))
 	}
+
+	for _, change := range objsToPatch {
+		if options.apply != nil {
+			err = c.Apply(ctx, change.after, *options.apply)
+		} else {
+			err = c.PatchObject(ctx, change.before, change.after, options.patch)
+		}
+		report.add(SyncListEntry{
+			Operation: SyncUpdate,
+			Key:       runtimeclient.ObjectKeyFromObject(change.after),
+			Drift:     change.drift,
+			Err:       options.observe(SyncUpdate, change.after, err),
+		})
+	}
+
 	for _, obj := range listBeforeIdx {
-		if err := c.Delete(ctx, obj); err != nil {
-			return err
+		if !obj.GetDeletionTimestamp().IsZero() {
+			report.skipped(obj, SkippedBeingDeleted)
+			continue
 		}
+		report.record(SyncDelete, obj, options.observe(SyncDelete, obj, c.Delete(ctx, obj)))
 	}
 
-	return nil
+	return report, report.Err()
 }
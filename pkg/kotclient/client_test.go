@@ -2,6 +2,7 @@ package kotclient_test
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
 	"github.com/fgrehm/kot/pkg/kotclient"
@@ -9,6 +10,7 @@ import (
 	. "github.com/onsi/gomega"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 var _ = Describe("Client", func() {
@@ -109,7 +111,11 @@ var _ = Describe("Client", func() {
 			list := buildCMList(*cm1, *cm2)
 			listSnapshot := list.DeepCopy()
 
-			Expect(client.SyncList(ctx, list, list.DeepCopy(), nil)).To(Succeed())
+			report, err := client.SyncList(ctx, list, list.DeepCopy(), nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(report.Changed()).To(BeFalse())
+			Expect(report.Skipped).To(HaveLen(2))
+			Expect(report.Skipped[0].Reason).To(Equal(kotclient.SkippedUnchanged))
 
 			Expect(client.Reload(ctx, cm1)).To(Succeed())
 			originalCM := listSnapshot.Items[0]
@@ -129,7 +135,10 @@ var _ = Describe("Client", func() {
 			emptyList := buildCMList()
 			list := buildCMList(*cm1, *cm2)
 
-			Expect(client.SyncList(ctx, emptyList, list, nil)).To(Succeed())
+			report, err := client.SyncList(ctx, emptyList, list, nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(report.Created).To(HaveLen(2))
+			Expect(report.Created[0].Key).To(Equal(kotclient.Key{Namespace: cm1.Namespace, Name: cm1.Name}))
 
 			Expect(client.Reload(ctx, cm1)).To(Succeed())
 			Expect(cm1.UID).NotTo(BeEmpty())
@@ -154,7 +163,10 @@ var _ = Describe("Client", func() {
 
 			newList := buildCMList(*cm2, *updatedCM1, *updatedCM3)
 
-			Expect(client.SyncList(ctx, list, newList, nil)).To(Succeed())
+			report, err := client.SyncList(ctx, list, newList, nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(report.Updated).To(HaveLen(2))
+			Expect(report.Updated[0].Drift.String()).To(Equal(`data.bar: <unset> -> "foo"`))
 
 			Expect(client.Reload(ctx, cm1)).To(Succeed())
 			originalCM := listSnapshot.Items[0]
@@ -170,6 +182,25 @@ var _ = Describe("Client", func() {
 			Expect(cm3.Data).To(Equal(updatedCM3.Data))
 		})
 
+		It("updates objects whose fields were removed", func() {
+			cm := buildCM("client-test-remove")
+			cm.Data["removed"] = "true"
+			cm.Labels = map[string]string{"removed": "true"}
+			Expect(client.Create(ctx, cm)).To(Succeed())
+
+			updatedCM := cm.DeepCopy()
+			delete(updatedCM.Data, "removed")
+			delete(updatedCM.Labels, "removed")
+
+			report, err := client.SyncList(ctx, buildCMList(*cm), buildCMList(*updatedCM), nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(report.Updated).To(HaveLen(1))
+
+			Expect(client.Reload(ctx, cm)).To(Succeed())
+			Expect(cm.Data).To(Equal(map[string]string{"value": "original"}))
+			Expect(cm.Labels).To(BeEmpty())
+		})
+
 		It("deletes objects when necessary", func() {
 			cm1 := createCM("client-test-delete-1")
 			cm2 := createCM("client-test-delete-2")
@@ -180,12 +211,59 @@ var _ = Describe("Client", func() {
 			cm2.DeletionTimestamp = &now
 			listAfter := buildCMList(*cm2)
 
-			Expect(client.SyncList(ctx, listBefore, listAfter, nil)).To(Succeed())
+			report, err := client.SyncList(ctx, listBefore, listAfter, nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(report.Deleted).To(HaveLen(1))
+			Expect(report.Skipped).To(HaveLen(1))
+			Expect(report.Skipped[0].Reason).To(Equal(kotclient.SkippedBeingDeleted))
 
 			Eventually(func() error {
 				return client.Reload(ctx, cm1)
 			}).Should(MatchError(`ConfigMap "client-test-delete-1" not found`))
 			Expect(client.Reload(ctx, cm2)).To(Succeed())
 		})
+
+		It("keeps going and aggregates errors when objects fail", func() {
+			cm1 := buildCM("client-test-errors-1")
+			cm2 := buildCM("client-test-errors-2")
+			cm3 := buildCM("client-test-errors-3")
+
+			listAfter := buildCMList(*cm1, *cm2, *cm3)
+			processor := func(obj runtimeclient.Object) error {
+				if obj.GetName() == cm2.Name {
+					return errors.New("boom")
+				}
+				return nil
+			}
+
+			report, err := client.SyncList(ctx, buildCMList(), listAfter, processor)
+			Expect(err).To(MatchError(fmt.Sprintf(`one or more errors occurred: ["failed to create %s/%s: boom"]`, cm2.Namespace, cm2.Name)))
+			Expect(report.Created).To(HaveLen(2))
+			Expect(report.Failed).To(HaveLen(1))
+			Expect(report.Failed[0].Operation).To(Equal(kotclient.SyncCreate))
+		})
+
+		It("notifies observers of every write", func() {
+			cm1 := createCM("client-test-observe-1")
+			cm2 := createCM("client-test-observe-2")
+
+			listBefore := buildCMList(*cm1, *cm2)
+			updatedCM1 := cm1.DeepCopy()
+			updatedCM1.Data = map[string]string{"changed": "yes"}
+			listAfter := buildCMList(*updatedCM1)
+
+			ops := map[kotclient.SyncOperation]string{}
+			observer := kotclient.WithObserver(func(op kotclient.SyncOperation, obj runtimeclient.Object, err error) {
+				Expect(err).NotTo(HaveOccurred())
+				ops[op] = obj.GetName()
+			})
+			_, err := client.SyncList(ctx, listBefore, listAfter, nil, observer)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(ops).To(Equal(map[kotclient.SyncOperation]string{
+				kotclient.SyncUpdate: cm1.Name,
+				kotclient.SyncDelete: cm2.Name,
+			}))
+		})
 	})
 })
@@ -15,7 +15,9 @@ type GR = schema.GroupResource
 
 var (
 	IsNotFound      = apierrors.IsNotFound
+	IsConflict      = apierrors.IsConflict
 	NewNotFound     = apierrors.NewNotFound
+	NewConflict     = apierrors.NewConflict
 	IgnoreNotFound  = runtimeclient.IgnoreNotFound
 	RetryOnConflict = retry.RetryOnConflict
 	DefaultRetry    = retry.DefaultRetry
@@ -0,0 +1,441 @@
+package kotclient
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	apiruntime "k8s.io/apimachinery/pkg/runtime"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const LastAppliedHashAnnotation = "kot.io/last-applied-hash"
+
+// serverFields are populated by the API server and never compared nor sent on
+// server side apply requests.
+var serverFields = [][]string{
+	{"status"},
+	{"metadata", "uid"},
+	{"metadata", "resourceVersion"},
+	{"metadata", "generation"},
+	{"metadata", "selfLink"},
+	{"metadata", "creationTimestamp"},
+	{"metadata", "deletionTimestamp"},
+	{"metadata", "deletionGracePeriodSeconds"},
+	{"metadata", "managedFields"},
+}
+
+// DriftOptions configures how changes made to objects are detected. By default
+// desired objects are expected to be built from existing ones and all fields
+// are compared. When FieldManager or LastAppliedHash is set only the fields set
+// on the desired object are, so fields defaulted by the API server or set by
+// someone else are left alone.
+type DriftOptions struct {
+	// Ignore lists paths of fields that are never compared, like
+	// "spec.replicas", "spec.containers[*].image" or
+	// "metadata.annotations[example.com/revision]".
+	Ignore []string
+	// FieldManager makes fields it manages on the existing object count as
+	// removed when the desired object does not set them anymore.
+	FieldManager string
+	// LastAppliedHash keeps a hash of the desired fields on the
+	// LastAppliedHashAnnotation, so that removed fields are detected without
+	// managed fields.
+	LastAppliedHash bool
+}
+
+// FieldChange is a field whose value differs from the desired one.
+type FieldChange struct {
+	Path    string
+	Current interface{}
+	Desired interface{}
+}
+
+func (c FieldChange) String() string {
+	return fmt.Sprintf("%s: %s -> %s", c.Path, formatValue(c.Current), formatValue(c.Desired))
+}
+
+// Drift lists the fields of an object that differ from the desired ones.
+type Drift []FieldChange
+
+// Changed tells if any field differs.
+func (d Drift) Changed() bool {
+	return len(d) > 0
+}
+
+func (d Drift) String() string {
+	changes := make([]string, len(d))
+	for i, change := range d {
+		changes[i] = change.String()
+	}
+	return strings.Join(changes, ", ")
+}
+
+// DetectDrift compares the fields of desired with the ones of current.
+func DetectDrift(current, desired runtimeclient.Object, opts DriftOptions) (Drift, error) {
+	ignore := parsePaths(opts.Ignore)
+
+	currentFields, err := comparableFields(current)
+	if err != nil {
+		return nil, err
+	}
+	desiredFields, err := comparableFields(desired)
+	if err != nil {
+		return nil, err
+	}
+
+	drift := Drift{}
+	// Without a way to tell which fields were set by us, fields missing from
+	// desired count as removed
+	symmetric := opts.FieldManager == "" && !opts.LastAppliedHash
+	compareFields(fieldPath{}, currentFields, desiredFields, ignore, symmetric, &drift)
+
+	if opts.FieldManager != "" {
+		for _, entry := range current.GetManagedFields() {
+			if entry.Manager != opts.FieldManager || entry.FieldsV1 == nil {
+				continue
+			}
+			managed := map[string]interface{}{}
+			if err := json.Unmarshal(entry.FieldsV1.Raw, &managed); err != nil {
+				return nil, err
+			}
+			removedFields(fieldPath{}, managed, currentFields, desiredFields, ignore, &drift)
+		}
+	}
+
+	if opts.LastAppliedHash {
+		hash, err := hashFields(desiredFields, ignore)
+		if err != nil {
+			return nil, err
+		}
+		if lastHash, found := current.GetAnnotations()[LastAppliedHashAnnotation]; !found || lastHash != hash {
+			path := fieldPath{"metadata", "annotations", LastAppliedHashAnnotation}
+			change := FieldChange{Path: path.String(), Desired: hash}
+			if found {
+				change.Current = lastHash
+			}
+			drift = append(drift, change)
+		}
+	}
+
+	return drift, nil
+}
+
+// StampLastAppliedHash records the hash of the fields set on obj on the
+// LastAppliedHashAnnotation, it does nothing unless opts.LastAppliedHash is set.
+func StampLastAppliedHash(obj runtimeclient.Object, opts DriftOptions) error {
+	if !opts.LastAppliedHash {
+		return nil
+	}
+
+	fields, err := comparableFields(obj)
+	if err != nil {
+		return err
+	}
+	hash, err := hashFields(fields, parsePaths(opts.Ignore))
+	if err != nil {
+		return err
+	}
+
+	annotations := obj.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[LastAppliedHashAnnotation] = hash
+	obj.SetAnnotations(annotations)
+	return nil
+}
+
+// comparableFields converts obj to unstructured content without the fields
+// populated by the API server.
+func comparableFields(obj runtimeclient.Object) (map[string]interface{}, error) {
+	var content map[string]interface{}
+	if u, ok := obj.(*unstructured.Unstructured); ok {
+		content = apiruntime.DeepCopyJSON(u.Object)
+	} else {
+		var err error
+		if content, err = apiruntime.DefaultUnstructuredConverter.ToUnstructured(obj); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, field := range serverFields {
+		unstructured.RemoveNestedField(content, field...)
+	}
+	unstructured.RemoveNestedField(content, "metadata", "annotations", LastAppliedHashAnnotation)
+	// Type information is not always set on typed objects
+	delete(content, "apiVersion")
+	delete(content, "kind")
+	return content, nil
+}
+
+// compareFields compares the fields set on desired, along with the ones only
+// set on current if symmetric is set.
+func compareFields(path fieldPath, current, desired interface{}, ignore []fieldPath, symmetric bool, drift *Drift) {
+	if path.ignored(ignore) {
+		return
+	}
+
+	switch desiredValue := desired.(type) {
+	case nil:
+		if symmetric && !isEmptyValue(current) {
+			*drift = append(*drift, FieldChange{Path: path.String(), Current: current})
+		}
+	case map[string]interface{}:
+		currentValue, ok := current.(map[string]interface{})
+		if !ok && current != nil {
+			*drift = append(*drift, FieldChange{Path: path.String(), Current: current, Desired: desired})
+			return
+		}
+		for _, key := range sortedKeys(desiredValue) {
+			compareFields(path.child(key), currentValue[key], desiredValue[key], ignore, symmetric, drift)
+		}
+		if !symmetric {
+			return
+		}
+		for _, key := range sortedKeys(currentValue) {
+			if _, found := desiredValue[key]; !found {
+				compareFields(path.child(key), currentValue[key], nil, ignore, symmetric, drift)
+			}
+		}
+	case []interface{}:
+		currentValue, ok := current.([]interface{})
+		if !ok || len(currentValue) != len(desiredValue) {
+			*drift = append(*drift, FieldChange{Path: path.String(), Current: current, Desired: desired})
+			return
+		}
+		for i := range desiredValue {
+			compareFields(path.child(fmt.Sprintf("[%d]", i)), currentValue[i], desiredValue[i], ignore, symmetric, drift)
+		}
+	default:
+		if !valuesEqual(current, desired) {
+			*drift = append(*drift, FieldChange{Path: path.String(), Current: current, Desired: desired})
+		}
+	}
+}
+
+// removedFields walks the fields recorded on managed fields entries, which are
+// keyed like "f:<name>", looking for the ones that are not desired anymore.
+// Items of lists are compared by compareFields.
+func removedFields(path fieldPath, managed, current, desired map[string]interface{}, ignore []fieldPath, drift *Drift) {
+	for _, key := range sortedKeys(managed) {
+		name := strings.TrimPrefix(key, "f:")
+		if name == key {
+			continue
+		}
+		childPath := path.child(name)
+		if childPath.ignored(ignore) {
+			continue
+		}
+
+		currentValue, found := current[name]
+		if !found {
+			continue
+		}
+		desiredValue := desired[name]
+		if desiredValue == nil {
+			*drift = append(*drift, FieldChange{Path: childPath.String(), Current: currentValue})
+			continue
+		}
+
+		managedFields, _ := managed[key].(map[string]interface{})
+		currentMap, currentIsMap := currentValue.(map[string]interface{})
+		desiredMap, desiredIsMap := desiredValue.(map[string]interface{})
+		if len(managedFields) > 0 && currentIsMap && desiredIsMap {
+			removedFields(childPath, managedFields, currentMap, desiredMap, ignore, drift)
+		}
+	}
+}
+
+func hashFields(fields map[string]interface{}, ignore []fieldPath) (string, error) {
+	// Empty fields are dropped so that objects hash the same way before and
+	// after being written
+	filtered := withoutIgnored(fieldPath{}, fields, ignore).(map[string]interface{})
+	pruneEmpty(filtered)
+	data, err := json.Marshal(filtered)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", sha256.Sum256(data)), nil
+}
+
+func withoutIgnored(path fieldPath, value interface{}, ignore []fieldPath) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		filtered := map[string]interface{}{}
+		for key, item := range v {
+			if itemPath := path.child(key); !itemPath.ignored(ignore) {
+				filtered[key] = withoutIgnored(itemPath, item, ignore)
+			}
+		}
+		return filtered
+	case []interface{}:
+		filtered := []interface{}{}
+		for i, item := range v {
+			if itemPath := path.child(fmt.Sprintf("[%d]", i)); !itemPath.ignored(ignore) {
+				filtered = append(filtered, withoutIgnored(itemPath, item, ignore))
+			}
+		}
+		return filtered
+	}
+	return value
+}
+
+// isEmptyValue tells if a field missing from an object is equivalent to v.
+func isEmptyValue(v interface{}) bool {
+	switch value := v.(type) {
+	case nil:
+		return true
+	case map[string]interface{}:
+		return len(value) == 0
+	case []interface{}:
+		return len(value) == 0
+	}
+	return false
+}
+
+// valuesEqual compares leaf values, numbers might be decoded as integers or
+// floats depending on where objects come from.
+func valuesEqual(a, b interface{}) bool {
+	if af, ok := toFloat(a); ok {
+		bf, ok := toFloat(b)
+		return ok && af == bf
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case float32:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
+
+func formatValue(v interface{}) string {
+	if v == nil {
+		return "<unset>"
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("%v", v)
+	}
+	return string(data)
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// fieldPath identifies a field by its keys, items of lists are identified by
+// segments like "[0]" and "[*]" matches any of them.
+type fieldPath []string
+
+func (p fieldPath) child(segment string) fieldPath {
+	return append(append(fieldPath{}, p...), segment)
+}
+
+// ignored tells if the path is or is nested under one of the given paths
+func (p fieldPath) ignored(ignore []fieldPath) bool {
+	for _, prefix := range ignore {
+		if len(prefix) > len(p) {
+			continue
+		}
+		matches := true
+		for i, segment := range prefix {
+			if segment != p[i] && !(segment == "[*]" && isIndex(p[i])) {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			return true
+		}
+	}
+	return false
+}
+
+func (p fieldPath) String() string {
+	var sb strings.Builder
+	for _, segment := range p {
+		switch {
+		case isIndex(segment):
+			sb.WriteString(segment)
+		case strings.ContainsAny(segment, ".[]"):
+			sb.WriteString("[" + segment + "]")
+		default:
+			if sb.Len() > 0 {
+				sb.WriteString(".")
+			}
+			sb.WriteString(segment)
+		}
+	}
+	return sb.String()
+}
+
+func isIndex(segment string) bool {
+	if len(segment) < 3 || segment[0] != '[' || segment[len(segment)-1] != ']' {
+		return false
+	}
+	_, err := strconv.Atoi(segment[1 : len(segment)-1])
+	return err == nil
+}
+
+// parsePaths parses paths like "spec.containers[*].image", keys that have dots
+// are wrapped in brackets like "metadata.labels[example.com/name]".
+func parsePaths(paths []string) []fieldPath {
+	parsed := make([]fieldPath, 0, len(paths))
+	for _, path := range paths {
+		segments := fieldPath{}
+		current := ""
+		for i := 0; i < len(path); i++ {
+			switch path[i] {
+			case '.':
+				if current != "" {
+					segments = append(segments, current)
+				}
+				current = ""
+			case '[':
+				if current != "" {
+					segments = append(segments, current)
+				}
+				current = ""
+				end := strings.IndexByte(path[i:], ']')
+				if end < 0 {
+					end = len(path) - i
+				}
+				key := path[i+1 : i+end]
+				if _, err := strconv.Atoi(key); err == nil || key == "*" {
+					key = "[" + key + "]"
+				}
+				segments = append(segments, key)
+				i += end
+			default:
+				current += string(path[i])
+			}
+		}
+		if current != "" {
+			segments = append(segments, current)
+		}
+		parsed = append(parsed, segments)
+	}
+	return parsed
+}
@@ -0,0 +1,122 @@
+package kotclient_test
+
+import (
+	"github.com/fgrehm/kot/pkg/kotclient"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
+)
+
+var _ = Describe("DetectDrift", func() {
+	var current, desired *appsv1.Deployment
+
+	BeforeEach(func() {
+		current = &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "app", UID: "uid", ResourceVersion: "1", Labels: map[string]string{"app": "app"}},
+			Spec: appsv1.DeploymentSpec{
+				Replicas: pointer.Int32(3),
+				Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{
+						Name:                     "app",
+						Image:                    "app:v1",
+						ImagePullPolicy:          corev1.PullIfNotPresent,
+						TerminationMessagePath:   "/dev/termination-log",
+						TerminationMessagePolicy: corev1.TerminationMessageReadFile,
+					}},
+					RestartPolicy: corev1.RestartPolicyAlways,
+				}},
+			},
+		}
+		desired = &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "app", Labels: map[string]string{"app": "app"}},
+			Spec: appsv1.DeploymentSpec{
+				Replicas: pointer.Int32(3),
+				Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{Name: "app", Image: "app:v1"}},
+				}},
+			},
+		}
+	})
+
+	It("ignores fields defaulted by the API server with a field manager", func() {
+		drift, err := kotclient.DetectDrift(current, desired, kotclient.DriftOptions{FieldManager: "kot"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(drift.Changed()).To(BeFalse())
+	})
+
+	It("reports fields removed from objects built from existing ones", func() {
+		desired = current.DeepCopy()
+		Expect(kotclient.DetectDrift(current, desired, kotclient.DriftOptions{})).To(BeEmpty())
+
+		desired.Labels = map[string]string{}
+		desired.Spec.Replicas = nil
+		desired.Spec.Template.Spec.Containers[0].ImagePullPolicy = ""
+
+		drift, err := kotclient.DetectDrift(current, desired, kotclient.DriftOptions{
+			Ignore: []string{"spec.template.spec.containers[*].imagePullPolicy"},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(drift.String()).To(Equal(`metadata.labels: {"app":"app"} -> <unset>, spec.replicas: 3 -> <unset>`))
+	})
+
+	It("reports fields that changed", func() {
+		desired.Spec.Replicas = pointer.Int32(1)
+		desired.Spec.Template.Spec.Containers[0].Image = "app:v2"
+
+		drift, err := kotclient.DetectDrift(current, desired, kotclient.DriftOptions{FieldManager: "kot"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(drift.String()).To(Equal(`spec.replicas: 3 -> 1, spec.template.spec.containers[0].image: "app:v1" -> "app:v2"`))
+	})
+
+	It("skips ignored fields", func() {
+		desired.Spec.Replicas = pointer.Int32(1)
+		desired.Spec.Template.Spec.Containers[0].Image = "app:v2"
+		desired.Labels["example.com/revision"] = "2"
+
+		drift, err := kotclient.DetectDrift(current, desired, kotclient.DriftOptions{
+			FieldManager: "kot",
+			Ignore:       []string{"spec.replicas", "spec.template.spec.containers[*].image", "metadata.labels[example.com/revision]"},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(drift.Changed()).To(BeFalse())
+	})
+
+	It("reports removed fields managed by the field manager", func() {
+		current.ManagedFields = []metav1.ManagedFieldsEntry{{
+			Manager:  "kot",
+			FieldsV1: &metav1.FieldsV1{Raw: []byte(`{"f:metadata":{"f:labels":{"f:app":{}}}}`)},
+		}}
+		desired.Labels = nil
+
+		drift, err := kotclient.DetectDrift(current, desired, kotclient.DriftOptions{FieldManager: "other"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(drift.Changed()).To(BeFalse())
+
+		drift, err = kotclient.DetectDrift(current, desired, kotclient.DriftOptions{FieldManager: "kot"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(drift.String()).To(Equal(`metadata.labels: {"app":"app"} -> <unset>`))
+	})
+
+	It("reports removed fields based on the last applied hash", func() {
+		opts := kotclient.DriftOptions{LastAppliedHash: true}
+
+		drift, err := kotclient.DetectDrift(current, desired, opts)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(drift).To(HaveLen(1))
+		Expect(drift[0].Path).To(Equal("metadata.annotations[kot.io/last-applied-hash]"))
+
+		Expect(kotclient.StampLastAppliedHash(desired, opts)).To(Succeed())
+		current.Annotations = desired.Annotations
+		drift, err = kotclient.DetectDrift(current, desired, opts)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(drift.Changed()).To(BeFalse())
+
+		desired.Labels = nil
+		drift, err = kotclient.DetectDrift(current, desired, opts)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(drift.Changed()).To(BeTrue())
+	})
+})
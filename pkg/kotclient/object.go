@@ -11,6 +11,47 @@ import (
 	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
 )
 
+// NewObject initializes an empty object of the given GVK, kinds that are not
+// registered on the scheme are handled as unstructured objects.
+func NewObject(scheme *apiruntime.Scheme, gvk GVK) (runtimeclient.Object, error) {
+	apiruntimeObj, err := scheme.New(gvk)
+	if apiruntime.IsNotRegisteredError(err) {
+		obj := &unstructured.Unstructured{}
+		obj.SetGroupVersionKind(gvk)
+		return obj, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	obj, ok := apiruntimeObj.(runtimeclient.Object)
+	if !ok {
+		return nil, fmt.Errorf("unable to cast %T to runtimeclient.Object", apiruntimeObj)
+	}
+	return obj, nil
+}
+
+// NewObjectList initializes an empty list for objects of the given GVK, kinds
+// that are not registered on the scheme are handled as unstructured lists.
+func NewObjectList(scheme *apiruntime.Scheme, gvk GVK) (runtimeclient.ObjectList, error) {
+	gvk.Kind = fmt.Sprintf("%sList", gvk.Kind)
+	apiruntimeList, err := scheme.New(gvk)
+	if apiruntime.IsNotRegisteredError(err) {
+		list := &unstructured.UnstructuredList{}
+		list.SetGroupVersionKind(gvk)
+		return list, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	list, ok := apiruntimeList.(runtimeclient.ObjectList)
+	if !ok {
+		return nil, fmt.Errorf("unable to cast %T to runtimeclient.ObjectList", apiruntimeList)
+	}
+	return list, nil
+}
+
 // Inspired by https://stackoverflow.com/a/63022947
 func ObjectField(obj runtimeclient.Object, field ...string) (interface{}, error) {
 	unstructuredObj, err := apiruntime.DefaultUnstructuredConverter.ToUnstructured(obj)
@@ -8,6 +8,8 @@ import (
 	. "github.com/onsi/gomega"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/kubernetes/scheme"
 	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
 )
 
@@ -63,6 +65,40 @@ var _ = Describe("Object resource utilities", func() {
 		})
 	})
 
+	Describe("NewObject", func() {
+		It("initializes objects of kinds registered on the scheme", func() {
+			obj, err := kotclient.NewObject(scheme.Scheme, corev1.SchemeGroupVersion.WithKind("ConfigMap"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(obj).To(Equal(&corev1.ConfigMap{}))
+		})
+
+		It("falls back to unstructured objects", func() {
+			gvk := kotclient.GVK{Group: "example.com", Version: "v1", Kind: "Widget"}
+
+			obj, err := kotclient.NewObject(scheme.Scheme, gvk)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(obj).To(BeAssignableToTypeOf(&unstructured.Unstructured{}))
+			Expect(obj.GetObjectKind().GroupVersionKind()).To(Equal(gvk))
+		})
+	})
+
+	Describe("NewObjectList", func() {
+		It("initializes lists of kinds registered on the scheme", func() {
+			list, err := kotclient.NewObjectList(scheme.Scheme, corev1.SchemeGroupVersion.WithKind("ConfigMap"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(list).To(Equal(&corev1.ConfigMapList{}))
+		})
+
+		It("falls back to unstructured lists", func() {
+			gvk := kotclient.GVK{Group: "example.com", Version: "v1", Kind: "Widget"}
+
+			list, err := kotclient.NewObjectList(scheme.Scheme, gvk)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(list).To(BeAssignableToTypeOf(&unstructured.UnstructuredList{}))
+			Expect(list.GetObjectKind().GroupVersionKind()).To(Equal(gvk.GroupVersion().WithKind("WidgetList")))
+		})
+	})
+
 	Describe("SortByAge", func() {
 		It("works", func() {
 			cm1 := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{CreationTimestamp: metav1.NewTime(time.Now().Add(time.Hour * -24))}}
@@ -0,0 +1,159 @@
+package kotclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	mergepatch "github.com/evanphx/json-patch"
+	"github.com/pkg/errors"
+	"gomodules.xyz/jsonpatch/v2"
+	"k8s.io/apimachinery/pkg/types"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// PatchOptions configures how changes made to an object are sent to the API.
+type PatchOptions struct {
+	// Type of patch to compute, either types.MergePatchType (the default) or
+	// types.JSONPatchType.
+	Type types.PatchType
+	// OptimisticLock makes the patch fail with a conflict if the object changed
+	// since it was read. Conflicts are retried on top of the latest version.
+	OptimisticLock bool
+}
+
+func (c *client) PatchObject(ctx context.Context, objBefore, objAfter runtimeclient.Object, opts PatchOptions) error {
+	return c.patchWithRetry(ctx, objBefore, objAfter, opts, func(patch runtimeclient.Patch) error {
+		return c.Patch(ctx, objAfter, patch)
+	})
+}
+
+func (c *client) PatchStatus(ctx context.Context, objBefore, objAfter runtimeclient.Object, opts PatchOptions) error {
+	return c.patchWithRetry(ctx, objBefore, objAfter, opts, func(patch runtimeclient.Patch) error {
+		return c.Status().Patch(ctx, objAfter, patch)
+	})
+}
+
+func (c *client) patchWithRetry(ctx context.Context, objBefore, objAfter runtimeclient.Object, opts PatchOptions, send func(runtimeclient.Patch) error) error {
+	base := objBefore.DeepCopyObject().(runtimeclient.Object)
+	// The changes made to objBefore, reapplied on top of the latest version of
+	// the object on conflicts
+	var changes []byte
+
+	return RetryOnConflict(DefaultRetry, func() error {
+		patch, err := NewPatch(base, opts)
+		if err != nil {
+			return err
+		}
+
+		err = send(patch)
+		if !IsConflict(err) {
+			return err
+		}
+
+		if changes == nil {
+			var diffErr error
+			if changes, diffErr = mergeChanges(objBefore, objAfter); diffErr != nil {
+				return diffErr
+			}
+		}
+		latest := objBefore.DeepCopyObject().(runtimeclient.Object)
+		if reloadErr := c.Reload(ctx, latest); reloadErr != nil {
+			return reloadErr
+		}
+		if rebaseErr := applyChanges(latest, changes, objAfter); rebaseErr != nil {
+			return rebaseErr
+		}
+		base = latest
+		return err
+	})
+}
+
+// mergeChanges computes a JSON merge patch with the changes made to objBefore.
+func mergeChanges(objBefore, objAfter runtimeclient.Object) ([]byte, error) {
+	beforeJSON, err := json.Marshal(objBefore)
+	if err != nil {
+		return nil, err
+	}
+	afterJSON, err := json.Marshal(objAfter)
+	if err != nil {
+		return nil, err
+	}
+	return mergepatch.CreateMergePatch(beforeJSON, afterJSON)
+}
+
+// applyChanges sets objAfter to the latest version of the object with changes
+// applied on top of it.
+func applyChanges(latest runtimeclient.Object, changes []byte, objAfter runtimeclient.Object) error {
+	latestJSON, err := json.Marshal(latest)
+	if err != nil {
+		return err
+	}
+	rebasedJSON, err := mergepatch.MergePatch(latestJSON, changes)
+	if err != nil {
+		return errors.Wrap(err, "failed to apply changes on top of the latest version")
+	}
+
+	// Fields not in the JSON are reset by decoding into an empty object
+	rebased := reflect.New(reflect.TypeOf(objAfter).Elem())
+	if err := json.Unmarshal(rebasedJSON, rebased.Interface()); err != nil {
+		return err
+	}
+	reflect.ValueOf(objAfter).Elem().Set(rebased.Elem())
+	return nil
+}
+
+// NewPatch creates a patch with the changes made to objBefore.
+func NewPatch(objBefore runtimeclient.Object, opts PatchOptions) (runtimeclient.Patch, error) {
+	switch opts.Type {
+	case "", types.MergePatchType:
+		if opts.OptimisticLock {
+			return runtimeclient.MergeFromWithOptions(objBefore, runtimeclient.MergeFromWithOptimisticLock{}), nil
+		}
+		return runtimeclient.MergeFrom(objBefore), nil
+	case types.JSONPatchType:
+		return &jsonPatch{from: objBefore, optimisticLock: opts.OptimisticLock}, nil
+	}
+
+	return nil, fmt.Errorf("unsupported patch type '%s'", opts.Type)
+}
+
+type jsonPatch struct {
+	from           runtimeclient.Object
+	optimisticLock bool
+}
+
+func (p *jsonPatch) Type() types.PatchType {
+	return types.JSONPatchType
+}
+
+func (p *jsonPatch) Data(obj runtimeclient.Object) ([]byte, error) {
+	fromJSON, err := json.Marshal(p.from)
+	if err != nil {
+		return nil, err
+	}
+	// The resource version is only sent as a precondition
+	obj = obj.DeepCopyObject().(runtimeclient.Object)
+	obj.SetResourceVersion(p.from.GetResourceVersion())
+	objJSON, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	ops, err := jsonpatch.CreatePatch(fromJSON, objJSON)
+	if err != nil {
+		return nil, err
+	}
+
+	if p.optimisticLock {
+		version := p.from.GetResourceVersion()
+		if version == "" {
+			return nil, fmt.Errorf("cannot use OptimisticLock, object %q does not have any resource version we can use", p.from)
+		}
+		test := jsonpatch.NewOperation("test", "/metadata/resourceVersion", version)
+		ops = append([]jsonpatch.Operation{test}, ops...)
+	}
+
+	return json.Marshal(ops)
+}
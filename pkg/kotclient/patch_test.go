@@ -0,0 +1,121 @@
+package kotclient_test
+
+import (
+	"context"
+
+	"github.com/fgrehm/kot/pkg/kotclient"
+	"github.com/fgrehm/kot/pkg/kottesting/gomock"
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var _ = Describe("Patching", func() {
+	var (
+		before *corev1.ConfigMap
+		after  *corev1.ConfigMap
+	)
+
+	BeforeEach(func() {
+		before = &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "cm", ResourceVersion: "1"},
+			Data:       map[string]string{"a": "1"},
+		}
+		after = before.DeepCopy()
+		after.Data["b"] = "2"
+	})
+
+	Describe("NewPatch", func() {
+		It("creates merge patches by default", func() {
+			patch, err := kotclient.NewPatch(before, kotclient.PatchOptions{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(patch.Type()).To(Equal(types.MergePatchType))
+			Expect(patch.Data(after)).To(MatchJSON(`{"data":{"b":"2"}}`))
+		})
+
+		It("supports optimistic locking with merge patches", func() {
+			patch, err := kotclient.NewPatch(before, kotclient.PatchOptions{OptimisticLock: true})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(patch.Data(after)).To(MatchJSON(`{"data":{"b":"2"},"metadata":{"resourceVersion":"1"}}`))
+		})
+
+		It("creates json patches", func() {
+			patch, err := kotclient.NewPatch(before, kotclient.PatchOptions{Type: types.JSONPatchType})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(patch.Type()).To(Equal(types.JSONPatchType))
+			Expect(patch.Data(after)).To(MatchJSON(`[{"op":"add","path":"/data/b","value":"2"}]`))
+		})
+
+		It("supports optimistic locking with json patches", func() {
+			patch, err := kotclient.NewPatch(before, kotclient.PatchOptions{Type: types.JSONPatchType, OptimisticLock: true})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(patch.Data(after)).To(MatchJSON(`[
+				{"op":"test","path":"/metadata/resourceVersion","value":"1"},
+				{"op":"add","path":"/data/b","value":"2"}
+			]`))
+		})
+
+		It("fails for unknown patch types", func() {
+			_, err := kotclient.NewPatch(before, kotclient.PatchOptions{Type: types.StrategicMergePatchType})
+			Expect(err).To(MatchError("unsupported patch type 'application/strategic-merge-patch+json'"))
+		})
+	})
+
+	Describe("PatchObject", func() {
+		var (
+			mCtrl      *gomock.Controller
+			runtimeCli *kotmocks.MockClient
+			client     kotclient.Client
+		)
+
+		BeforeEach(func() {
+			mCtrl = gomock.NewController(GinkgoT())
+			runtimeCli = kotmocks.NewMockClient(mCtrl)
+			client = kotclient.Decorate(runtimeCli)
+		})
+
+		AfterEach(func() {
+			mCtrl.Finish()
+		})
+
+		It("retries conflicts on top of the latest version", func() {
+			conflict := kotclient.NewConflict(kotclient.GR{Resource: "configmaps"}, "cm", nil)
+			sent := []string{}
+			recordPatch := func(_ context.Context, obj runtimeclient.Object, patch runtimeclient.Patch, _ ...runtimeclient.PatchOption) {
+				data, err := patch.Data(obj)
+				Expect(err).NotTo(HaveOccurred())
+				sent = append(sent, string(data))
+			}
+
+			gomock.InOrder(
+				runtimeCli.EXPECT().Patch(gomock.Any(), after, gomock.Any()).Do(recordPatch).Return(conflict),
+				runtimeCli.EXPECT().Get(gomock.Any(), kotclient.Key{Name: "cm"}, gomock.Any()).
+					SetArg(2, corev1.ConfigMap{
+						ObjectMeta: metav1.ObjectMeta{Name: "cm", ResourceVersion: "2"},
+						Data:       map[string]string{"a": "1", "c": "3"},
+					}),
+				runtimeCli.EXPECT().Patch(gomock.Any(), after, gomock.Any()).Do(recordPatch),
+			)
+
+			Expect(client.PatchObject(context.Background(), before, after, kotclient.PatchOptions{OptimisticLock: true})).To(Succeed())
+			Expect(sent).To(HaveLen(2))
+			Expect(sent[0]).To(MatchJSON(`{"data":{"b":"2"},"metadata":{"resourceVersion":"1"}}`))
+			Expect(sent[1]).To(MatchJSON(`{"data":{"b":"2"},"metadata":{"resourceVersion":"2"}}`))
+			Expect(after.Data).To(Equal(map[string]string{"a": "1", "b": "2", "c": "3"}))
+			Expect(before.ResourceVersion).To(Equal("1"))
+		})
+
+		It("gives up on conflicts after a few retries", func() {
+			conflict := kotclient.NewConflict(kotclient.GR{Resource: "configmaps"}, "cm", nil)
+			runtimeCli.EXPECT().Patch(gomock.Any(), after, gomock.Any()).Return(conflict).Times(kotclient.DefaultRetry.Steps)
+			runtimeCli.EXPECT().Get(gomock.Any(), kotclient.Key{Name: "cm"}, gomock.Any()).Times(kotclient.DefaultRetry.Steps)
+
+			err := client.PatchObject(context.Background(), before, after, kotclient.PatchOptions{OptimisticLock: true})
+			Expect(kotclient.IsConflict(err)).To(BeTrue())
+		})
+	})
+})
@@ -0,0 +1,242 @@
+package kotclient
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+
+	"gomodules.xyz/jsonpatch/v2"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+// Operations recorded on plans, on top of the ones made by SyncList
+const (
+	PlanPatch        SyncOperation = "patch"
+	PlanStatusUpdate SyncOperation = "status-update"
+	PlanStatusPatch  SyncOperation = "status-patch"
+)
+
+// Plan collects the writes that would have been made to the API while it is
+// carried by the context of requests, none of them get persisted.
+type Plan struct {
+	// DryRun sends writes to the API with DryRun=All, so that they are
+	// validated and defaulted by the server.
+	DryRun bool
+
+	mu      sync.Mutex
+	changes []PlannedChange
+}
+
+// PlannedChange is a write that would have been made to the API.
+type PlannedChange struct {
+	Operation SyncOperation `json:"operation"`
+	GVK       string        `json:"gvk"`
+	Key       string        `json:"key"`
+	// Diff is the object being created, the patch being sent or the JSON patch
+	// that turns the current object into the updated one.
+	Diff  json.RawMessage `json:"diff,omitempty"`
+	Error string          `json:"error,omitempty"`
+}
+
+func (p *Plan) Changes() []PlannedChange {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return append([]PlannedChange{}, p.changes...)
+}
+
+func (p *Plan) record(change PlannedChange) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.changes = append(p.changes, change)
+}
+
+// planCtxKey is how we find the Plan in a context.Context
+type planCtxKey struct{}
+
+// WithPlan returns a new Context, derived from ctx, which makes writes sent
+// through a Client get recorded on plan instead of being persisted.
+func WithPlan(ctx context.Context, plan *Plan) context.Context {
+	return context.WithValue(ctx, planCtxKey{}, plan)
+}
+
+// PlanFrom returns the Plan carried by ctx, or nil if writes are persisted.
+func PlanFrom(ctx context.Context) *Plan {
+	if v, ok := ctx.Value(planCtxKey{}).(*Plan); ok {
+		return v
+	}
+	return nil
+}
+
+// planningClient records writes on the Plan carried by the context, if any.
+type planningClient struct {
+	runtimeclient.Client
+}
+
+func (c *planningClient) Create(ctx context.Context, obj runtimeclient.Object, opts ...runtimeclient.CreateOption) error {
+	plan := PlanFrom(ctx)
+	if plan == nil {
+		return c.Client.Create(ctx, obj, opts...)
+	}
+
+	var err error
+	if plan.DryRun {
+		err = c.Client.Create(ctx, obj, append(opts, runtimeclient.DryRunAll)...)
+	}
+	c.record(plan, SyncCreate, obj, marshalDiff(obj), err)
+	return err
+}
+
+func (c *planningClient) Update(ctx context.Context, obj runtimeclient.Object, opts ...runtimeclient.UpdateOption) error {
+	plan := PlanFrom(ctx)
+	if plan == nil {
+		return c.Client.Update(ctx, obj, opts...)
+	}
+
+	diff := c.updateDiff(ctx, obj)
+	var err error
+	if plan.DryRun {
+		err = c.Client.Update(ctx, obj, append(opts, runtimeclient.DryRunAll)...)
+	}
+	c.record(plan, SyncUpdate, obj, diff, err)
+	return err
+}
+
+func (c *planningClient) Patch(ctx context.Context, obj runtimeclient.Object, patch runtimeclient.Patch, opts ...runtimeclient.PatchOption) error {
+	plan := PlanFrom(ctx)
+	if plan == nil {
+		return c.Client.Patch(ctx, obj, patch, opts...)
+	}
+
+	diff, _ := patch.Data(obj)
+	var err error
+	if plan.DryRun {
+		err = c.Client.Patch(ctx, obj, patch, append(opts, runtimeclient.DryRunAll)...)
+	}
+	c.record(plan, PlanPatch, obj, rawDiff(diff), err)
+	return err
+}
+
+func (c *planningClient) Delete(ctx context.Context, obj runtimeclient.Object, opts ...runtimeclient.DeleteOption) error {
+	plan := PlanFrom(ctx)
+	if plan == nil {
+		return c.Client.Delete(ctx, obj, opts...)
+	}
+
+	var err error
+	if plan.DryRun {
+		err = c.Client.Delete(ctx, obj, append(opts, runtimeclient.DryRunAll)...)
+	}
+	c.record(plan, SyncDelete, obj, nil, err)
+	return err
+}
+
+func (c *planningClient) DeleteAllOf(ctx context.Context, obj runtimeclient.Object, opts ...runtimeclient.DeleteAllOfOption) error {
+	plan := PlanFrom(ctx)
+	if plan == nil {
+		return c.Client.DeleteAllOf(ctx, obj, opts...)
+	}
+
+	var err error
+	if plan.DryRun {
+		err = c.Client.DeleteAllOf(ctx, obj, append(opts, runtimeclient.DryRunAll)...)
+	}
+	c.record(plan, SyncDelete, obj, nil, err)
+	return err
+}
+
+func (c *planningClient) Status() runtimeclient.StatusWriter {
+	return &planningStatusWriter{StatusWriter: c.Client.Status(), client: c}
+}
+
+func (c *planningClient) updateDiff(ctx context.Context, obj runtimeclient.Object) json.RawMessage {
+	current := obj.DeepCopyObject().(runtimeclient.Object)
+	if err := c.Client.Get(ctx, runtimeclient.ObjectKeyFromObject(obj), current); err != nil {
+		return marshalDiff(obj)
+	}
+	// Reads might set or clear type information
+	current.GetObjectKind().SetGroupVersionKind(obj.GetObjectKind().GroupVersionKind())
+
+	currentJSON, err := json.Marshal(current)
+	if err != nil {
+		return nil
+	}
+	objJSON, err := json.Marshal(obj)
+	if err != nil {
+		return nil
+	}
+	ops, err := jsonpatch.CreatePatch(currentJSON, objJSON)
+	if err != nil {
+		return nil
+	}
+	return marshalDiff(ops)
+}
+
+func (c *planningClient) record(plan *Plan, op SyncOperation, obj runtimeclient.Object, diff json.RawMessage, err error) {
+	change := PlannedChange{
+		Operation: op,
+		Key:       runtimeclient.ObjectKeyFromObject(obj).String(),
+		Diff:      diff,
+	}
+	if gvk, gvkErr := apiutil.GVKForObject(obj, c.Scheme()); gvkErr == nil {
+		change.GVK = gvk.String()
+	}
+	if err != nil {
+		change.Error = err.Error()
+	}
+	plan.record(change)
+}
+
+type planningStatusWriter struct {
+	runtimeclient.StatusWriter
+	client *planningClient
+}
+
+func (w *planningStatusWriter) Update(ctx context.Context, obj runtimeclient.Object, opts ...runtimeclient.UpdateOption) error {
+	plan := PlanFrom(ctx)
+	if plan == nil {
+		return w.StatusWriter.Update(ctx, obj, opts...)
+	}
+
+	diff := w.client.updateDiff(ctx, obj)
+	var err error
+	if plan.DryRun {
+		err = w.StatusWriter.Update(ctx, obj, append(opts, runtimeclient.DryRunAll)...)
+	}
+	w.client.record(plan, PlanStatusUpdate, obj, diff, err)
+	return err
+}
+
+func (w *planningStatusWriter) Patch(ctx context.Context, obj runtimeclient.Object, patch runtimeclient.Patch, opts ...runtimeclient.PatchOption) error {
+	plan := PlanFrom(ctx)
+	if plan == nil {
+		return w.StatusWriter.Patch(ctx, obj, patch, opts...)
+	}
+
+	diff, _ := patch.Data(obj)
+	var err error
+	if plan.DryRun {
+		err = w.StatusWriter.Patch(ctx, obj, patch, append(opts, runtimeclient.DryRunAll)...)
+	}
+	w.client.record(plan, PlanStatusPatch, obj, rawDiff(diff), err)
+	return err
+}
+
+func marshalDiff(v interface{}) json.RawMessage {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	return data
+}
+
+// rawDiff keeps patches that are not JSON, like server side apply ones, as
+// strings.
+func rawDiff(data []byte) json.RawMessage {
+	if json.Valid(data) {
+		return data
+	}
+	return marshalDiff(string(data))
+}
@@ -0,0 +1,72 @@
+package kotclient_test
+
+import (
+	"context"
+
+	"github.com/fgrehm/kot/pkg/kotclient"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("Plan", func() {
+	var (
+		client kotclient.Client
+		ctx    context.Context
+		plan   *kotclient.Plan
+		cm     *corev1.ConfigMap
+	)
+
+	BeforeEach(func() {
+		client = kotclient.Decorate(testEnv.Client)
+		plan = &kotclient.Plan{}
+		ctx = kotclient.WithPlan(context.Background(), plan)
+		cm = &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "plan-test", Namespace: defaultNamespace},
+			Data:       map[string]string{"value": "original"},
+		}
+	})
+
+	AfterEach(func() {
+		Expect(kotclient.IgnoreNotFound(client.Delete(context.Background(), cm))).To(Succeed())
+	})
+
+	It("records writes without persisting them", func() {
+		Expect(client.Create(ctx, cm)).To(Succeed())
+		Expect(client.Reload(context.Background(), cm)).To(MatchError(ContainSubstring("not found")))
+
+		changes := plan.Changes()
+		Expect(changes).To(HaveLen(1))
+		Expect(changes[0].Operation).To(Equal(kotclient.SyncCreate))
+		Expect(changes[0].GVK).To(Equal("/v1, Kind=ConfigMap"))
+		Expect(changes[0].Key).To(Equal(defaultNamespace + "/plan-test"))
+		Expect(string(changes[0].Diff)).To(ContainSubstring(`"value":"original"`))
+	})
+
+	It("records the changes made by updates", func() {
+		Expect(client.Create(context.Background(), cm)).To(Succeed())
+
+		updated := cm.DeepCopy()
+		updated.Data["value"] = "updated"
+		Expect(client.Update(ctx, updated)).To(Succeed())
+		Expect(client.PatchObject(ctx, cm, updated, kotclient.PatchOptions{})).To(Succeed())
+
+		Expect(client.Reload(context.Background(), cm)).To(Succeed())
+		Expect(cm.Data["value"]).To(Equal("original"))
+
+		changes := plan.Changes()
+		Expect(changes).To(HaveLen(2))
+		Expect(string(changes[0].Diff)).To(Equal(`[{"op":"replace","path":"/data/value","value":"updated"}]`))
+		Expect(changes[1].Operation).To(Equal(kotclient.PlanPatch))
+		Expect(string(changes[1].Diff)).To(Equal(`{"data":{"value":"updated"}}`))
+	})
+
+	It("sends writes with DryRun=All if enabled", func() {
+		plan.DryRun = true
+		Expect(client.Create(ctx, cm)).To(Succeed())
+		Expect(client.Reload(context.Background(), cm.DeepCopy())).To(MatchError(ContainSubstring("not found")))
+		Expect(plan.Changes()).To(HaveLen(1))
+		Expect(plan.Changes()[0].Error).To(BeEmpty())
+	})
+})
@@ -0,0 +1,93 @@
+package kotclient
+
+import (
+	"fmt"
+	"strings"
+
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Reasons for SyncList to leave an object alone
+const (
+	SkippedUnchanged    = "unchanged"
+	SkippedBeingDeleted = "being deleted"
+)
+
+// SyncListEntry describes what SyncList did with an object.
+type SyncListEntry struct {
+	Operation SyncOperation
+	Key       Key
+	// Reason is set for skipped objects
+	Reason string
+	// Drift is set for updated objects
+	Drift Drift
+	// Err is set for failed operations
+	Err error
+}
+
+func (e SyncListEntry) String() string {
+	key := e.Key.Name
+	if e.Key.Namespace != "" {
+		key = e.Key.String()
+	}
+	switch {
+	case e.Err != nil:
+		return fmt.Sprintf("failed to %s %s: %s", e.Operation, key, e.Err)
+	case e.Reason != "":
+		return fmt.Sprintf("skipped %s: %s", key, e.Reason)
+	default:
+		return fmt.Sprintf("%s %s", e.Operation, key)
+	}
+}
+
+// SyncListReport lists the objects created, updated, deleted or skipped by
+// SyncList, along with the ones that failed to be written.
+type SyncListReport struct {
+	Created []SyncListEntry
+	Updated []SyncListEntry
+	Deleted []SyncListEntry
+	Skipped []SyncListEntry
+	Failed  []SyncListEntry
+}
+
+// Changed tells if any object was written.
+func (r *SyncListReport) Changed() bool {
+	return len(r.Created)+len(r.Updated)+len(r.Deleted) > 0
+}
+
+// Err aggregates the errors of failed operations, nil if there are none.
+func (r *SyncListReport) Err() error {
+	if len(r.Failed) == 0 {
+		return nil
+	}
+
+	errs := make([]string, len(r.Failed))
+	for i, entry := range r.Failed {
+		errs[i] = entry.String()
+	}
+	return fmt.Errorf(`one or more errors occurred: ["%s"]`, strings.Join(errs, `", "`))
+}
+
+func (r *SyncListReport) record(op SyncOperation, obj runtimeclient.Object, err error) {
+	r.add(SyncListEntry{Operation: op, Key: runtimeclient.ObjectKeyFromObject(obj), Err: err})
+}
+
+func (r *SyncListReport) add(entry SyncListEntry) {
+	if entry.Err != nil {
+		r.Failed = append(r.Failed, entry)
+		return
+	}
+
+	switch entry.Operation {
+	case SyncCreate:
+		r.Created = append(r.Created, entry)
+	case SyncUpdate:
+		r.Updated = append(r.Updated, entry)
+	case SyncDelete:
+		r.Deleted = append(r.Deleted, entry)
+	}
+}
+
+func (r *SyncListReport) skipped(obj runtimeclient.Object, reason string) {
+	r.Skipped = append(r.Skipped, SyncListEntry{Key: runtimeclient.ObjectKeyFromObject(obj), Reason: reason})
+}
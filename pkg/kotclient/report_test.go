@@ -0,0 +1,34 @@
+package kotclient_test
+
+import (
+	"errors"
+
+	"github.com/fgrehm/kot/pkg/kotclient"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("SyncListReport", func() {
+	It("describes entries", func() {
+		Expect(kotclient.SyncListEntry{Operation: kotclient.SyncCreate, Key: kotclient.Key{Namespace: "ns", Name: "a"}}.String()).
+			To(Equal("create ns/a"))
+		Expect(kotclient.SyncListEntry{Key: kotclient.Key{Name: "a"}, Reason: kotclient.SkippedUnchanged}.String()).
+			To(Equal("skipped a: unchanged"))
+		Expect(kotclient.SyncListEntry{Operation: kotclient.SyncDelete, Key: kotclient.Key{Name: "a"}, Err: errors.New("boom")}.String()).
+			To(Equal("failed to delete a: boom"))
+	})
+
+	It("aggregates errors of failed entries", func() {
+		report := &kotclient.SyncListReport{}
+		Expect(report.Err()).NotTo(HaveOccurred())
+		Expect(report.Changed()).To(BeFalse())
+
+		report.Updated = []kotclient.SyncListEntry{{Operation: kotclient.SyncUpdate, Key: kotclient.Key{Name: "a"}}}
+		report.Failed = []kotclient.SyncListEntry{
+			{Operation: kotclient.SyncCreate, Key: kotclient.Key{Name: "b"}, Err: errors.New("boom")},
+			{Operation: kotclient.SyncDelete, Key: kotclient.Key{Name: "c"}, Err: errors.New("bam")},
+		}
+		Expect(report.Changed()).To(BeTrue())
+		Expect(report.Err()).To(MatchError(`one or more errors occurred: ["failed to create b: boom", "failed to delete c: bam"]`))
+	})
+})
@@ -0,0 +1,90 @@
+package kotclient
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const tracerName = "github.com/fgrehm/kot/pkg/kotclient"
+
+// tracingClient creates a span for every request made to the API.
+type tracingClient struct {
+	runtimeclient.Client
+}
+
+func (c *tracingClient) Get(ctx context.Context, key Key, obj runtimeclient.Object) error {
+	ctx, span := startSpan(ctx, "Get", obj, attribute.String("kot.object.key", key.String()))
+	return endSpan(span, c.Client.Get(ctx, key, obj))
+}
+
+func (c *tracingClient) List(ctx context.Context, list runtimeclient.ObjectList, opts ...runtimeclient.ListOption) error {
+	ctx, span := startSpan(ctx, "List", list)
+	return endSpan(span, c.Client.List(ctx, list, opts...))
+}
+
+func (c *tracingClient) Create(ctx context.Context, obj runtimeclient.Object, opts ...runtimeclient.CreateOption) error {
+	ctx, span := startSpan(ctx, "Create", obj, objectKey(obj))
+	return endSpan(span, c.Client.Create(ctx, obj, opts...))
+}
+
+func (c *tracingClient) Update(ctx context.Context, obj runtimeclient.Object, opts ...runtimeclient.UpdateOption) error {
+	ctx, span := startSpan(ctx, "Update", obj, objectKey(obj))
+	return endSpan(span, c.Client.Update(ctx, obj, opts...))
+}
+
+func (c *tracingClient) Patch(ctx context.Context, obj runtimeclient.Object, patch runtimeclient.Patch, opts ...runtimeclient.PatchOption) error {
+	ctx, span := startSpan(ctx, "Patch", obj, objectKey(obj), attribute.String("kot.patch.type", string(patch.Type())))
+	return endSpan(span, c.Client.Patch(ctx, obj, patch, opts...))
+}
+
+func (c *tracingClient) Delete(ctx context.Context, obj runtimeclient.Object, opts ...runtimeclient.DeleteOption) error {
+	ctx, span := startSpan(ctx, "Delete", obj, objectKey(obj))
+	return endSpan(span, c.Client.Delete(ctx, obj, opts...))
+}
+
+func (c *tracingClient) DeleteAllOf(ctx context.Context, obj runtimeclient.Object, opts ...runtimeclient.DeleteAllOfOption) error {
+	ctx, span := startSpan(ctx, "DeleteAllOf", obj)
+	return endSpan(span, c.Client.DeleteAllOf(ctx, obj, opts...))
+}
+
+func (c *tracingClient) Status() runtimeclient.StatusWriter {
+	return &tracingStatusWriter{c.Client.Status()}
+}
+
+type tracingStatusWriter struct {
+	runtimeclient.StatusWriter
+}
+
+func (w *tracingStatusWriter) Update(ctx context.Context, obj runtimeclient.Object, opts ...runtimeclient.UpdateOption) error {
+	ctx, span := startSpan(ctx, "Status.Update", obj, objectKey(obj))
+	return endSpan(span, w.StatusWriter.Update(ctx, obj, opts...))
+}
+
+func (w *tracingStatusWriter) Patch(ctx context.Context, obj runtimeclient.Object, patch runtimeclient.Patch, opts ...runtimeclient.PatchOption) error {
+	ctx, span := startSpan(ctx, "Status.Patch", obj, objectKey(obj), attribute.String("kot.patch.type", string(patch.Type())))
+	return endSpan(span, w.StatusWriter.Patch(ctx, obj, patch, opts...))
+}
+
+func startSpan(ctx context.Context, operation string, obj interface{}, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	attrs = append(attrs, attribute.String("kot.object.type", fmt.Sprintf("%T", obj)))
+	return otel.Tracer(tracerName).Start(ctx, fmt.Sprintf("kotclient.%s", operation), trace.WithAttributes(attrs...))
+}
+
+func endSpan(span trace.Span, err error) error {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+	return err
+}
+
+func objectKey(obj runtimeclient.Object) attribute.KeyValue {
+	return attribute.String("kot.object.key", runtimeclient.ObjectKeyFromObject(obj).String())
+}
@@ -3,7 +3,6 @@ package kottesting
 import (
 	"context"
 	"errors"
-	"fmt"
 	"time"
 
 	"github.com/fgrehm/kot/pkg/indexing"
@@ -74,10 +73,5 @@ func (c *TestClient) CountChildrenResources(ctx context.Context, owner runtimecl
 }
 
 func (c *TestClient) newObjectList(gvk kotclient.GVK) (runtimeclient.ObjectList, error) {
-	gvk.Kind = fmt.Sprintf("%sList", gvk.Kind)
-	list, err := c.Scheme.New(gvk)
-	if err != nil {
-		return nil, err
-	}
-	return list.(runtimeclient.ObjectList), nil
+	return kotclient.NewObjectList(c.Scheme, gvk)
 }
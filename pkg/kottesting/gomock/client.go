@@ -38,6 +38,20 @@ func (m *MockClient) EXPECT() *MockClientMockRecorder {
 	return m.recorder
 }
 
+// Apply mocks base method.
+func (m *MockClient) Apply(ctx context.Context, obj client.Object, opts kotclient.ApplyOptions) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Apply", ctx, obj, opts)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Apply indicates an expected call of Apply.
+func (mr *MockClientMockRecorder) Apply(ctx, obj, opts interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Apply", reflect.TypeOf((*MockClient)(nil).Apply), ctx, obj, opts)
+}
+
 // Create mocks base method.
 func (m *MockClient) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
 	m.ctrl.T.Helper()
@@ -147,6 +161,34 @@ func (mr *MockClientMockRecorder) Patch(ctx, obj, patch interface{}, opts ...int
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Patch", reflect.TypeOf((*MockClient)(nil).Patch), varargs...)
 }
 
+// PatchObject mocks base method.
+func (m *MockClient) PatchObject(ctx context.Context, objBefore, objAfter client.Object, opts kotclient.PatchOptions) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PatchObject", ctx, objBefore, objAfter, opts)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// PatchObject indicates an expected call of PatchObject.
+func (mr *MockClientMockRecorder) PatchObject(ctx, objBefore, objAfter, opts interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PatchObject", reflect.TypeOf((*MockClient)(nil).PatchObject), ctx, objBefore, objAfter, opts)
+}
+
+// PatchStatus mocks base method.
+func (m *MockClient) PatchStatus(ctx context.Context, objBefore, objAfter client.Object, opts kotclient.PatchOptions) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PatchStatus", ctx, objBefore, objAfter, opts)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// PatchStatus indicates an expected call of PatchStatus.
+func (mr *MockClientMockRecorder) PatchStatus(ctx, objBefore, objAfter, opts interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PatchStatus", reflect.TypeOf((*MockClient)(nil).PatchStatus), ctx, objBefore, objAfter, opts)
+}
+
 // RESTMapper mocks base method.
 func (m *MockClient) RESTMapper() meta.RESTMapper {
 	m.ctrl.T.Helper()
@@ -204,17 +246,23 @@ func (mr *MockClientMockRecorder) Status() *gomock.Call {
 }
 
 // SyncList mocks base method.
-func (m *MockClient) SyncList(ctx context.Context, listBefore, listAfter client.ObjectList, processor kotclient.ListSyncProcessFunc) error {
+func (m *MockClient) SyncList(ctx context.Context, listBefore, listAfter client.ObjectList, processor kotclient.ListSyncProcessFunc, opts ...kotclient.SyncListOption) (*kotclient.SyncListReport, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "SyncList", ctx, listBefore, listAfter, processor)
-	ret0, _ := ret[0].(error)
-	return ret0
+	varargs := []interface{}{ctx, listBefore, listAfter, processor}
+	for _, a := range opts {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "SyncList", varargs...)
+	ret0, _ := ret[0].(*kotclient.SyncListReport)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
 }
 
 // SyncList indicates an expected call of SyncList.
-func (mr *MockClientMockRecorder) SyncList(ctx, listBefore, listAfter, processor interface{}) *gomock.Call {
+func (mr *MockClientMockRecorder) SyncList(ctx, listBefore, listAfter, processor interface{}, opts ...interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SyncList", reflect.TypeOf((*MockClient)(nil).SyncList), ctx, listBefore, listAfter, processor)
+	varargs := append([]interface{}{ctx, listBefore, listAfter, processor}, opts...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SyncList", reflect.TypeOf((*MockClient)(nil).SyncList), varargs...)
 }
 
 // Update mocks base method.
@@ -6,13 +6,15 @@ import (
 	"github.com/golang/mock/gomock"
 	apiruntime "k8s.io/apimachinery/pkg/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 )
 
 type MockedEnv struct {
-	Scheme  *apiruntime.Scheme
-	Client  *MockClient
-	Manager *MockManager
+	Scheme   *apiruntime.Scheme
+	Client   *MockClient
+	Manager  *MockManager
+	Recorder *record.FakeRecorder
 }
 
 func NewEnv(mCtrl *gomock.Controller, logOutput io.Writer) MockedEnv {
@@ -27,8 +29,11 @@ func NewEnv(mCtrl *gomock.Controller, logOutput io.Writer) MockedEnv {
 	mgr.EXPECT().GetScheme().Return(scheme).AnyTimes()
 	mgr.EXPECT().GetClient().Return(client).AnyTimes()
 
+	recorder := record.NewFakeRecorder(100)
+	mgr.EXPECT().GetEventRecorderFor(gomock.Any()).Return(recorder).AnyTimes()
+
 	logger := zap.New(zap.WriteTo(logOutput), zap.UseDevMode(true))
 	mgr.EXPECT().GetLogger().Return(logger).AnyTimes()
 
-	return MockedEnv{scheme, client, mgr}
+	return MockedEnv{scheme, client, mgr, recorder}
 }
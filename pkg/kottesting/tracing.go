@@ -0,0 +1,44 @@
+package kottesting
+
+import (
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// SpanRecorder keeps spans in memory so that tests can assert on traces.
+type SpanRecorder struct {
+	*tracetest.SpanRecorder
+}
+
+// RecordSpans sets the global tracer provider to one that keeps ended spans in
+// memory until Stop is called.
+func RecordSpans() *SpanRecorder {
+	recorder := &SpanRecorder{tracetest.NewSpanRecorder()}
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder.SpanRecorder)))
+	return recorder
+}
+
+// SpanNames returns the names of the spans that ended, in the order they ended.
+func (r *SpanRecorder) SpanNames() []string {
+	names := []string{}
+	for _, span := range r.Ended() {
+		names = append(names, span.Name())
+	}
+	return names
+}
+
+// FindSpan returns the first span that ended with the given name, if any.
+func (r *SpanRecorder) FindSpan(name string) sdktrace.ReadOnlySpan {
+	for _, span := range r.Ended() {
+		if span.Name() == name {
+			return span
+		}
+	}
+	return nil
+}
+
+func (r *SpanRecorder) Stop() {
+	otel.SetTracerProvider(trace.NewNoopTracerProvider())
+}
@@ -0,0 +1,136 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const (
+	ResultSuccess = "success"
+	ResultError   = "error"
+)
+
+var (
+	// ReconcileDuration is a histogram of how long each controller takes to
+	// reconcile a resource.
+	ReconcileDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "kot_reconcile_duration_seconds",
+		Help: "Duration of a reconciliation pass per controller",
+	}, []string{"controller"})
+
+	// ReconcileErrors is a counter of reconciliation passes that failed.
+	ReconcileErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "kot_reconcile_errors_total",
+		Help: "Total number of reconciliation errors per controller",
+	}, []string{"controller"})
+
+	// ReconcilerDuration is a histogram of how long each reconciler takes to run.
+	ReconcilerDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "kot_reconciler_duration_seconds",
+		Help: "Duration of reconciler runs per controller and reconciler",
+	}, []string{"controller", "reconciler"})
+
+	// ReconcilerErrors is a counter of reconciler runs that failed.
+	ReconcilerErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "kot_reconciler_errors_total",
+		Help: "Total number of errors per controller and reconciler",
+	}, []string{"controller", "reconciler"})
+
+	// ChildOperations is a counter of writes made to child objects.
+	ChildOperations = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "kot_child_operations_total",
+		Help: "Total number of child object writes per controller, GVK, operation and result",
+	}, []string{"controller", "group", "version", "kind", "operation", "result"})
+
+	// FinalizerDuration is a histogram of how long finalizers take to run.
+	FinalizerDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "kot_finalizer_duration_seconds",
+		Help: "Duration of finalizer runs per controller",
+	}, []string{"controller", "result"})
+
+	// StatusUpdates is a counter of status writes.
+	StatusUpdates = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "kot_status_updates_total",
+		Help: "Total number of status updates per controller and result",
+	}, []string{"controller", "result"})
+
+	// WatcherEnqueueErrors is a counter of watched objects that failed to be
+	// mapped to the resources to enqueue.
+	WatcherEnqueueErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "kot_watcher_enqueue_errors_total",
+		Help: "Total number of enqueue errors per controller and watched GVK",
+	}, []string{"controller", "group", "version", "kind"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(
+		ReconcileDuration,
+		ReconcileErrors,
+		ReconcilerDuration,
+		ReconcilerErrors,
+		ChildOperations,
+		FinalizerDuration,
+		StatusUpdates,
+		WatcherEnqueueErrors,
+	)
+}
+
+// controllerCtxKey is how we find the controller name in a context.Context
+type controllerCtxKey struct{}
+
+// WithController returns a new Context, derived from ctx, which carries the
+// name of the controller used to label metrics.
+func WithController(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, controllerCtxKey{}, name)
+}
+
+// ControllerFrom returns the controller name carried by ctx, if any.
+func ControllerFrom(ctx context.Context) string {
+	if v, ok := ctx.Value(controllerCtxKey{}).(string); ok {
+		return v
+	}
+	return ""
+}
+
+func ObserveReconcile(ctx context.Context, start time.Time, err error) {
+	controller := ControllerFrom(ctx)
+	ReconcileDuration.WithLabelValues(controller).Observe(time.Since(start).Seconds())
+	if err != nil {
+		ReconcileErrors.WithLabelValues(controller).Inc()
+	}
+}
+
+func ObserveReconciler(ctx context.Context, reconciler string, start time.Time, err error) {
+	controller := ControllerFrom(ctx)
+	ReconcilerDuration.WithLabelValues(controller, reconciler).Observe(time.Since(start).Seconds())
+	if err != nil {
+		ReconcilerErrors.WithLabelValues(controller, reconciler).Inc()
+	}
+}
+
+func ObserveChildOperation(ctx context.Context, gvk schema.GroupVersionKind, operation string, err error) {
+	ChildOperations.WithLabelValues(ControllerFrom(ctx), gvk.Group, gvk.Version, gvk.Kind, operation, result(err)).Inc()
+}
+
+func ObserveFinalize(ctx context.Context, start time.Time, err error) {
+	FinalizerDuration.WithLabelValues(ControllerFrom(ctx), result(err)).Observe(time.Since(start).Seconds())
+}
+
+func ObserveStatusUpdate(ctx context.Context, err error) {
+	StatusUpdates.WithLabelValues(ControllerFrom(ctx), result(err)).Inc()
+}
+
+func ObserveEnqueueError(ctx context.Context, gvk schema.GroupVersionKind) {
+	WatcherEnqueueErrors.WithLabelValues(ControllerFrom(ctx), gvk.Group, gvk.Version, gvk.Kind).Inc()
+}
+
+func result(err error) string {
+	if err != nil {
+		return ResultError
+	}
+	return ResultSuccess
+}
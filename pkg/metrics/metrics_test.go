@@ -0,0 +1,58 @@
+package metrics_test
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/fgrehm/kot/pkg/metrics"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	corev1 "k8s.io/api/core/v1"
+)
+
+var _ = Describe("Metrics", func() {
+	var ctx context.Context
+
+	BeforeEach(func() {
+		ctx = metrics.WithController(context.Background(), "metrics-test")
+	})
+
+	It("carries the controller name on the context", func() {
+		Expect(metrics.ControllerFrom(ctx)).To(Equal("metrics-test"))
+		Expect(metrics.ControllerFrom(context.Background())).To(Equal(""))
+	})
+
+	It("observes reconciliation passes", func() {
+		metrics.ObserveReconcile(ctx, time.Now(), nil)
+		metrics.ObserveReconcile(ctx, time.Now(), errors.New("boom"))
+
+		Expect(testutil.CollectAndCount(metrics.ReconcileDuration, "kot_reconcile_duration_seconds")).To(BeNumerically(">=", 1))
+		Expect(testutil.ToFloat64(metrics.ReconcileErrors.WithLabelValues("metrics-test"))).To(Equal(1.0))
+	})
+
+	It("observes reconcilers", func() {
+		metrics.ObserveReconciler(ctx, "ConfigMap", time.Now(), errors.New("boom"))
+		metrics.ObserveReconciler(ctx, "Secret", time.Now(), nil)
+
+		Expect(testutil.ToFloat64(metrics.ReconcilerErrors.WithLabelValues("metrics-test", "ConfigMap"))).To(Equal(1.0))
+		Expect(testutil.ToFloat64(metrics.ReconcilerErrors.WithLabelValues("metrics-test", "Secret"))).To(Equal(0.0))
+	})
+
+	It("counts child operations by GVK", func() {
+		gvk := corev1.SchemeGroupVersion.WithKind("ConfigMap")
+		metrics.ObserveChildOperation(ctx, gvk, "create", nil)
+		metrics.ObserveChildOperation(ctx, gvk, "create", nil)
+		metrics.ObserveChildOperation(ctx, gvk, "delete", errors.New("boom"))
+
+		Expect(testutil.ToFloat64(metrics.ChildOperations.WithLabelValues("metrics-test", "", "v1", "ConfigMap", "create", metrics.ResultSuccess))).To(Equal(2.0))
+		Expect(testutil.ToFloat64(metrics.ChildOperations.WithLabelValues("metrics-test", "", "v1", "ConfigMap", "delete", metrics.ResultError))).To(Equal(1.0))
+	})
+
+	It("counts status updates", func() {
+		metrics.ObserveStatusUpdate(ctx, nil)
+
+		Expect(testutil.ToFloat64(metrics.StatusUpdates.WithLabelValues("metrics-test", metrics.ResultSuccess))).To(Equal(1.0))
+	})
+})
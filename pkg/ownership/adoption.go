@@ -0,0 +1,56 @@
+package ownership
+
+import (
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// AdoptAnnotation marks existing objects that can be adopted by reconcilers
+// using the AdoptIfAnnotated policy.
+const AdoptAnnotation = "kot.io/adopt"
+
+// AdoptionPolicy tells if reconcilers take over existing objects that have the
+// name of a child they are about to create.
+type AdoptionPolicy string
+
+const (
+	// AdoptNever leaves existing objects alone, creating the child fails.
+	AdoptNever AdoptionPolicy = "Never"
+	// AdoptIfUnowned adopts existing objects that are not owned by anyone.
+	AdoptIfUnowned AdoptionPolicy = "IfUnowned"
+	// AdoptIfAnnotated adopts existing objects that are not owned by anyone
+	// and have the AdoptAnnotation set to "true".
+	AdoptIfAnnotated AdoptionPolicy = "IfAnnotated"
+)
+
+// Enabled tells if existing objects should be looked up at all.
+func (p AdoptionPolicy) Enabled() bool {
+	return p != "" && p != AdoptNever
+}
+
+// CheckAdoption returns an error if obj can't be adopted by owner under the
+// given policy.
+func CheckAdoption(policy AdoptionPolicy, owner, obj runtimeclient.Object) error {
+	key := obj.GetName()
+	if obj.GetNamespace() != "" {
+		key = runtimeclient.ObjectKeyFromObject(obj).String()
+	}
+	if !policy.Enabled() {
+		return fmt.Errorf("refusing to adopt %s: adoption is disabled", key)
+	}
+
+	if ref := metav1.GetControllerOf(obj); ref != nil && ref.UID != owner.GetUID() {
+		return fmt.Errorf("refusing to adopt %s: it is controlled by %s %s", key, ref.Kind, ref.Name)
+	}
+	if ref := OwnerOf(obj); ref != nil && ref.UID != owner.GetUID() {
+		return fmt.Errorf("refusing to adopt %s: it is owned by %s %s", key, ref.GVK.Kind, ref.Key)
+	}
+
+	if policy == AdoptIfAnnotated && obj.GetAnnotations()[AdoptAnnotation] != "true" {
+		return fmt.Errorf("refusing to adopt %s: it is missing the %s annotation", key, AdoptAnnotation)
+	}
+
+	return nil
+}
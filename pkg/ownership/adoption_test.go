@@ -0,0 +1,61 @@
+package ownership_test
+
+import (
+	"github.com/fgrehm/kot/pkg/ownership"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/utils/pointer"
+)
+
+var _ = Describe("CheckAdoption", func() {
+	var (
+		owner *corev1.ServiceAccount
+		obj   *corev1.ConfigMap
+	)
+
+	BeforeEach(func() {
+		owner = &corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: "sa", Namespace: "ns", UID: "owner-uid"}}
+		obj = &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "cm", Namespace: "ns"}}
+	})
+
+	It("refuses to adopt if adoption is disabled", func() {
+		Expect(ownership.CheckAdoption("", owner, obj)).To(MatchError("refusing to adopt ns/cm: adoption is disabled"))
+		Expect(ownership.CheckAdoption(ownership.AdoptNever, owner, obj)).To(HaveOccurred())
+	})
+
+	It("adopts unowned objects", func() {
+		Expect(ownership.CheckAdoption(ownership.AdoptIfUnowned, owner, obj)).To(Succeed())
+	})
+
+	It("adopts objects that are already owned by the same owner", func() {
+		Expect(ownership.Annotations.SetOwner(owner, obj, scheme.Scheme)).To(Succeed())
+		Expect(ownership.CheckAdoption(ownership.AdoptIfUnowned, owner, obj)).To(Succeed())
+	})
+
+	It("refuses to adopt objects controlled by someone else", func() {
+		obj.OwnerReferences = []metav1.OwnerReference{{
+			APIVersion: "apps/v1",
+			Kind:       "Deployment",
+			Name:       "other",
+			UID:        "other-uid",
+			Controller: pointer.Bool(true),
+		}}
+		Expect(ownership.CheckAdoption(ownership.AdoptIfUnowned, owner, obj)).To(MatchError("refusing to adopt ns/cm: it is controlled by Deployment other"))
+	})
+
+	It("refuses to adopt objects owned through labels by someone else", func() {
+		other := &corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "ns", UID: "other-uid"}}
+		Expect(ownership.Labels.SetOwner(other, obj, scheme.Scheme)).To(Succeed())
+		Expect(ownership.CheckAdoption(ownership.AdoptIfUnowned, owner, obj)).To(MatchError("refusing to adopt ns/cm: it is owned by ServiceAccount ns/other"))
+	})
+
+	It("requires the adopt annotation if configured", func() {
+		Expect(ownership.CheckAdoption(ownership.AdoptIfAnnotated, owner, obj)).To(MatchError("refusing to adopt ns/cm: it is missing the kot.io/adopt annotation"))
+
+		obj.Annotations = map[string]string{ownership.AdoptAnnotation: "true"}
+		Expect(ownership.CheckAdoption(ownership.AdoptIfAnnotated, owner, obj)).To(Succeed())
+	})
+})
@@ -0,0 +1,163 @@
+package ownership
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/fgrehm/kot/pkg/kotclient"
+	apiruntime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	runtimehandler "sigs.k8s.io/controller-runtime/pkg/handler"
+	runtimereconcile "sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const (
+	OwnerUIDKey = "kot.io/owner-uid"
+	OwnerGVKKey = "kot.io/owner-gvk"
+	OwnerKeyKey = "kot.io/owner-key"
+)
+
+// Strategy marks child objects as owned by the resource being reconciled.
+type Strategy interface {
+	SetOwner(owner, obj runtimeclient.Object, scheme *apiruntime.Scheme) error
+	// GarbageCollected tells if Kubernetes deletes owned objects along with
+	// their owner, otherwise they are deleted by the kot finalizer.
+	GarbageCollected() bool
+}
+
+var (
+	// OwnerReferences sets a controller reference on children, which have to
+	// live on the same namespace of a namespaced owner.
+	OwnerReferences Strategy = ownerReferences{}
+	// Labels sets the UID of the owner as a label and its GVK and key as
+	// annotations, children can live anywhere.
+	Labels Strategy = metadata{uidOnLabel: true}
+	// Annotations sets the UID, GVK and key of the owner as annotations,
+	// children can live anywhere.
+	Annotations Strategy = metadata{}
+)
+
+// Default returns the strategy if set, falling back to owner references.
+func Default(strategy Strategy) Strategy {
+	if strategy == nil {
+		return OwnerReferences
+	}
+	return strategy
+}
+
+type ownerReferences struct{}
+
+func (ownerReferences) SetOwner(owner, obj runtimeclient.Object, scheme *apiruntime.Scheme) error {
+	return ctrl.SetControllerReference(owner, obj, scheme)
+}
+
+func (ownerReferences) GarbageCollected() bool {
+	return true
+}
+
+type metadata struct {
+	uidOnLabel bool
+}
+
+func (s metadata) SetOwner(owner, obj runtimeclient.Object, scheme *apiruntime.Scheme) error {
+	gvk, err := apiutil.GVKForObject(owner, scheme)
+	if err != nil {
+		return err
+	}
+
+	if ref := OwnerOf(obj); ref != nil && ref.UID != owner.GetUID() {
+		return fmt.Errorf("object is already owned by %s %s", ref.GVK.Kind, ref.Key)
+	}
+
+	annotations := obj.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	if s.uidOnLabel {
+		labels := obj.GetLabels()
+		if labels == nil {
+			labels = map[string]string{}
+		}
+		labels[OwnerUIDKey] = string(owner.GetUID())
+		obj.SetLabels(labels)
+	} else {
+		annotations[OwnerUIDKey] = string(owner.GetUID())
+	}
+	annotations[OwnerGVKKey] = formatGVK(gvk)
+	annotations[OwnerKeyKey] = runtimeclient.ObjectKeyFromObject(owner).String()
+	obj.SetAnnotations(annotations)
+
+	return nil
+}
+
+func (metadata) GarbageCollected() bool {
+	return false
+}
+
+// Ref identifies the owner of an object.
+type Ref struct {
+	UID types.UID
+	GVK kotclient.GVK
+	Key kotclient.Key
+}
+
+// OwnerOf returns the owner recorded on the labels or annotations of obj, nil
+// if there is none.
+func OwnerOf(obj runtimeclient.Object) *Ref {
+	uid := obj.GetLabels()[OwnerUIDKey]
+	if uid == "" {
+		uid = obj.GetAnnotations()[OwnerUIDKey]
+	}
+	if uid == "" {
+		return nil
+	}
+
+	annotations := obj.GetAnnotations()
+	gvk, ok := parseGVK(annotations[OwnerGVKKey])
+	if !ok {
+		return nil
+	}
+
+	key := kotclient.Key{}
+	if namespace, name, found := strings.Cut(annotations[OwnerKeyKey], "/"); found {
+		key.Namespace, key.Name = namespace, name
+	} else {
+		key.Name = namespace
+	}
+
+	return &Ref{UID: types.UID(uid), GVK: gvk, Key: key}
+}
+
+// EnqueueOwner enqueues the owners of objects that have been marked with a
+// Labels or Annotations strategy, if they are of the given GVK.
+func EnqueueOwner(ownerGVK kotclient.GVK) runtimehandler.EventHandler {
+	return runtimehandler.EnqueueRequestsFromMapFunc(func(obj runtimeclient.Object) []runtimereconcile.Request {
+		ref := OwnerOf(obj)
+		if ref == nil || ref.GVK != ownerGVK {
+			return []runtimereconcile.Request{}
+		}
+		return []runtimereconcile.Request{{NamespacedName: ref.Key}}
+	})
+}
+
+// formatGVK encodes a GVK as <apiVersion>/<kind>, like "v1/Namespace"
+func formatGVK(gvk kotclient.GVK) string {
+	apiVersion, kind := gvk.ToAPIVersionAndKind()
+	return fmt.Sprintf("%s/%s", apiVersion, kind)
+}
+
+func parseGVK(value string) (kotclient.GVK, bool) {
+	i := strings.LastIndex(value, "/")
+	if i <= 0 || i == len(value)-1 {
+		return kotclient.GVK{}, false
+	}
+	gv, err := schema.ParseGroupVersion(value[:i])
+	if err != nil {
+		return kotclient.GVK{}, false
+	}
+	return gv.WithKind(value[i+1:]), true
+}
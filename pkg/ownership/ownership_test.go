@@ -0,0 +1,116 @@
+package ownership_test
+
+import (
+	"github.com/fgrehm/kot/pkg/kotclient"
+	"github.com/fgrehm/kot/pkg/ownership"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/util/workqueue"
+	runtimeevent "sigs.k8s.io/controller-runtime/pkg/event"
+	runtimereconcile "sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+var _ = Describe("Ownership", func() {
+	var (
+		owner *corev1.ServiceAccount
+		child *corev1.Namespace
+	)
+
+	BeforeEach(func() {
+		owner = &corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: "sa", Namespace: "ns", UID: "owner-uid"}}
+		child = &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "child"}}
+	})
+
+	Describe("OwnerReferences", func() {
+		It("sets a controller reference", func() {
+			cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "cm", Namespace: "ns"}}
+			Expect(ownership.OwnerReferences.SetOwner(owner, cm, scheme.Scheme)).To(Succeed())
+			Expect(metav1.GetControllerOf(cm).UID).To(Equal(owner.UID))
+			Expect(ownership.OwnerReferences.GarbageCollected()).To(BeTrue())
+		})
+
+		It("fails for cluster-scoped children of namespaced owners", func() {
+			err := ownership.OwnerReferences.SetOwner(owner, child, scheme.Scheme)
+			Expect(err).To(MatchError(ContainSubstring("cluster-scoped resource must not have a namespace-scoped owner")))
+		})
+
+		It("is the default strategy", func() {
+			Expect(ownership.Default(nil)).To(Equal(ownership.OwnerReferences))
+			Expect(ownership.Default(ownership.Labels)).To(Equal(ownership.Labels))
+		})
+	})
+
+	Describe("Labels", func() {
+		It("sets the owner UID as a label and the GVK and key as annotations", func() {
+			Expect(ownership.Labels.SetOwner(owner, child, scheme.Scheme)).To(Succeed())
+			Expect(child.OwnerReferences).To(BeEmpty())
+			Expect(child.Labels).To(Equal(map[string]string{ownership.OwnerUIDKey: "owner-uid"}))
+			Expect(child.Annotations).To(Equal(map[string]string{
+				ownership.OwnerGVKKey: "v1/ServiceAccount",
+				ownership.OwnerKeyKey: "ns/sa",
+			}))
+			Expect(ownership.Labels.GarbageCollected()).To(BeFalse())
+
+			Expect(ownership.OwnerOf(child)).To(Equal(&ownership.Ref{
+				UID: "owner-uid",
+				GVK: corev1.SchemeGroupVersion.WithKind("ServiceAccount"),
+				Key: kotclient.Key{Namespace: "ns", Name: "sa"},
+			}))
+		})
+
+		It("fails if the object is owned by someone else", func() {
+			other := &corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "ns", UID: "other-uid"}}
+			Expect(ownership.Labels.SetOwner(other, child, scheme.Scheme)).To(Succeed())
+
+			err := ownership.Labels.SetOwner(owner, child, scheme.Scheme)
+			Expect(err).To(MatchError("object is already owned by ServiceAccount ns/other"))
+		})
+	})
+
+	Describe("Annotations", func() {
+		It("sets the owner UID, GVK and key as annotations", func() {
+			clusterOwner := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "owner", UID: "owner-uid"}}
+
+			Expect(ownership.Annotations.SetOwner(clusterOwner, child, scheme.Scheme)).To(Succeed())
+			Expect(child.Labels).To(BeEmpty())
+			Expect(child.Annotations).To(HaveKeyWithValue(ownership.OwnerUIDKey, "owner-uid"))
+
+			Expect(ownership.OwnerOf(child)).To(Equal(&ownership.Ref{
+				UID: "owner-uid",
+				GVK: corev1.SchemeGroupVersion.WithKind("Namespace"),
+				Key: kotclient.Key{Name: "owner"},
+			}))
+		})
+	})
+
+	Describe("OwnerOf", func() {
+		It("returns nil for objects that are not owned", func() {
+			Expect(ownership.OwnerOf(child)).To(BeNil())
+		})
+
+		It("returns nil if the owner GVK is invalid", func() {
+			child.Labels = map[string]string{ownership.OwnerUIDKey: "owner-uid"}
+			child.Annotations = map[string]string{ownership.OwnerGVKKey: "invalid"}
+			Expect(ownership.OwnerOf(child)).To(BeNil())
+		})
+	})
+
+	Describe("EnqueueOwner", func() {
+		It("enqueues owners of the given GVK", func() {
+			Expect(ownership.Labels.SetOwner(owner, child, scheme.Scheme)).To(Succeed())
+			queue := workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter())
+			defer queue.ShutDown()
+
+			ownership.EnqueueOwner(corev1.SchemeGroupVersion.WithKind("Secret")).Create(runtimeevent.CreateEvent{Object: child}, queue)
+			Expect(queue.Len()).To(Equal(0))
+
+			ownership.EnqueueOwner(corev1.SchemeGroupVersion.WithKind("ServiceAccount")).Create(runtimeevent.CreateEvent{Object: child}, queue)
+			Expect(queue.Len()).To(Equal(1))
+			item, _ := queue.Get()
+			Expect(item).To(Equal(runtimereconcile.Request{NamespacedName: kotclient.Key{Namespace: "ns", Name: "sa"}}))
+		})
+	})
+})
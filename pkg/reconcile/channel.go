@@ -0,0 +1,147 @@
+package reconcile
+
+import (
+	"context"
+	"time"
+
+	"github.com/fgrehm/kot/pkg/deps"
+	"github.com/fgrehm/kot/pkg/kotclient"
+	"github.com/pkg/errors"
+	"k8s.io/client-go/util/workqueue"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	runtimehandler "sigs.k8s.io/controller-runtime/pkg/handler"
+	ctrllog "sigs.k8s.io/controller-runtime/pkg/log"
+	runtimepredicate "sigs.k8s.io/controller-runtime/pkg/predicate"
+	runtimereconcile "sigs.k8s.io/controller-runtime/pkg/reconcile"
+	runtimesource "sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+// ChannelWatcher enqueues resources from events sent on Go channels, so that
+// code running outside of controllers, like webhook receivers or message
+// consumers, can trigger reconciliations.
+type ChannelWatcher struct {
+	*ChannelWatcherConfig
+	// mapper maps objects of events the same way resource watchers do
+	mapper *ResourceWatcher
+}
+
+var _ ParentWatcher = &ChannelWatcher{}
+var _ ControllerWatcher = &ChannelWatcher{}
+var _ WatcherConfig = &ChannelWatcherConfig{}
+var _ deps.DepsInjector = &ChannelWatcher{}
+
+func (w *ChannelWatcher) InjectDeps(ctn deps.Container) {
+	// Events can carry objects of any type, so there is no watched GVK
+	w.mapper.ctn = ctn
+	w.mapper.log = ctrllog.Log.WithName("watcher").WithValues("source", "channel")
+}
+
+func (w *ChannelWatcher) WatchParents(gvk kotclient.GVK) {
+	w.mapper.WatchParents(gvk)
+}
+
+func (w *ChannelWatcher) WatchForController(name string) {
+	w.mapper.WatchForController(name)
+}
+
+func (w *ChannelWatcher) Source() runtimesource.Source {
+	if w.Requests != nil {
+		return &requestSource{requests: w.Requests}
+	}
+	return &runtimesource.Channel{Source: w.Events}
+}
+
+func (w *ChannelWatcher) Handler() runtimehandler.EventHandler {
+	if w.Enqueue == nil {
+		return &runtimehandler.EnqueueRequestForObject{}
+	}
+	return w.mapper.Handler()
+}
+
+func (w *ChannelWatcher) Predicate() runtimepredicate.Predicate {
+	if w.When == nil {
+		return runtimepredicate.Funcs{}
+	}
+	return w.When
+}
+
+type ChannelWatcherConfig struct {
+	// Events enqueues the objects of the events sent on it, which only need to
+	// have their name and namespace set.
+	Events <-chan event.GenericEvent
+	// Requests enqueues the requests sent on it as they are, it can't be set
+	// along with Events.
+	Requests <-chan runtimereconcile.Request
+
+	// When filters Events, all of them go through by default.
+	When runtimepredicate.Predicate
+	// Enqueue maps objects of Events to the requests to enqueue, defaults to
+	// enqueuing the objects themselves.
+	Enqueue func(ctx context.Context, deps deps.Container, obj runtimeclient.Object) ([]runtimereconcile.Request, error)
+	// Timeout, Retries and EnqueueAllOnError are handled as in
+	// ResourceWatcherConfig and require Enqueue to be set.
+	Timeout           time.Duration
+	Retries           int
+	EnqueueAllOnError bool
+}
+
+func (c *ChannelWatcherConfig) Validate() (bool, error) {
+	if c.Events == nil && c.Requests == nil {
+		return false, errors.New("channel is not set")
+	}
+	if c.Events != nil && c.Requests != nil {
+		return false, errors.New("only one of events and requests channels can be set")
+	}
+	if c.Requests != nil && (c.When != nil || c.Enqueue != nil) {
+		return false, errors.New("predicates and enqueuer can only be used with events")
+	}
+	if c.Enqueue == nil && (c.Timeout != 0 || c.Retries != 0 || c.EnqueueAllOnError) {
+		return false, errors.New("timeout, retries and fallback can only be used with an enqueuer")
+	}
+	if c.Timeout < 0 {
+		return false, errors.New("timeout can't be negative")
+	}
+	if c.Retries < 0 {
+		return false, errors.New("retries can't be negative")
+	}
+
+	return true, nil
+}
+
+func (c *ChannelWatcherConfig) buildWatcher() (Watcher, error) {
+	return &ChannelWatcher{
+		ChannelWatcherConfig: c,
+		mapper: &ResourceWatcher{ResourceWatcherConfig: &ResourceWatcherConfig{
+			Enqueue:           c.Enqueue,
+			Timeout:           c.Timeout,
+			Retries:           c.Retries,
+			EnqueueAllOnError: c.EnqueueAllOnError,
+		}},
+	}, nil
+}
+
+// requestSource adds requests sent on a channel to the queue until the
+// manager stops or the channel is closed.
+type requestSource struct {
+	requests <-chan runtimereconcile.Request
+}
+
+var _ runtimesource.Source = &requestSource{}
+
+func (s *requestSource) Start(ctx context.Context, _ runtimehandler.EventHandler, queue workqueue.RateLimitingInterface, _ ...runtimepredicate.Predicate) error {
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case req, ok := <-s.requests:
+				if !ok {
+					return
+				}
+				queue.Add(req)
+			}
+		}
+	}()
+	return nil
+}
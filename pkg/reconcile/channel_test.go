@@ -0,0 +1,131 @@
+package reconcile_test
+
+import (
+	"context"
+	"errors"
+
+	"github.com/fgrehm/kot/pkg/deps"
+	wkdeps "github.com/fgrehm/kot/pkg/deps/wellknown"
+	"github.com/fgrehm/kot/pkg/kottesting/gomock"
+	"github.com/fgrehm/kot/pkg/metrics"
+	"github.com/fgrehm/kot/pkg/reconcile"
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/workqueue"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+	runtimeevent "sigs.k8s.io/controller-runtime/pkg/event"
+	runtimereconcile "sigs.k8s.io/controller-runtime/pkg/reconcile"
+	runtimesource "sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+var _ = Describe("ChannelWatcher", func() {
+	var (
+		ctx    context.Context
+		cancel context.CancelFunc
+		queue  workqueue.RateLimitingInterface
+	)
+
+	BeforeEach(func() {
+		ctx, cancel = context.WithCancel(context.Background())
+		queue = workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter())
+	})
+
+	AfterEach(func() {
+		cancel()
+		queue.ShutDown()
+		deps.Clear()
+	})
+
+	It("enqueues requests sent on the channel", func() {
+		requests := make(chan runtimereconcile.Request)
+		watcher := reconcile.MustCreateWatcher(&reconcile.ChannelWatcherConfig{Requests: requests})
+		deps.Inject(deps.Build(), watcher)
+
+		Expect(watcher.Source().Start(ctx, watcher.Handler(), queue, watcher.Predicate())).To(Succeed())
+		req := runtimereconcile.Request{NamespacedName: runtimeclient.ObjectKey{Namespace: "ns", Name: "parent"}}
+		requests <- req
+
+		Eventually(queue.Len).Should(Equal(1))
+		item, _ := queue.Get()
+		Expect(item).To(Equal(req))
+	})
+
+	It("maps objects of events to requests", func() {
+		events := make(chan runtimeevent.GenericEvent)
+		watcher := reconcile.MustCreateWatcher(&reconcile.ChannelWatcherConfig{
+			Events: events,
+			Enqueue: func(_ context.Context, _ deps.Container, obj runtimeclient.Object) ([]runtimereconcile.Request, error) {
+				return []runtimereconcile.Request{{NamespacedName: runtimeclient.ObjectKey{Name: obj.GetName() + "-parent"}}}, nil
+			},
+		})
+		deps.Inject(deps.Build(), watcher)
+		Expect(watcher.Source()).To(Equal(&runtimesource.Channel{Source: events}))
+
+		watcher.Handler().Generic(runtimeevent.GenericEvent{Object: &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "ns"}}}, queue)
+		item, _ := queue.Get()
+		Expect(item).To(Equal(runtimereconcile.Request{NamespacedName: runtimeclient.ObjectKey{Name: "ns-parent"}}))
+	})
+
+	It("enqueues objects of events by default", func() {
+		watcher := reconcile.MustCreateWatcher(&reconcile.ChannelWatcherConfig{Events: make(chan runtimeevent.GenericEvent)})
+		deps.Inject(deps.Build(), watcher)
+
+		watcher.Handler().Generic(runtimeevent.GenericEvent{Object: &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "ns"}}}, queue)
+		item, _ := queue.Get()
+		Expect(item).To(Equal(runtimereconcile.Request{NamespacedName: runtimeclient.ObjectKey{Name: "ns"}}))
+	})
+
+	It("counts mapping errors and enqueues all parents when set to", func() {
+		mCtrl := gomock.NewController(GinkgoT())
+		defer mCtrl.Finish()
+		mockedEnv := kotmocks.NewEnv(mCtrl, GinkgoWriter)
+		wkdeps.SetClient(mockedEnv.Client)
+		wkdeps.SetScheme(mockedEnv.Scheme)
+		mockedEnv.Client.EXPECT().List(gomock.Any(), gomock.Any()).SetArg(1, corev1.NamespaceList{Items: []corev1.Namespace{
+			{ObjectMeta: metav1.ObjectMeta{Name: "a"}},
+		}})
+
+		watcher := reconcile.MustCreateWatcher(&reconcile.ChannelWatcherConfig{
+			Events: make(chan runtimeevent.GenericEvent),
+			Enqueue: func(context.Context, deps.Container, runtimeclient.Object) ([]runtimereconcile.Request, error) {
+				return nil, errors.New("boom")
+			},
+			EnqueueAllOnError: true,
+		})
+		watcher.(reconcile.ParentWatcher).WatchParents(corev1.SchemeGroupVersion.WithKind("Namespace"))
+		watcher.(reconcile.ControllerWatcher).WatchForController("namespace")
+		deps.Inject(deps.Build(), watcher)
+		errs := metrics.WatcherEnqueueErrors.WithLabelValues("namespace", "", "v1", "ConfigMap")
+		before := testutil.ToFloat64(errs)
+
+		watcher.Handler().Generic(runtimeevent.GenericEvent{Object: &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "cm"}}}, queue)
+		Expect(testutil.ToFloat64(errs)).To(Equal(before + 1))
+		item, _ := queue.Get()
+		Expect(item).To(Equal(runtimereconcile.Request{NamespacedName: runtimeclient.ObjectKey{Name: "a"}}))
+	})
+
+	Describe("Validate", func() {
+		It("requires exactly one channel", func() {
+			_, err := reconcile.CreateWatcher(&reconcile.ChannelWatcherConfig{})
+			Expect(err).To(MatchError("channel is not set"))
+
+			_, err = reconcile.CreateWatcher(&reconcile.ChannelWatcherConfig{
+				Events:   make(chan runtimeevent.GenericEvent),
+				Requests: make(chan runtimereconcile.Request),
+			})
+			Expect(err).To(MatchError("only one of events and requests channels can be set"))
+		})
+
+		It("requires an enqueuer for retries", func() {
+			_, err := reconcile.CreateWatcher(&reconcile.ChannelWatcherConfig{
+				Events:  make(chan runtimeevent.GenericEvent),
+				Retries: 1,
+			})
+			Expect(err).To(MatchError("timeout, retries and fallback can only be used with an enqueuer"))
+		})
+	})
+})
@@ -0,0 +1,57 @@
+package reconcile
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/fgrehm/kot/pkg/action"
+	"github.com/fgrehm/kot/pkg/deps"
+)
+
+// ChildrenCollector returns a finalizer that deletes the children of a
+// reconciler whose ownership strategy is not handled by the Kubernetes garbage
+// collector, it returns nil for other reconcilers.
+func ChildrenCollector(reconciler Reconciler) Finalizer {
+	owning, ok := reconciler.(OwningReconciler)
+	if !ok || owning.OwnershipStrategy().GarbageCollected() {
+		return nil
+	}
+	return &childrenCollector{reconciler: owning}
+}
+
+type childrenCollector struct {
+	reconciler OwningReconciler
+	resourceReconcilerMixin
+}
+
+var _ NamedFinalizer = &childrenCollector{}
+var _ deps.DepsInjector = &childrenCollector{}
+
+func (f *childrenCollector) FinalizerName() string {
+	name := strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return '-'
+	}, ReconcilerName(f.reconciler))
+	return fmt.Sprintf("kot.io/delete-%s", strings.Trim(name, "-"))
+}
+
+func (f *childrenCollector) Enabled(ctx action.Context) (bool, error) {
+	return true, nil
+}
+
+func (f *childrenCollector) Finalize(ctx action.Context) (bool, action.Result, error) {
+	// Read lazily as GVKs of typed reconcilers are only known once deps are injected
+	gvk := f.reconciler.OwnedGVK()
+	children, err := f.fetchChildren(ctx, gvk)
+	if err != nil {
+		return false, action.Result{}, err
+	}
+
+	if err := f.deleteChildren(ctx, gvk, children); err != nil {
+		return false, action.Result{}, err
+	}
+	return true, action.Result{}, nil
+}
@@ -0,0 +1,107 @@
+package reconcile_test
+
+import (
+	"errors"
+
+	"github.com/fgrehm/kot/pkg/action"
+	"github.com/fgrehm/kot/pkg/deps"
+	wkdeps "github.com/fgrehm/kot/pkg/deps/wellknown"
+	"github.com/fgrehm/kot/pkg/kotclient"
+	"github.com/fgrehm/kot/pkg/kottesting/gomock"
+	"github.com/fgrehm/kot/pkg/ownership"
+	"github.com/fgrehm/kot/pkg/reconcile"
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var _ = Describe("ChildrenCollector", func() {
+	var (
+		ctx   action.Context
+		mCtrl *gomock.Controller
+
+		client *kotmocks.MockClient
+		ctn    deps.Container
+		cfg    *reconcile.OneReconcilerConfig
+	)
+
+	BeforeEach(func() {
+		mCtrl = gomock.NewController(GinkgoT())
+
+		mockedEnv := kotmocks.NewEnv(mCtrl, GinkgoWriter)
+		client = mockedEnv.Client
+		wkdeps.SetClient(client)
+		wkdeps.SetScheme(mockedEnv.Scheme)
+		ctn = deps.Build()
+
+		cfg = &reconcile.OneReconcilerConfig{
+			GVK:       corev1.SchemeGroupVersion.WithKind("Namespace"),
+			Ownership: ownership.Labels,
+			Reconcile: func(ctx action.Context, child runtimeclient.Object) (action.Result, error) {
+				return action.Result{}, nil
+			},
+		}
+		ctx = action.NewBackgroundContext().WithResource(&corev1.ServiceAccount{})
+	})
+
+	AfterEach(func() {
+		mCtrl.Finish()
+		deps.Clear()
+	})
+
+	It("is not needed for children that are garbage collected by Kubernetes", func() {
+		cfg.Ownership = nil
+		Expect(reconcile.ChildrenCollector(reconcile.MustCreateReconciler(cfg))).To(BeNil())
+		Expect(reconcile.ChildrenCollector(&reconcile.CustomReconciler{})).To(BeNil())
+	})
+
+	It("deletes children that are not being deleted", func() {
+		collector := reconcile.ChildrenCollector(reconcile.MustCreateReconciler(cfg))
+		deps.Inject(ctn, collector)
+
+		now := metav1.Now()
+		client.EXPECT().List(gomock.Any(), gomock.Any(), gomock.Any()).
+			SetArg(1, corev1.NamespaceList{Items: []corev1.Namespace{
+				{ObjectMeta: metav1.ObjectMeta{Name: "deleting", DeletionTimestamp: &now}},
+				{ObjectMeta: metav1.ObjectMeta{Name: "child"}},
+			}})
+		client.EXPECT().Delete(gomock.Any(), gomock.Any()).Do(func(_ interface{}, obj runtimeclient.Object, _ ...interface{}) error {
+			Expect(obj.GetName()).To(Equal("child"))
+			return nil
+		})
+
+		Expect(collector.Enabled(ctx)).To(BeTrue())
+		finalized, _, err := collector.Finalize(ctx)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(finalized).To(BeTrue())
+	})
+
+	It("ignores children that are already gone", func() {
+		collector := reconcile.ChildrenCollector(reconcile.MustCreateReconciler(cfg))
+		deps.Inject(ctn, collector)
+
+		client.EXPECT().List(gomock.Any(), gomock.Any(), gomock.Any()).
+			SetArg(1, corev1.NamespaceList{Items: []corev1.Namespace{{ObjectMeta: metav1.ObjectMeta{Name: "child"}}}})
+		client.EXPECT().Delete(gomock.Any(), gomock.Any()).Return(kotclient.NewNotFound(kotclient.GR{}, "child"))
+
+		finalized, _, err := collector.Finalize(ctx)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(finalized).To(BeTrue())
+	})
+
+	It("fails if a child can't be deleted", func() {
+		collector := reconcile.ChildrenCollector(reconcile.MustCreateReconciler(cfg))
+		deps.Inject(ctn, collector)
+
+		client.EXPECT().List(gomock.Any(), gomock.Any(), gomock.Any()).
+			SetArg(1, corev1.NamespaceList{Items: []corev1.Namespace{{ObjectMeta: metav1.ObjectMeta{Name: "child"}}}})
+		client.EXPECT().Delete(gomock.Any(), gomock.Any()).Return(errors.New("boom"))
+
+		finalized, _, err := collector.Finalize(ctx)
+		Expect(err).To(MatchError("failed to delete child object: boom"))
+		Expect(finalized).To(BeFalse())
+	})
+})
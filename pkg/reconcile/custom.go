@@ -17,6 +17,18 @@ var _ deps.DepsInjector = &CustomReconciler{}
 func (r *CustomReconciler) InjectDeps(ctn deps.Container) {
 }
 
+func (r *CustomReconciler) ReconcilerName() string {
+	return r.Name
+}
+
+func (r *CustomReconciler) Dependencies() []Dependency {
+	return r.DependsOn
+}
+
+func (r *CustomReconciler) SpanName() string {
+	return r.ReconcilerName()
+}
+
 func (r *CustomReconciler) Run(originalCtx action.Context) (action.Result, error) {
 	if !originalCtx.Resource().GetDeletionTimestamp().IsZero() {
 		return action.Result{}, nil
@@ -35,6 +47,9 @@ type CustomReconcilerConfig struct {
 	Name      string
 	Reconcile CustomReconcilerFunc
 	Finalize  Finalizer
+
+	// DependsOn lists reconcilers that have to succeed before this one runs.
+	DependsOn []Dependency
 }
 
 type CustomReconcilerFunc = action.ActionFn
@@ -48,6 +63,9 @@ func (c *CustomReconcilerConfig) Validate() (bool, error) {
 	if c.Reconcile == nil {
 		return false, errors.New("Reconcile func is not set")
 	}
+	if err := validateDependencies(c.DependsOn); err != nil {
+		return false, err
+	}
 
 	return true, nil
 }
@@ -0,0 +1,66 @@
+package reconcile
+
+import (
+	"fmt"
+
+	"github.com/fgrehm/kot/pkg/kotclient"
+)
+
+// Dependency identifies reconcilers that have to succeed before a reconciler
+// runs, either by name or by the GVK they own.
+type Dependency struct {
+	Name string
+	GVK  kotclient.GVK
+}
+
+func OnName(name string) Dependency {
+	return Dependency{Name: name}
+}
+
+func OnGVK(gvk kotclient.GVK) Dependency {
+	return Dependency{GVK: gvk}
+}
+
+func (d Dependency) String() string {
+	if d.Name != "" {
+		return d.Name
+	}
+	return d.GVK.String()
+}
+
+// Matches checks if the reconciler is identified by the dependency.
+func (d Dependency) Matches(r Reconciler) bool {
+	if d.Name != "" {
+		return ReconcilerName(r) == d.Name
+	}
+	if rec, ok := r.(ResourceReconciler); ok {
+		return rec.OwnedGVK() == d.GVK
+	}
+	return false
+}
+
+// DependentReconciler is implemented by reconcilers that depend on others
+type DependentReconciler interface {
+	Reconciler
+	Dependencies() []Dependency
+}
+
+// Dependencies returns what the reconciler depends on, if anything.
+func Dependencies(r Reconciler) []Dependency {
+	if dependent, ok := r.(DependentReconciler); ok {
+		return dependent.Dependencies()
+	}
+	return nil
+}
+
+func validateDependencies(deps []Dependency) error {
+	for _, dep := range deps {
+		if dep.Name == "" && dep.GVK == (kotclient.GVK{}) {
+			return fmt.Errorf("dependency must have either a name or a GVK")
+		}
+		if dep.Name != "" && dep.GVK != (kotclient.GVK{}) {
+			return fmt.Errorf("dependency %q must have either a name or a GVK, not both", dep.Name)
+		}
+	}
+	return nil
+}
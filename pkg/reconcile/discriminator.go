@@ -0,0 +1,112 @@
+package reconcile
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/labels"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// RoleLabel is set on children of reconcilers that are discriminated by role.
+const RoleLabel = "kot.io/role"
+
+// Discriminator tells the children of a reconciler apart from the ones of
+// other reconcilers of the same GVK on the same parent.
+type Discriminator interface {
+	// Matches tells if obj belongs to the reconciler.
+	Matches(obj runtimeclient.Object) bool
+	// Stamp marks obj as belonging to the reconciler before it is written.
+	Stamp(obj runtimeclient.Object)
+	fmt.Stringer
+}
+
+// ByName matches the child with the given name, it is set on the child if the
+// reconcile func does not set one.
+func ByName(name string) Discriminator {
+	return byName(name)
+}
+
+type byName string
+
+func (d byName) Matches(obj runtimeclient.Object) bool {
+	return obj.GetName() == string(d)
+}
+
+func (d byName) Stamp(obj runtimeclient.Object) {
+	if obj.GetName() == "" {
+		obj.SetName(string(d))
+	}
+}
+
+func (d byName) String() string {
+	return fmt.Sprintf("name=%s", string(d))
+}
+
+// ByLabels matches children that have all of the given labels, which are set
+// on children before they are written.
+func ByLabels(labels map[string]string) Discriminator {
+	return byLabels(labels)
+}
+
+// ByRole matches children labeled with the given role.
+func ByRole(role string) Discriminator {
+	return byRole{byLabels{RoleLabel: role}}
+}
+
+type byRole struct {
+	byLabels
+}
+
+func (d byRole) String() string {
+	return fmt.Sprintf("role=%s", d.byLabels[RoleLabel])
+}
+
+type byLabels map[string]string
+
+func (d byLabels) Matches(obj runtimeclient.Object) bool {
+	objLabels := obj.GetLabels()
+	for name, value := range d {
+		if v, ok := objLabels[name]; !ok || v != value {
+			return false
+		}
+	}
+	return true
+}
+
+func (d byLabels) Stamp(obj runtimeclient.Object) {
+	objLabels := obj.GetLabels()
+	if objLabels == nil {
+		objLabels = map[string]string{}
+	}
+	for name, value := range d {
+		objLabels[name] = value
+	}
+	obj.SetLabels(objLabels)
+}
+
+func (d byLabels) String() string {
+	return fmt.Sprintf("labels=%s", labels.SelectorFromSet(labels.Set(d)))
+}
+
+// discriminatedName is the default name of reconcilers, children of the same
+// GVK picked by different discriminators get different names.
+func discriminatedName(kind string, d Discriminator) string {
+	if d == nil {
+		return kind
+	}
+	return fmt.Sprintf("%s(%s)", kind, d)
+}
+
+// discriminate returns the objects that match d, all of them if d is nil.
+func discriminate(d Discriminator, objs []runtimeclient.Object) []runtimeclient.Object {
+	if d == nil {
+		return objs
+	}
+	matched := []runtimeclient.Object{}
+	for _, obj := range objs {
+		if d.Matches(obj) {
+			matched = append(matched, obj)
+		}
+	}
+	return matched
+}
@@ -0,0 +1,68 @@
+package reconcile_test
+
+import (
+	"github.com/fgrehm/kot/pkg/reconcile"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("Discriminators", func() {
+	var cm *corev1.ConfigMap
+
+	BeforeEach(func() {
+		cm = &corev1.ConfigMap{}
+	})
+
+	Describe("ByName", func() {
+		It("matches and sets the name", func() {
+			d := reconcile.ByName("cm")
+			Expect(d.Matches(cm)).To(BeFalse())
+
+			d.Stamp(cm)
+			Expect(cm.Name).To(Equal("cm"))
+			Expect(d.Matches(cm)).To(BeTrue())
+		})
+
+		It("does not override names set by the reconcile func", func() {
+			cm.Name = "other"
+			reconcile.ByName("cm").Stamp(cm)
+			Expect(cm.Name).To(Equal("other"))
+		})
+	})
+
+	Describe("ByLabels", func() {
+		It("matches and sets all labels", func() {
+			d := reconcile.ByLabels(map[string]string{"a": "1", "b": "2"})
+			cm.ObjectMeta = metav1.ObjectMeta{Labels: map[string]string{"a": "1", "c": "3"}}
+			Expect(d.Matches(cm)).To(BeFalse())
+
+			d.Stamp(cm)
+			Expect(cm.Labels).To(Equal(map[string]string{"a": "1", "b": "2", "c": "3"}))
+			Expect(d.Matches(cm)).To(BeTrue())
+		})
+	})
+
+	Describe("ByRole", func() {
+		It("uses the role label", func() {
+			d := reconcile.ByRole("config")
+			d.Stamp(cm)
+			Expect(cm.Labels).To(Equal(map[string]string{reconcile.RoleLabel: "config"}))
+			Expect(d.Matches(cm)).To(BeTrue())
+			Expect(reconcile.ByRole("other").Matches(cm)).To(BeFalse())
+		})
+	})
+
+	It("names reconcilers after their discriminators", func() {
+		gvk := corev1.SchemeGroupVersion.WithKind("ConfigMap")
+		name := func(d reconcile.Discriminator) string {
+			return reconcile.ReconcilerName(&reconcile.OneReconciler{OneReconcilerConfig: &reconcile.OneReconcilerConfig{GVK: gvk, Discriminator: d}})
+		}
+
+		Expect(name(nil)).To(Equal("ConfigMap"))
+		Expect(name(reconcile.ByName("cm"))).To(Equal("ConfigMap(name=cm)"))
+		Expect(name(reconcile.ByRole("db"))).To(Equal("ConfigMap(role=db)"))
+		Expect(name(reconcile.ByLabels(map[string]string{"b": "2", "a": "1"}))).To(Equal("ConfigMap(labels=a=1,b=2)"))
+	})
+})
@@ -0,0 +1,62 @@
+package reconcile
+
+import (
+	"fmt"
+
+	"github.com/fgrehm/kot/pkg/action"
+	"github.com/fgrehm/kot/pkg/kotclient"
+	corev1 "k8s.io/api/core/v1"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Reasons of the events recorded on the resource being reconciled
+const (
+	ReasonChildCreated      = "ChildCreated"
+	ReasonChildUpdated      = "ChildUpdated"
+	ReasonChildDeleted      = "ChildDeleted"
+	ReasonChildCreateFailed = "ChildCreateFailed"
+	ReasonChildUpdateFailed = "ChildUpdateFailed"
+	ReasonChildDeleteFailed = "ChildDeleteFailed"
+	ReasonFinalized         = "Finalized"
+	ReasonFinalizeFailed    = "FinalizeFailed"
+	ReasonFinalizeTimedOut  = "FinalizeTimedOut"
+	ReasonReconcileFailed   = "ReconcileFailed"
+)
+
+// RecordChildEvent records an event on the resource being reconciled about a
+// write made to one of its children.
+func RecordChildEvent(ctx action.Context, op kotclient.SyncOperation, gvk kotclient.GVK, child runtimeclient.Object, err error) {
+	name := child.GetName()
+	if name == "" {
+		name = child.GetGenerateName()
+	}
+	childDesc := fmt.Sprintf("%s %s", gvk.Kind, name)
+
+	var reason, verb string
+	switch op {
+	case kotclient.SyncCreate:
+		reason, verb = ReasonChildCreated, "Created"
+		if err != nil {
+			reason, verb = ReasonChildCreateFailed, "create"
+		}
+	case kotclient.SyncUpdate:
+		reason, verb = ReasonChildUpdated, "Updated"
+		if err != nil {
+			reason, verb = ReasonChildUpdateFailed, "update"
+		}
+	case kotclient.SyncDelete:
+		reason, verb = ReasonChildDeleted, "Deleted"
+		if err != nil {
+			reason, verb = ReasonChildDeleteFailed, "delete"
+		}
+	default:
+		return
+	}
+
+	recorder := action.EventRecorderFrom(ctx)
+	if err != nil {
+		recorder.Eventf(ctx.Resource(), corev1.EventTypeWarning, reason, "Failed to %s %s: %s", verb, childDesc, err)
+		return
+	}
+	recorder.Eventf(ctx.Resource(), corev1.EventTypeNormal, reason, "%s %s", verb, childDesc)
+}
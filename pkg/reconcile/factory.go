@@ -8,6 +8,22 @@ import (
 	wkdeps "github.com/fgrehm/kot/pkg/deps/wellknown"
 )
 
+// InjectionValidator is implemented by reconcilers and watchers that can only
+// tell whether they are usable once deps are injected, controllers fail to
+// complete when the error is set.
+type InjectionValidator interface {
+	InjectionErr() error
+}
+
+// InjectionErr returns the error of a reconciler or watcher that failed to
+// set itself up when deps were injected.
+func InjectionErr(obj interface{}) error {
+	if v, ok := obj.(InjectionValidator); ok {
+		return v.InjectionErr()
+	}
+	return nil
+}
+
 func CreateReconciler(config ReconcilerConfig) (Reconciler, error) {
 	valid, err := config.Validate()
 	if !valid || err != nil {
@@ -21,6 +37,8 @@ func CreateReconciler(config ReconcilerConfig) (Reconciler, error) {
 		return &OneReconciler{OneReconcilerConfig: cfg}, nil
 	case *ListReconcilerConfig:
 		return &ListReconciler{ListReconcilerConfig: cfg}, nil
+	case reconcilerBuilder:
+		return cfg.buildReconciler(), nil
 	}
 
 	return nil, errors.New("unknown reconciler type")
@@ -43,6 +61,8 @@ func CreateWatcher(config WatcherConfig) (Watcher, error) {
 	switch cfg := config.(type) {
 	case *ResourceWatcherConfig:
 		return &ResourceWatcher{ResourceWatcherConfig: cfg}, nil
+	case watcherBuilder:
+		return cfg.buildWatcher()
 	}
 
 	return nil, errors.New("unknown watcher type")
@@ -1,95 +1,417 @@
 package reconcile
 
 import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
 	"github.com/fgrehm/kot/pkg/action"
 	"github.com/fgrehm/kot/pkg/kotclient"
+	"github.com/fgrehm/kot/pkg/metrics"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 )
 
-const finalizerName = "kot-fin"
+// LegacyFinalizerName is registered for finalizers that don't have a name of
+// their own, it used to be shared by all finalizers.
+const LegacyFinalizerName = "kot-fin"
+
+// FinalizersStatusAnnotation keeps track of the progress of pending finalizers.
+const FinalizersStatusAnnotation = "kot.io/finalizers-status"
+
+const (
+	DefaultFinalizerBackoff    = 5 * time.Second
+	DefaultFinalizerMaxBackoff = 5 * time.Minute
+)
+
+// FinalizerTimeoutPolicy tells what happens to finalizers that have been
+// pending for longer than the timeout of a FinalizerSet.
+type FinalizerTimeoutPolicy string
+
+const (
+	// FinalizerTimeoutFlag records an event and keeps retrying the finalizer.
+	FinalizerTimeoutFlag FinalizerTimeoutPolicy = "Flag"
+	// FinalizerTimeoutForceRemove records an event and removes the finalizer
+	// without it having completed.
+	FinalizerTimeoutForceRemove FinalizerTimeoutPolicy = "ForceRemove"
+)
+
+// FinalizerStatus is the progress of a finalizer, it is stored as JSON in the
+// FinalizersStatusAnnotation of the resource being finalized.
+type FinalizerStatus struct {
+	Attempts      int         `json:"attempts"`
+	Failures      int         `json:"failures,omitempty"`
+	LastError     string      `json:"lastError,omitempty"`
+	StartedAt     metav1.Time `json:"startedAt"`
+	LastAttemptAt metav1.Time `json:"lastAttemptAt"`
+	TimedOut      bool        `json:"timedOut,omitempty"`
+	// Completed is set for finalizers that ran as part of the legacy finalizer
+	Completed bool `json:"completed,omitempty"`
+}
+
+// persistedStatus returns a representation of the statuses that leaves out
+// Attempts and LastAttemptAt, changes to those alone don't result in an update
+// of the resource so that finalizers that are not done don't keep triggering
+// new reconciliations. They are saved along with the next relevant change.
+func persistedStatus(statuses map[string]*FinalizerStatus) string {
+	relevant := map[string]FinalizerStatus{}
+	for name, status := range statuses {
+		st := *status
+		st.Attempts, st.LastAttemptAt = 0, metav1.Time{}
+		relevant[name] = st
+	}
+	value, _ := json.Marshal(relevant)
+	return string(value)
+}
+
+// FinalizersStatus returns the progress of pending finalizers of a resource.
+func FinalizersStatus(resource runtimeclient.Object) (map[string]*FinalizerStatus, error) {
+	statuses := map[string]*FinalizerStatus{}
+	value := resource.GetAnnotations()[FinalizersStatusAnnotation]
+	if value == "" {
+		return statuses, nil
+	}
+	if err := json.Unmarshal([]byte(value), &statuses); err != nil {
+		return nil, errors.Wrap(err, "failed to parse finalizers status")
+	}
+	return statuses, nil
+}
+
+func setFinalizersStatus(resource runtimeclient.Object, statuses map[string]*FinalizerStatus) error {
+	annotations := resource.GetAnnotations()
+	if len(statuses) == 0 {
+		if _, ok := annotations[FinalizersStatusAnnotation]; ok {
+			delete(annotations, FinalizersStatusAnnotation)
+			resource.SetAnnotations(annotations)
+		}
+		return nil
+	}
+
+	value, err := json.Marshal(statuses)
+	if err != nil {
+		return err
+	}
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[FinalizersStatusAnnotation] = string(value)
+	resource.SetAnnotations(annotations)
+	return nil
+}
 
 type Finalizer interface {
 	Enabled(ctx action.Context) (bool, error)
 	Finalize(ctx action.Context) (bool, action.Result, error)
 }
 
+// NamedFinalizer is implemented by finalizers that register their own name on
+// resources, so that they are added and removed independently of others.
+type NamedFinalizer interface {
+	Finalizer
+	FinalizerName() string
+}
+
+// FinalizerName returns the name registered on resources for a finalizer,
+// falling back to LegacyFinalizerName for unnamed finalizers.
+func FinalizerName(f Finalizer) string {
+	if named, ok := f.(NamedFinalizer); ok && named.FinalizerName() != "" {
+		return named.FinalizerName()
+	}
+	return LegacyFinalizerName
+}
+
 type FinalizerSet struct {
-	client     kotclient.Client
-	finalizers []Finalizer
+	client        kotclient.Client
+	finalizers    []Finalizer
+	backoff       time.Duration
+	maxBackoff    time.Duration
+	timeout       time.Duration
+	timeoutPolicy FinalizerTimeoutPolicy
 }
 
 var _ action.Action = &FinalizerSet{}
 
+// WithBackoff configures how long failed finalizers wait before being retried,
+// the delay doubles on each consecutive failure up to max.
+func (s *FinalizerSet) WithBackoff(base, max time.Duration) *FinalizerSet {
+	s.backoff, s.maxBackoff = base, max
+	return s
+}
+
+// WithTimeout applies the policy to finalizers that are pending for longer
+// than timeout, finalizers never time out by default.
+func (s *FinalizerSet) WithTimeout(timeout time.Duration, policy FinalizerTimeoutPolicy) *FinalizerSet {
+	s.timeout, s.timeoutPolicy = timeout, policy
+	return s
+}
+
+// finalizerGroup holds the finalizers that share a name
+type finalizerGroup struct {
+	name       string
+	finalizers []Finalizer
+}
+
 func (s *FinalizerSet) Run(ctx action.Context) (action.Result, error) {
 	var (
-		resource   = ctx.Resource()
-		registered = controllerutil.ContainsFinalizer(resource, finalizerName)
-		deleting   = !resource.GetDeletionTimestamp().IsZero()
+		resource = ctx.Resource()
+		deleting = !resource.GetDeletionTimestamp().IsZero()
+		before   = append([]string{}, resource.GetFinalizers()...)
 	)
 
-	enabledFinalizers := []Finalizer{}
+	groups := []*finalizerGroup{}
+	enabledNames := map[string]*finalizerGroup{}
 	for _, finalizer := range s.finalizers {
 		enabled, err := finalizer.Enabled(ctx)
 		if err != nil {
 			return action.Result{}, err
 		}
+		if !enabled {
+			continue
+		}
 
-		if enabled {
-			enabledFinalizers = append(enabledFinalizers, finalizer)
+		name := FinalizerName(finalizer)
+		group, ok := enabledNames[name]
+		if !ok {
+			group = &finalizerGroup{name: name}
+			enabledNames[name] = group
+			groups = append(groups, group)
 		}
+		group.finalizers = append(group.finalizers, finalizer)
 	}
 
-	if len(enabledFinalizers) == 0 {
-		if registered {
-			return s.removeFinalizer(ctx)
+	// Names of finalizers that are no longer enabled are removed, that
+	// includes the legacy name once all finalizers have their own unless the
+	// resource is being deleted, finalize takes care of it in that case
+	for _, name := range s.knownNames() {
+		if _, enabled := enabledNames[name]; enabled || (deleting && name == LegacyFinalizerName) {
+			continue
 		}
-		return action.Result{}, nil
+		controllerutil.RemoveFinalizer(resource, name)
 	}
 
-	if registered && deleting {
-		finalized, res, err := s.finalize(ctx, enabledFinalizers)
-		if err != nil {
-			return res, err
+	if !deleting {
+		added := false
+		for _, group := range groups {
+			if !controllerutil.ContainsFinalizer(resource, group.name) {
+				controllerutil.AddFinalizer(resource, group.name)
+				added = true
+			}
 		}
-		if finalized {
-			return s.removeFinalizer(ctx)
-		} else {
-			return res.Merge(action.Result{Halt: true}), nil
+		if !s.changed(before, resource) {
+			return action.Result{}, nil
 		}
-	} else if !registered && !deleting {
-		return s.addFinalizer(ctx)
+		// The update event resumes reconciliation, planned updates never trigger
+		// one so reconciliation goes on
+		halt := added && kotclient.PlanFrom(ctx) == nil
+		return action.Result{Halt: halt}, s.client.Update(ctx, resource)
+	}
+
+	statuses, err := FinalizersStatus(resource)
+	if err != nil {
+		ctx.Logger().Error(err, "discarding finalizers status")
+		statuses = map[string]*FinalizerStatus{}
+	}
+	statusBefore := persistedStatus(statuses)
+
+	start := time.Now()
+	finalized, res, ran, finalizeErr := s.finalize(ctx, groups, statuses)
+	if ran {
+		metrics.ObserveFinalize(ctx, start, finalizeErr)
 	}
 
-	return action.Result{}, nil
+	if err := setFinalizersStatus(resource, statuses); err != nil {
+		return res, err
+	}
+	if s.changed(before, resource) || statusBefore != persistedStatus(statuses) {
+		if err := s.client.Update(ctx, resource); err != nil {
+			return res, err
+		}
+	}
+	if finalizeErr != nil {
+		return res, finalizeErr
+	}
+	if !finalized {
+		return res.Merge(action.Result{Halt: true}), nil
+	}
+	if ran {
+		action.EventRecorderFrom(ctx).Event(resource, corev1.EventTypeNormal, ReasonFinalized, "Finalized resource")
+	}
+
+	return res, nil
 }
 
-func (s *FinalizerSet) addFinalizer(ctx action.Context) (action.Result, error) {
-	resource := ctx.Resource()
-	controllerutil.AddFinalizer(resource, finalizerName)
-	return action.Result{Halt: true}, s.client.Update(ctx, resource)
+func (s *FinalizerSet) changed(before []string, resource runtimeclient.Object) bool {
+	after := resource.GetFinalizers()
+	if len(before) != len(after) {
+		return true
+	}
+	for i := range before {
+		if before[i] != after[i] {
+			return true
+		}
+	}
+	return false
 }
 
-func (s *FinalizerSet) removeFinalizer(ctx action.Context) (action.Result, error) {
-	resource := ctx.Resource()
-	controllerutil.RemoveFinalizer(resource, finalizerName)
-	return action.Result{}, s.client.Update(ctx, resource)
+// knownNames returns the names of all finalizers in the set, along with the
+// legacy one.
+func (s *FinalizerSet) knownNames() []string {
+	names := []string{LegacyFinalizerName}
+	for _, f := range s.finalizers {
+		if name := FinalizerName(f); name != LegacyFinalizerName {
+			names = append(names, name)
+		}
+	}
+	return names
 }
 
-func (s *FinalizerSet) finalize(ctx action.Context, finalizers []Finalizer) (bool, action.Result, error) {
+// finalize runs the groups of finalizers registered on the resource, names of
+// groups that are done get removed from it. Finalizers that are not registered
+// but enabled run as part of the legacy name while it is still around, since
+// finalizers can't be added to resources being deleted.
+func (s *FinalizerSet) finalize(ctx action.Context, groups []*finalizerGroup, statuses map[string]*FinalizerStatus) (bool, action.Result, bool, error) {
 	var (
-		res       = action.Result{}
-		finalized = true
+		resource   = ctx.Resource()
+		log        = ctx.Logger()
+		now        = time.Now()
+		res        = action.Result{}
+		finalized  = true
+		ran        = false
+		legacy     = controllerutil.ContainsFinalizer(resource, LegacyFinalizerName)
+		legacyDone = true
+		allErrors  = []string{}
 	)
 
-	for _, f := range finalizers {
+	for _, group := range groups {
+		registered := controllerutil.ContainsFinalizer(resource, group.name)
+		if !registered && !legacy {
+			continue
+		}
+
+		status, ok := statuses[group.name]
+		if !ok {
+			status = &FinalizerStatus{StartedAt: metav1.NewTime(now)}
+			statuses[group.name] = status
+		}
+		if status.Completed {
+			continue
+		}
+
+		done := false
+		if wait := s.retryIn(status, now); wait > 0 {
+			res = res.Merge(action.Result{RequeueAfter: wait})
+		} else {
+			ran = true
+			var err error
+			done, err = s.runGroup(ctx, group, status, now, &res)
+			if err != nil {
+				log.Error(err, "finalizer failed", "finalizer", group.name, "attempts", status.Attempts)
+				allErrors = append(allErrors, err.Error())
+			}
+		}
+
+		if !done && s.timedOut(status, now) {
+			done = s.handleTimeout(ctx, group.name, status)
+		}
+
+		if !done {
+			finalized = false
+			if !registered || group.name == LegacyFinalizerName {
+				legacyDone = false
+			}
+			continue
+		}
+
+		if registered {
+			controllerutil.RemoveFinalizer(resource, group.name)
+			delete(statuses, group.name)
+		} else {
+			status.Completed = true
+		}
+	}
+
+	if legacy && legacyDone {
+		controllerutil.RemoveFinalizer(resource, LegacyFinalizerName)
+		for name := range statuses {
+			delete(statuses, name)
+		}
+	}
+
+	if len(allErrors) == 0 {
+		return finalized, res, ran, nil
+	}
+	return false, res, ran, fmt.Errorf(`one or more errors occurred: ["%s"]`, strings.Join(allErrors, `", "`))
+}
+
+func (s *FinalizerSet) runGroup(ctx action.Context, group *finalizerGroup, status *FinalizerStatus, now time.Time, res *action.Result) (bool, error) {
+	status.Attempts++
+	status.LastAttemptAt = metav1.NewTime(now)
+
+	done := true
+	for _, f := range group.finalizers {
 		fin, r, err := f.Finalize(ctx)
+		*res = res.Merge(r)
 		if err != nil {
-			return false, res, nil
+			err = errors.Wrapf(err, "finalizer %s failed", group.name)
+			action.EventRecorderFrom(ctx).Eventf(ctx.Resource(), corev1.EventTypeWarning, ReasonFinalizeFailed, "Failed to finalize resource: %s", err)
+			status.Failures++
+			status.LastError = err.Error()
+			return false, err
 		}
 		if !fin {
-			finalized = false
+			done = false
 		}
-		res = res.Merge(r)
 	}
-	return finalized, res, nil
+
+	status.Failures = 0
+	status.LastError = ""
+	return done, nil
+}
+
+// retryIn returns how long a finalizer that failed has to wait before running
+// again.
+func (s *FinalizerSet) retryIn(status *FinalizerStatus, now time.Time) time.Duration {
+	if status.Failures == 0 {
+		return 0
+	}
+
+	backoff, maxBackoff := s.backoff, s.maxBackoff
+	if backoff <= 0 {
+		backoff = DefaultFinalizerBackoff
+	}
+	if maxBackoff <= 0 {
+		maxBackoff = DefaultFinalizerMaxBackoff
+	}
+	for i := 1; i < status.Failures && backoff < maxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > maxBackoff {
+		backoff = maxBackoff
+	}
+
+	return status.LastAttemptAt.Add(backoff).Sub(now)
+}
+
+func (s *FinalizerSet) timedOut(status *FinalizerStatus, now time.Time) bool {
+	return s.timeout > 0 && now.Sub(status.StartedAt.Time) >= s.timeout
+}
+
+// handleTimeout applies the timeout policy, it returns true if the finalizer
+// should be considered done.
+func (s *FinalizerSet) handleTimeout(ctx action.Context, name string, status *FinalizerStatus) bool {
+	if s.timeoutPolicy == FinalizerTimeoutForceRemove {
+		ctx.Logger().Info("removing finalizer that timed out", "finalizer", name)
+		action.EventRecorderFrom(ctx).Eventf(ctx.Resource(), corev1.EventTypeWarning, ReasonFinalizeTimedOut, "Finalizer %s timed out and was removed", name)
+		return true
+	}
+
+	if !status.TimedOut {
+		status.TimedOut = true
+		action.EventRecorderFrom(ctx).Eventf(ctx.Resource(), corev1.EventTypeWarning, ReasonFinalizeTimedOut, "Finalizer %s is taking longer than %s", name, s.timeout)
+	}
+	return false
 }
@@ -1,6 +1,10 @@
 package reconcile_test
 
 import (
+	"errors"
+	"fmt"
+	"time"
+
 	"github.com/fgrehm/kot/pkg/action"
 	"github.com/fgrehm/kot/pkg/deps"
 	wkdeps "github.com/fgrehm/kot/pkg/deps/wellknown"
@@ -11,6 +15,7 @@ import (
 	. "github.com/onsi/gomega"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
 	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 )
@@ -28,6 +33,26 @@ func (f fakeFinalizer) Finalize(ctx action.Context) (bool, action.Result, error)
 	return f.finalize(ctx)
 }
 
+type namedFakeFinalizer struct {
+	fakeFinalizer
+	name     string
+	timesRan int
+}
+
+func newNamedFinalizer(name string, done bool) *namedFakeFinalizer {
+	f := &namedFakeFinalizer{name: name}
+	f.enabled = func(action.Context) (bool, error) { return true, nil }
+	f.finalize = func(action.Context) (bool, action.Result, error) {
+		f.timesRan++
+		return done, action.Result{}, nil
+	}
+	return f
+}
+
+func (f *namedFakeFinalizer) FinalizerName() string {
+	return f.name
+}
+
 var _ = Describe("FinalizerSet", func() {
 	var (
 		ctx    action.Context
@@ -153,9 +178,91 @@ var _ = Describe("FinalizerSet", func() {
 				})
 
 				It("runs the enabled finalizers", func() {
+					client.EXPECT().Update(gomock.Any(), gomock.Any())
+
 					res, err := finalizerSet.Run(ctx)
 					Expect(err).NotTo(HaveOccurred())
 					Expect(res).To(Equal(action.Result{Halt: true}))
+
+					statuses, err := reconcile.FinalizersStatus(sa)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(statuses).To(HaveKey("kot-fin"))
+					Expect(statuses["kot-fin"].Attempts).To(Equal(1))
+					Expect(statuses["kot-fin"].StartedAt.IsZero()).To(BeFalse())
+
+					By("not updating the resource again if only attempts changed")
+					res, err = finalizerSet.Run(ctx)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(res).To(Equal(action.Result{Halt: true}))
+				})
+
+				It("returns finalizer errors and records them", func() {
+					enabledFinalizer.finalize = func(ctx action.Context) (bool, action.Result, error) {
+						return false, action.Result{}, errors.New("boom")
+					}
+					client.EXPECT().Update(gomock.Any(), gomock.Any())
+
+					recorder := record.NewFakeRecorder(1)
+					_, err := finalizerSet.Run(action.NewContext(action.WithEventRecorder(ctx, recorder)))
+					Expect(err).To(MatchError(`one or more errors occurred: ["finalizer kot-fin failed: boom"]`))
+					Expect(recorder.Events).To(Receive(ContainSubstring("Warning FinalizeFailed")))
+					Expect(sa.Finalizers).To(Equal([]string{"kot-fin"}))
+
+					statuses, err := reconcile.FinalizersStatus(sa)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(statuses["kot-fin"].Failures).To(Equal(1))
+					Expect(statuses["kot-fin"].LastError).To(Equal("finalizer kot-fin failed: boom"))
+				})
+
+				It("backs off finalizers that failed", func() {
+					sa.Annotations = map[string]string{
+						reconcile.FinalizersStatusAnnotation: fmt.Sprintf(`{"kot-fin":{"attempts":1,"failures":2,"startedAt":%[1]q,"lastAttemptAt":%[1]q}}`, now.UTC().Format(time.RFC3339)),
+					}
+					enabledFinalizer.finalize = func(ctx action.Context) (bool, action.Result, error) {
+						panic("should not run")
+					}
+
+					res, err := finalizerSet.WithBackoff(time.Minute, time.Hour).Run(ctx)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(res.Halt).To(BeTrue())
+					Expect(res.RequeueAfter).To(BeNumerically("~", 2*time.Minute, 2*time.Second))
+				})
+
+				Context("finalizer timed out", func() {
+					BeforeEach(func() {
+						startedAt := now.Add(-time.Hour).UTC().Format(time.RFC3339)
+						sa.Annotations = map[string]string{
+							reconcile.FinalizersStatusAnnotation: fmt.Sprintf(`{"kot-fin":{"attempts":3,"startedAt":%[1]q,"lastAttemptAt":%[1]q}}`, startedAt),
+						}
+					})
+
+					It("flags it and keeps retrying", func() {
+						client.EXPECT().Update(gomock.Any(), gomock.Any())
+
+						recorder := record.NewFakeRecorder(1)
+						res, err := finalizerSet.WithTimeout(time.Minute, reconcile.FinalizerTimeoutFlag).Run(action.NewContext(action.WithEventRecorder(ctx, recorder)))
+						Expect(err).NotTo(HaveOccurred())
+						Expect(res).To(Equal(action.Result{Halt: true}))
+						Expect(recorder.Events).To(Receive(ContainSubstring("Warning FinalizeTimedOut")))
+						Expect(sa.Finalizers).To(Equal([]string{"kot-fin"}))
+
+						statuses, err := reconcile.FinalizersStatus(sa)
+						Expect(err).NotTo(HaveOccurred())
+						Expect(statuses["kot-fin"].TimedOut).To(BeTrue())
+						Expect(statuses["kot-fin"].Attempts).To(Equal(4))
+					})
+
+					It("force removes it", func() {
+						client.EXPECT().Update(gomock.Any(), gomock.Any())
+
+						recorder := record.NewFakeRecorder(2)
+						res, err := finalizerSet.WithTimeout(time.Minute, reconcile.FinalizerTimeoutForceRemove).Run(action.NewContext(action.WithEventRecorder(ctx, recorder)))
+						Expect(err).NotTo(HaveOccurred())
+						Expect(res).To(Equal(action.Result{}))
+						Expect(recorder.Events).To(Receive(ContainSubstring("Warning FinalizeTimedOut")))
+						Expect(sa.Finalizers).To(BeEmpty())
+						Expect(sa.Annotations).NotTo(HaveKey(reconcile.FinalizersStatusAnnotation))
+					})
 				})
 
 				It("deregisters finalizer if all child finalizers are done", func() {
@@ -169,9 +276,11 @@ var _ = Describe("FinalizerSet", func() {
 						return nil
 					})
 
-					res, err := finalizerSet.Run(ctx)
+					recorder := record.NewFakeRecorder(1)
+					res, err := finalizerSet.Run(action.NewContext(action.WithEventRecorder(ctx, recorder)))
 					Expect(err).NotTo(HaveOccurred())
 					Expect(res).To(Equal(action.Result{}))
+					Expect(recorder.Events).To(Receive(Equal("Normal Finalized Finalized resource")))
 				})
 			})
 
@@ -183,5 +292,71 @@ var _ = Describe("FinalizerSet", func() {
 				})
 			})
 		})
+
+		Context("named finalizers", func() {
+			var (
+				fooFinalizer *namedFakeFinalizer
+				barFinalizer *namedFakeFinalizer
+			)
+
+			BeforeEach(func() {
+				fooFinalizer = newNamedFinalizer("kot.io/foo", true)
+				barFinalizer = newNamedFinalizer("kot.io/bar", false)
+				finalizerSet = reconcile.CreateFinalizerSet(ctn, fooFinalizer, barFinalizer)
+			})
+
+			It("registers each finalizer and replaces the legacy one", func() {
+				controllerutil.AddFinalizer(sa, "kot-fin")
+				client.EXPECT().Update(gomock.Any(), gomock.Any())
+
+				res, err := finalizerSet.Run(ctx)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(res).To(Equal(action.Result{Halt: true}))
+				Expect(sa.Finalizers).To(Equal([]string{"kot.io/foo", "kot.io/bar"}))
+			})
+
+			It("removes finalizers that are done independently", func() {
+				sa.DeletionTimestamp = &now
+				sa.Finalizers = []string{"kot.io/foo", "kot.io/bar", "someone-else"}
+				client.EXPECT().Update(gomock.Any(), gomock.Any())
+
+				res, err := finalizerSet.Run(ctx)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(res).To(Equal(action.Result{Halt: true}))
+				Expect(sa.Finalizers).To(Equal([]string{"kot.io/bar", "someone-else"}))
+
+				res, err = finalizerSet.Run(ctx)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(res).To(Equal(action.Result{Halt: true}))
+				Expect(fooFinalizer.timesRan).To(Equal(1))
+				Expect(barFinalizer.timesRan).To(Equal(2))
+			})
+
+			It("runs finalizers of resources that only carry the legacy finalizer", func() {
+				sa.DeletionTimestamp = &now
+				sa.Finalizers = []string{"kot-fin"}
+				barFinalizer.finalize = func(action.Context) (bool, action.Result, error) {
+					return true, action.Result{}, nil
+				}
+				client.EXPECT().Update(gomock.Any(), gomock.Any())
+
+				res, err := finalizerSet.Run(ctx)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(res).To(Equal(action.Result{}))
+				Expect(fooFinalizer.timesRan).To(Equal(1))
+				Expect(sa.Finalizers).To(BeEmpty())
+			})
+
+			It("keeps the legacy finalizer until all finalizers are done", func() {
+				sa.DeletionTimestamp = &now
+				sa.Finalizers = []string{"kot-fin"}
+				client.EXPECT().Update(gomock.Any(), gomock.Any())
+
+				res, err := finalizerSet.Run(ctx)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(res).To(Equal(action.Result{Halt: true}))
+				Expect(sa.Finalizers).To(Equal([]string{"kot-fin"}))
+			})
+		})
 	})
 })
@@ -4,9 +4,11 @@ import (
 	"github.com/fgrehm/kot/pkg/action"
 	"github.com/fgrehm/kot/pkg/deps"
 	"github.com/fgrehm/kot/pkg/kotclient"
+	"github.com/fgrehm/kot/pkg/ownership"
 	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	apiruntime "k8s.io/apimachinery/pkg/runtime"
-	ctrl "sigs.k8s.io/controller-runtime"
 	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
 )
 
@@ -17,11 +19,35 @@ type ListReconciler struct {
 
 var _ ResourceReconciler = &ListReconciler{}
 var _ deps.DepsInjector = &ListReconciler{}
+var _ DiscriminatedReconciler = &ListReconciler{}
 
 func (r *ListReconciler) OwnedGVK() kotclient.GVK {
 	return r.GVK
 }
 
+func (r *ListReconciler) ReconcilerName() string {
+	if r.Name != "" {
+		return r.Name
+	}
+	return discriminatedName(r.GVK.Kind, r.Discriminator)
+}
+
+func (r *ListReconciler) ChildDiscriminator() Discriminator {
+	return r.Discriminator
+}
+
+func (r *ListReconciler) Dependencies() []Dependency {
+	return r.DependsOn
+}
+
+func (r *ListReconciler) OwnershipStrategy() ownership.Strategy {
+	return ownership.Default(r.Ownership)
+}
+
+func (r *ListReconciler) SpanName() string {
+	return r.ReconcilerName()
+}
+
 func (r *ListReconciler) Run(originalCtx action.Context) (action.Result, error) {
 	if !originalCtx.Resource().GetDeletionTimestamp().IsZero() {
 		return action.Result{}, nil
@@ -31,12 +57,11 @@ func (r *ListReconciler) Run(originalCtx action.Context) (action.Result, error)
 		gvk    = r.GVK
 		ctx    = originalCtx.WithLoggerValues("owned-gvk", gvk.String())
 		client = r.Client
-		scheme = r.Scheme
 		log    = ctx.Logger()
 	)
 	log.Info("reconciling list")
 
-	objList, err := r.newObjectList(scheme, gvk)
+	objList, err := r.newObjectList(gvk)
 	if err != nil {
 		return action.Result{}, errors.Wrap(err, "failed to initialize list")
 	}
@@ -44,27 +69,115 @@ func (r *ListReconciler) Run(originalCtx action.Context) (action.Result, error)
 	if err := r.listChildren(ctx, gvk, objList); err != nil {
 		return action.Result{}, errors.Wrap(err, "failed to fetch children resources")
 	}
+	if r.Discriminator != nil {
+		// Leave children of other reconcilers out so that they are not pruned
+		children, err := kotclient.ExtractList(objList)
+		if err != nil {
+			return action.Result{}, errors.Wrap(err, "failed to extract child resources from list")
+		}
+		if err := kotclient.SetList(objList, discriminate(r.Discriminator, children)); err != nil {
+			return action.Result{}, err
+		}
+	}
+
+	if shouldDelete, err := r.shouldDelete(ctx); err != nil {
+		return action.Result{}, errors.Wrap(err, "failed to check if children objects have to be deleted")
+	} else if shouldDelete {
+		return action.Result{}, r.deleteAll(ctx, objList)
+	}
 
 	reconciledObjList := objList.DeepCopyObject().(runtimeclient.ObjectList)
+	syncOpts := []kotclient.SyncListOption{
+		kotclient.WithPatchOptions(r.Patch),
+		kotclient.WithDriftOptions(driftOptions(ctx, r.Drift, r.Apply)),
+		kotclient.WithObserver(func(op kotclient.SyncOperation, obj runtimeclient.Object, err error) {
+			childWritten(ctx, op, gvk, obj, err)
+		}),
+	}
+	if r.Apply != nil {
+		// Start from scratch so that only fields set by the reconcile func are owned
+		if reconciledObjList, err = r.newApplyObjectList(gvk, objList); err != nil {
+			return action.Result{}, err
+		}
+		syncOpts = append(syncOpts, kotclient.WithApply(*r.Apply))
+	}
+
 	result, err := r.Reconcile(ctx, reconciledObjList)
 	if err != nil {
 		return result, errors.Wrap(err, "failed to reconcile children objects")
 	}
 
 	log.V(lDebug).Info("syncing list")
-	if client.SyncList(ctx, objList, reconciledObjList, r.ownerRefSetter(ctx)); err != nil {
+	report, err := client.SyncList(ctx, objList, reconciledObjList, r.ownerRefSetter(ctx), syncOpts...)
+	if report != nil {
+		if reports := SyncReportsFrom(ctx); reports != nil {
+			reports.Record(r.ReconcilerName(), report)
+		}
+		for _, entries := range [][]kotclient.SyncListEntry{report.Updated, report.Failed} {
+			for _, entry := range entries {
+				if entry.Drift.Changed() {
+					log.Info("updated child resource", "child", entry.Key.String(), "diff", entry.Drift.String(), "failed", entry.Err != nil)
+				}
+			}
+		}
+		if report.Changed() || len(report.Failed) > 0 {
+			log.Info("synced list", "created", len(report.Created), "updated", len(report.Updated), "deleted", len(report.Deleted), "failed", len(report.Failed))
+		}
+	}
+	if err != nil {
 		return result, errors.Wrap(err, "failed to sync list")
 	}
 
 	return result, nil
 }
 
+func (r *ListReconciler) shouldDelete(ctx action.Context) (bool, error) {
+	if r.If == nil {
+		return false, nil
+	}
+
+	ifResult, err := r.If(ctx)
+	return !ifResult, err
+}
+
+func (r *ListReconciler) deleteAll(ctx action.Context, objList runtimeclient.ObjectList) error {
+	children, err := kotclient.ExtractList(objList)
+	if err != nil {
+		return errors.Wrap(err, "failed to extract child resources from list")
+	}
+
+	opts := []runtimeclient.DeleteOption{}
+	if r.DeletePropagation != "" {
+		opts = append(opts, runtimeclient.PropagationPolicy(r.DeletePropagation))
+	}
+	return r.deleteChildren(ctx, r.GVK, children, opts...)
+}
+
 func (r *ListReconciler) ownerRefSetter(ctx action.Context) kotclient.ListSyncProcessFunc {
-	return (func(owner runtimeclient.Object, scheme *apiruntime.Scheme) kotclient.ListSyncProcessFunc {
+	return (func(owner runtimeclient.Object, scheme *apiruntime.Scheme, strategy ownership.Strategy) kotclient.ListSyncProcessFunc {
 		return func(obj runtimeclient.Object) error {
-			return ctrl.SetControllerReference(owner, obj, scheme)
+			// Unstructured children added by the reconcile func might not have it set
+			if u, ok := obj.(*unstructured.Unstructured); ok && u.GetKind() == "" {
+				u.SetGroupVersionKind(r.GVK)
+			}
+			if r.Discriminator != nil {
+				r.Discriminator.Stamp(obj)
+			}
+			if obj.GetUID() == "" {
+				existing, err := r.findAdoptable(ctx, r.Adopt, r.GVK, obj)
+				if err != nil {
+					return errors.Wrap(err, "failed to adopt child object")
+				}
+				if existing != nil {
+					// Children with a UID that were not listed get updated by SyncList
+					ctx.Logger().Info("adopting existing child resource", "child", existing.GetName())
+					obj.SetUID(existing.GetUID())
+					obj.SetResourceVersion(existing.GetResourceVersion())
+				}
+			}
+			return strategy.SetOwner(owner, obj, scheme)
 		}
-	})(ctx.Resource(), r.Scheme)
+	})(ctx.Resource(), r.Scheme, r.OwnershipStrategy())
 }
 
 func (r *ListReconciler) Finalizer() Finalizer {
@@ -72,10 +185,40 @@ func (r *ListReconciler) Finalizer() Finalizer {
 }
 
 type ListReconcilerConfig struct {
-	GVK       kotclient.GVK
+	// Name identifies the reconciler on logs and status conditions, defaults to
+	// the kind of the children objects.
+	Name string
+	GVK  kotclient.GVK
+	// If disables the reconciler when it returns false, in which case all of
+	// its children get deleted.
 	If        ReconcileIfFunc
 	Reconcile ReconcileListFunc
 	Finalize  Finalizer
+
+	// Apply enables writing children with server side apply. In that mode the
+	// reconcile func receives objects that only have their identity set and
+	// only the fields it sets are owned by the controller.
+	Apply *kotclient.ApplyOptions
+	// Patch configures how changes to existing children are sent to the API.
+	Patch kotclient.PatchOptions
+	// Drift configures how changes to existing children are detected.
+	Drift kotclient.DriftOptions
+	// DependsOn lists reconcilers that have to succeed before this one runs.
+	DependsOn []Dependency
+	// Ownership marks children as owned by the resource being reconciled, defaults
+	// to owner references.
+	Ownership ownership.Strategy
+	// Adopt enables taking over existing objects with the names of children
+	// that are not listed yet, defaults to never adopting. Adopted objects are
+	// replaced with the ones built by the reconcile func.
+	Adopt ownership.AdoptionPolicy
+	// Discriminator identifies the children among other children of the same
+	// GVK, it is required when more than one reconciler manages the GVK and
+	// names the reconciler unless Name is set.
+	Discriminator Discriminator
+	// DeletePropagation sets the propagation policy used when children are
+	// deleted because If returned false, defaults to the one of the API.
+	DeletePropagation metav1.DeletionPropagation
 }
 
 type ReconcileListFunc func(ctx action.Context, childList runtimeclient.ObjectList) (action.Result, error)
@@ -89,6 +232,9 @@ func (c *ListReconcilerConfig) Validate() (bool, error) {
 	if c.Reconcile == nil {
 		return false, errors.New("Reconcile func is not set")
 	}
+	if err := validateDependencies(c.DependsOn); err != nil {
+		return false, err
+	}
 
 	return true, nil
 }
@@ -1,18 +1,25 @@
 package reconcile_test
 
 import (
+	"errors"
+
 	"github.com/fgrehm/kot/pkg/action"
 	"github.com/fgrehm/kot/pkg/deps"
 	wkdeps "github.com/fgrehm/kot/pkg/deps/wellknown"
 	"github.com/fgrehm/kot/pkg/kotclient"
 	"github.com/fgrehm/kot/pkg/kottesting/gomock"
+	"github.com/fgrehm/kot/pkg/ownership"
 	"github.com/fgrehm/kot/pkg/reconcile"
 	"github.com/golang/mock/gomock"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/kubernetes/scheme"
 	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 )
 
 var _ = Describe("ListReconciler", func() {
@@ -71,8 +78,8 @@ var _ = Describe("ListReconciler", func() {
 			client.EXPECT().List(gomock.Any(), gomock.Any(), gomock.Any()).
 				SetArg(1, existingList)
 
-			client.EXPECT().SyncList(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
-				Do(func(_ interface{}, listBefore, listAfter runtimeclient.ObjectList, _ interface{}) error {
+			client.EXPECT().SyncList(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+				Do(func(_ interface{}, listBefore, listAfter runtimeclient.ObjectList, _ interface{}, _ ...kotclient.SyncListOption) error {
 					before := listBefore.(*corev1.ConfigMapList)
 					Expect(before.Items).To(HaveLen(1))
 
@@ -86,6 +93,209 @@ var _ = Describe("ListReconciler", func() {
 			Expect(res).To(Equal(action.Result{}))
 		})
 
+		It("bubbles up sync errors and makes the report available", func() {
+			report := &kotclient.SyncListReport{
+				Created: []kotclient.SyncListEntry{{Operation: kotclient.SyncCreate, Key: kotclient.Key{Name: "a"}}},
+				Failed:  []kotclient.SyncListEntry{{Operation: kotclient.SyncCreate, Key: kotclient.Key{Name: "b"}, Err: errors.New("boom")}},
+			}
+			reports := reconcile.NewSyncReports()
+			ctx = action.NewContext(reconcile.NewSyncReportsContext(ctx, reports))
+
+			client.EXPECT().List(gomock.Any(), gomock.Any(), gomock.Any())
+			client.EXPECT().SyncList(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+				Return(report, report.Err())
+
+			_, err := rec.Run(ctx)
+			Expect(err).To(MatchError(`failed to sync list: one or more errors occurred: ["failed to create b: boom"]`))
+			Expect(reconcile.ListSyncReport(ctx, "ConfigMap")).To(Equal(report))
+			Expect(reports.Get("other")).To(BeNil())
+		})
+
+		It("only syncs children picked by the discriminator", func() {
+			rec.Discriminator = reconcile.ByRole("main")
+			existingList := corev1.ConfigMapList{Items: []corev1.ConfigMap{
+				{ObjectMeta: metav1.ObjectMeta{UID: "foo", Labels: map[string]string{reconcile.RoleLabel: "main"}}},
+				{ObjectMeta: metav1.ObjectMeta{UID: "bar", Labels: map[string]string{reconcile.RoleLabel: "other"}}},
+			}}
+
+			client.EXPECT().List(gomock.Any(), gomock.Any(), gomock.Any()).
+				SetArg(1, existingList)
+
+			client.EXPECT().SyncList(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+				Do(func(_ interface{}, listBefore, listAfter runtimeclient.ObjectList, processor kotclient.ListSyncProcessFunc, _ ...kotclient.SyncListOption) error {
+					before := listBefore.(*corev1.ConfigMapList)
+					Expect(before.Items).To(HaveLen(1))
+					Expect(before.Items[0].UID).To(BeEquivalentTo("foo"))
+
+					after := listAfter.(*corev1.ConfigMapList)
+					Expect(after.Items).To(HaveLen(2))
+					Expect(processor(&after.Items[1])).To(Succeed())
+					Expect(after.Items[1].Labels).To(HaveKeyWithValue(reconcile.RoleLabel, "main"))
+					return nil
+				})
+
+			_, err := rec.Run(ctx)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("syncs lists using server side apply if enabled", func() {
+			rec.Apply = &kotclient.ApplyOptions{}
+			existingCm := &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{UID: "foo", Name: "cm", Labels: map[string]string{"set-by": "someone-else"}},
+			}
+			existingList := corev1.ConfigMapList{Items: []corev1.ConfigMap{*existingCm}}
+
+			client.EXPECT().List(gomock.Any(), gomock.Any(), gomock.Any()).
+				SetArg(1, existingList)
+
+			client.EXPECT().SyncList(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+				Do(func(_ interface{}, listBefore, listAfter runtimeclient.ObjectList, _ interface{}, opts ...kotclient.SyncListOption) error {
+					before := listBefore.(*corev1.ConfigMapList)
+					Expect(before.Items).To(HaveLen(1))
+					Expect(before.Items[0].Labels).NotTo(BeEmpty())
+
+					after := listAfter.(*corev1.ConfigMapList)
+					Expect(after.Items).To(HaveLen(2))
+					Expect(after.Items[0].UID).To(Equal(existingCm.UID))
+					Expect(after.Items[0].Name).To(Equal(existingCm.Name))
+					Expect(after.Items[0].Labels).To(BeEmpty())
+
+					Expect(opts).To(HaveLen(4))
+					return nil
+				})
+
+			res, err := rec.Run(ctx)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(res).To(Equal(action.Result{}))
+		})
+
+		It("updates children whose fields were removed", func() {
+			existingCm := corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{UID: "foo", Name: "cm", Namespace: "ns", Labels: map[string]string{"removed": "true"}},
+				Data:       map[string]string{"kept": "true", "removed": "true"},
+			}
+			sa.Name, sa.UID = "sa", "sa-uid"
+			Expect(controllerutil.SetControllerReference(sa, &existingCm, scheme.Scheme)).To(Succeed())
+			rec.Reconcile = func(ctx action.Context, list runtimeclient.ObjectList) (action.Result, error) {
+				cm := &list.(*corev1.ConfigMapList).Items[0]
+				delete(cm.Data, "removed")
+				delete(cm.Labels, "removed")
+				return action.Result{}, nil
+			}
+			store := fake.NewClientBuilder().WithObjects(existingCm.DeepCopy()).Build()
+
+			client.EXPECT().List(gomock.Any(), gomock.Any(), gomock.Any()).
+				SetArg(1, corev1.ConfigMapList{Items: []corev1.ConfigMap{existingCm}})
+			client.EXPECT().SyncList(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+				DoAndReturn(kotclient.Decorate(store).SyncList)
+
+			_, err := rec.Run(ctx)
+			Expect(err).NotTo(HaveOccurred())
+
+			updated := &corev1.ConfigMap{}
+			Expect(store.Get(ctx, runtimeclient.ObjectKeyFromObject(&existingCm), updated)).To(Succeed())
+			Expect(updated.Data).To(Equal(map[string]string{"kept": "true"}))
+			Expect(updated.Labels).To(BeEmpty())
+		})
+
+		It("sets the GVK of unstructured children of kinds not registered on the scheme", func() {
+			rec.GVK = kotclient.GVK{Group: "example.com", Version: "v1", Kind: "Widget"}
+			rec.Reconcile = func(ctx action.Context, list runtimeclient.ObjectList) (action.Result, error) {
+				widgets := list.(*unstructured.UnstructuredList)
+				widgets.Items = append(widgets.Items, unstructured.Unstructured{Object: map[string]interface{}{}})
+				return action.Result{}, nil
+			}
+
+			client.EXPECT().List(gomock.Any(), gomock.Any(), gomock.Any())
+			client.EXPECT().SyncList(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+				Do(func(_ interface{}, _, listAfter runtimeclient.ObjectList, processor kotclient.ListSyncProcessFunc, _ ...kotclient.SyncListOption) error {
+					widget := &listAfter.(*unstructured.UnstructuredList).Items[0]
+					Expect(processor(widget)).To(Succeed())
+					Expect(widget.GroupVersionKind()).To(Equal(rec.GVK))
+					Expect(widget.GetOwnerReferences()).To(HaveLen(1))
+					return nil
+				})
+
+			_, err := rec.Run(ctx)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("adopts existing objects with the names of new children", func() {
+			rec.Adopt = ownership.AdoptIfAnnotated
+			rec.Reconcile = func(ctx action.Context, list runtimeclient.ObjectList) (action.Result, error) {
+				cmList := list.(*corev1.ConfigMapList)
+				cmList.Items = append(cmList.Items, corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "cm", Namespace: "ns"}})
+				return action.Result{}, nil
+			}
+			existingCm := corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{
+				Name:            "cm",
+				Namespace:       "ns",
+				UID:             "cm-uid",
+				ResourceVersion: "3",
+				Annotations:     map[string]string{ownership.AdoptAnnotation: "true"},
+			}}
+
+			client.EXPECT().List(gomock.Any(), gomock.Any(), gomock.Any())
+			client.EXPECT().Get(gomock.Any(), kotclient.Key{Namespace: "ns", Name: "cm"}, gomock.Any()).SetArg(2, existingCm)
+			client.EXPECT().SyncList(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+				Do(func(_ interface{}, _, listAfter runtimeclient.ObjectList, processor kotclient.ListSyncProcessFunc, _ ...kotclient.SyncListOption) error {
+					cm := &listAfter.(*corev1.ConfigMapList).Items[0]
+					Expect(processor(cm)).To(Succeed())
+					Expect(cm.UID).To(BeEquivalentTo("cm-uid"))
+					Expect(cm.ResourceVersion).To(Equal("3"))
+					Expect(cm.GetOwnerReferences()).To(HaveLen(1))
+					return nil
+				})
+
+			_, err := rec.Run(ctx)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		Context("If returns false", func() {
+			BeforeEach(func() {
+				rec.If = func(ctx action.Context) (bool, error) {
+					return false, nil
+				}
+				rec.Reconcile = func(ctx action.Context, list runtimeclient.ObjectList) (action.Result, error) {
+					panic("should not be called")
+				}
+			})
+
+			It("deletes all children", func() {
+				rec.DeletePropagation = metav1.DeletePropagationForeground
+				existingList := corev1.ConfigMapList{Items: []corev1.ConfigMap{
+					{ObjectMeta: metav1.ObjectMeta{UID: "foo", Name: "foo"}},
+					{ObjectMeta: metav1.ObjectMeta{UID: "bar", Name: "bar", DeletionTimestamp: &now}},
+					{ObjectMeta: metav1.ObjectMeta{UID: "baz", Name: "baz"}},
+				}}
+
+				client.EXPECT().List(gomock.Any(), gomock.Any(), gomock.Any()).
+					SetArg(1, existingList)
+				deleted := []string{}
+				client.EXPECT().Delete(gomock.Any(), gomock.Any(), runtimeclient.PropagationPolicy(metav1.DeletePropagationForeground)).
+					Do(func(_ interface{}, obj runtimeclient.Object, _ ...interface{}) error {
+						deleted = append(deleted, obj.GetName())
+						return nil
+					}).Times(2)
+
+				res, err := rec.Run(ctx)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(res).To(Equal(action.Result{}))
+				Expect(deleted).To(Equal([]string{"foo", "baz"}))
+			})
+
+			It("bubbles up errors", func() {
+				existingList := corev1.ConfigMapList{Items: []corev1.ConfigMap{{ObjectMeta: metav1.ObjectMeta{UID: "foo"}}}}
+
+				client.EXPECT().List(gomock.Any(), gomock.Any(), gomock.Any()).
+					SetArg(1, existingList)
+				client.EXPECT().Delete(gomock.Any(), gomock.Any()).Return(errors.New("boom"))
+
+				_, err := rec.Run(ctx)
+				Expect(err).To(MatchError("failed to delete child object: boom"))
+			})
+		})
+
 		It("does not reconcile if parent resource is being deleted", func() {
 			rec.Reconcile = func(ctx action.Context, list runtimeclient.ObjectList) (action.Result, error) {
 				panic("should not be called")
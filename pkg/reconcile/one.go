@@ -6,9 +6,8 @@ import (
 	"github.com/fgrehm/kot/pkg/action"
 	"github.com/fgrehm/kot/pkg/deps"
 	"github.com/fgrehm/kot/pkg/kotclient"
+	"github.com/fgrehm/kot/pkg/ownership"
 	"github.com/pkg/errors"
-	"k8s.io/apimachinery/pkg/api/equality"
-	ctrl "sigs.k8s.io/controller-runtime"
 	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
 )
 
@@ -19,23 +18,45 @@ type OneReconciler struct {
 
 var _ ResourceReconciler = &OneReconciler{}
 var _ deps.DepsInjector = &OneReconciler{}
+var _ DiscriminatedReconciler = &OneReconciler{}
 
 func (r *OneReconciler) OwnedGVK() kotclient.GVK {
 	return r.GVK
 }
 
+func (r *OneReconciler) ReconcilerName() string {
+	if r.Name != "" {
+		return r.Name
+	}
+	return discriminatedName(r.GVK.Kind, r.Discriminator)
+}
+
+func (r *OneReconciler) ChildDiscriminator() Discriminator {
+	return r.Discriminator
+}
+
+func (r *OneReconciler) Dependencies() []Dependency {
+	return r.DependsOn
+}
+
+func (r *OneReconciler) OwnershipStrategy() ownership.Strategy {
+	return ownership.Default(r.Ownership)
+}
+
+func (r *OneReconciler) SpanName() string {
+	return r.ReconcilerName()
+}
+
 func (r *OneReconciler) Run(originalCtx action.Context) (action.Result, error) {
 	if !originalCtx.Resource().GetDeletionTimestamp().IsZero() {
 		return action.Result{}, nil
 	}
 
 	var (
-		gvk       = r.GVK
-		ctx       = originalCtx.WithLoggerValues("owned-gvk", gvk.String())
-		client    = r.Client
-		parentObj = ctx.Resource()
-		scheme    = r.Scheme
-		log       = ctx.Logger()
+		gvk    = r.GVK
+		ctx    = originalCtx.WithLoggerValues("owned-gvk", gvk.String())
+		client = r.Client
+		log    = ctx.Logger()
 	)
 
 	log.Info("reconciling one")
@@ -56,49 +77,119 @@ func (r *OneReconciler) Run(originalCtx action.Context) (action.Result, error) {
 			return action.Result{}, nil
 		} else {
 			log.Info("deleting child resource")
-			if err := client.Delete(ctx, childObj); err != nil {
+			err := client.Delete(ctx, childObj)
+			childWritten(ctx, kotclient.SyncDelete, gvk, childObj, err)
+			if err != nil {
 				return action.Result{}, errors.Wrap(err, "failed to delete child object")
 			}
 		}
 		return action.Result{}, nil
 	}
 
-	objToReconcile := childObj.DeepCopyObject().(runtimeclient.Object)
-	result, err := r.Reconcile(ctx, objToReconcile)
+	objToReconcile, result, err := r.reconcileChild(ctx, childObj)
 	if err != nil {
-		return result, errors.Wrap(err, "failed to reconcile child object")
+		return result, err
+	}
+
+	if childObj.GetUID() == "" {
+		existing, err := r.findAdoptable(ctx, r.Adopt, gvk, objToReconcile)
+		if err != nil {
+			return action.Result{}, errors.Wrap(err, "failed to adopt child object")
+		}
+		if existing != nil {
+			// Replace the existing object with the one built by the reconcile func
+			log.Info("adopting existing child resource", "child", existing.GetName())
+			childObj = existing
+			objToReconcile.SetUID(existing.GetUID())
+			objToReconcile.SetResourceVersion(existing.GetResourceVersion())
+		}
 	}
 
-	if err := ctrl.SetControllerReference(parentObj, objToReconcile, scheme); err != nil {
-		return action.Result{}, errors.Wrap(err, "failed to set controller reference for child object")
+	driftOpts := driftOptions(ctx, r.Drift, r.Apply)
+	if err := kotclient.StampLastAppliedHash(objToReconcile, driftOpts); err != nil {
+		return result, errors.Wrap(err, "failed to stamp child object")
+	}
+
+	var drift kotclient.Drift
+	if childObj.GetUID() != "" {
+		if drift, err = kotclient.DetectDrift(childObj, objToReconcile, driftOpts); err != nil {
+			return result, errors.Wrap(err, "failed to detect changes to child object")
+		}
+		if !drift.Changed() {
+			log.V(lDebug).Info("obj didn't change, skipping upsert")
+			return result, nil
+		}
 	}
 
-	if equality.Semantic.DeepEqual(childObj, objToReconcile) {
-		log.V(lDebug).Info("obj didn't change, skipping upsert")
+	if r.Apply != nil {
+		op := kotclient.SyncUpdate
+		if childObj.GetUID() == "" {
+			log.Info("applying child resource")
+			op = kotclient.SyncCreate
+		} else {
+			log.Info("applying child resource", "diff", drift.String())
+		}
+		err := client.Apply(ctx, objToReconcile, *r.Apply)
+		childWritten(ctx, op, gvk, objToReconcile, err)
+		if err != nil {
+			return result, errors.Wrap(err, "failed to apply child object")
+		}
 		return result, nil
 	}
 
 	if childObj.GetUID() == "" {
 		log.Info("creating child resource")
-		if err := client.Create(ctx, objToReconcile); err != nil {
+		err := client.Create(ctx, objToReconcile)
+		childWritten(ctx, kotclient.SyncCreate, gvk, objToReconcile, err)
+		if err != nil {
 			return result, errors.Wrap(err, "failed to create child object")
 		}
 		return result, nil
 	}
 
-	log.Info("updating child resource")
-	if err := client.Update(ctx, objToReconcile); err != nil {
+	log.Info("updating child resource", "diff", drift.String())
+	err = client.PatchObject(ctx, childObj, objToReconcile, r.Patch)
+	childWritten(ctx, kotclient.SyncUpdate, gvk, objToReconcile, err)
+	if err != nil {
 		return result, errors.Wrap(err, "failed to update child object")
 	}
 
 	return result, nil
 }
 
+// reconcileChild runs the reconcile func against a copy of childObj and marks
+// it as owned by the resource being reconciled.
+func (r *OneReconciler) reconcileChild(ctx action.Context, childObj runtimeclient.Object) (runtimeclient.Object, action.Result, error) {
+	objToReconcile := childObj.DeepCopyObject().(runtimeclient.Object)
+	if r.Apply != nil {
+		// Start from scratch so that only fields set by the reconcile func are owned
+		var err error
+		if objToReconcile, err = r.newApplyObject(r.GVK, childObj); err != nil {
+			return nil, action.Result{}, err
+		}
+	}
+
+	result, err := r.Reconcile(ctx, objToReconcile)
+	if err != nil {
+		return nil, result, errors.Wrap(err, "failed to reconcile child object")
+	}
+
+	if r.Discriminator != nil {
+		r.Discriminator.Stamp(objToReconcile)
+	}
+	if err := r.OwnershipStrategy().SetOwner(ctx.Resource(), objToReconcile, r.Scheme); err != nil {
+		return nil, action.Result{}, errors.Wrap(err, "failed to set owner of child object")
+	}
+
+	return objToReconcile, result, nil
+}
+
 func (r *OneReconciler) getOrInitializeChildObj(ctx action.Context) (runtimeclient.Object, error) {
 	children, err := r.fetchChildren(ctx, r.GVK)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to fetch children resources")
 	}
+	children = discriminate(r.Discriminator, children)
 
 	if len(children) > 1 {
 		return nil, fmt.Errorf("resource has %d of '%s' children, expected at most one", len(children), r.GVK)
@@ -130,10 +221,35 @@ func (r *OneReconciler) Finalizer() Finalizer {
 }
 
 type OneReconcilerConfig struct {
+	// Name identifies the reconciler on logs and status conditions, defaults to
+	// the kind of the child object.
+	Name      string
 	GVK       kotclient.GVK
 	If        ReconcileIfFunc
 	Reconcile ReconcileOneFunc
 	Finalize  Finalizer
+
+	// Apply enables writing the child with server side apply. In that mode the
+	// reconcile func receives an object that only has its name and namespace
+	// set and only the fields it sets are owned by the controller.
+	Apply *kotclient.ApplyOptions
+	// Patch configures how changes to an existing child are sent to the API.
+	Patch kotclient.PatchOptions
+	// Drift configures how changes to an existing child are detected.
+	Drift kotclient.DriftOptions
+	// DependsOn lists reconcilers that have to succeed before this one runs.
+	DependsOn []Dependency
+	// Ownership marks the child as owned by the resource being reconciled, defaults
+	// to owner references.
+	Ownership ownership.Strategy
+	// Adopt enables taking over an existing object with the name of the child
+	// when there is none yet, defaults to never adopting. Adopted objects are
+	// replaced with the one built by the reconcile func.
+	Adopt ownership.AdoptionPolicy
+	// Discriminator identifies the child among other children of the same GVK,
+	// it is required when more than one reconciler manages the GVK and names
+	// the reconciler unless Name is set.
+	Discriminator Discriminator
 }
 
 type ReconcileOneFunc func(ctx action.Context, childObj runtimeclient.Object) (action.Result, error)
@@ -147,6 +263,9 @@ func (c *OneReconcilerConfig) Validate() (bool, error) {
 	if c.Reconcile == nil {
 		return false, errors.New("Reconcile func is not set")
 	}
+	if err := validateDependencies(c.DependsOn); err != nil {
+		return false, err
+	}
 
 	return true, nil
 }
@@ -8,12 +8,18 @@ import (
 	wkdeps "github.com/fgrehm/kot/pkg/deps/wellknown"
 	"github.com/fgrehm/kot/pkg/kotclient"
 	"github.com/fgrehm/kot/pkg/kottesting/gomock"
+	"github.com/fgrehm/kot/pkg/metrics"
+	"github.com/fgrehm/kot/pkg/ownership"
 	"github.com/fgrehm/kot/pkg/reconcile"
 	"github.com/golang/mock/gomock"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
 )
@@ -26,7 +32,8 @@ var _ = Describe("OneReconciler", func() {
 
 			rec *reconcile.OneReconciler
 
-			client *kotmocks.MockClient
+			client   *kotmocks.MockClient
+			recorder *record.FakeRecorder
 
 			sa  *corev1.ServiceAccount
 			now metav1.Time
@@ -59,7 +66,8 @@ var _ = Describe("OneReconciler", func() {
 			deps.Inject(ctn, rec)
 
 			sa = &corev1.ServiceAccount{}
-			ctx = ctx.WithResource(sa)
+			recorder = record.NewFakeRecorder(10)
+			ctx = action.NewContext(action.WithEventRecorder(ctx.WithResource(sa), recorder))
 		})
 
 		AfterEach(func() {
@@ -74,12 +82,30 @@ var _ = Describe("OneReconciler", func() {
 					cm := obj.(*corev1.ConfigMap)
 					Expect(cm.Data).To(Equal(map[string]string{"foo": "bar"}))
 					Expect(cm.GetOwnerReferences()).To(HaveLen(1))
+					cm.Name = "created"
 					return nil
 				})
 
 				res, err := rec.Run(ctx)
 				Expect(err).NotTo(HaveOccurred())
 				Expect(res).To(Equal(action.Result{}))
+				Expect(recorder.Events).To(Receive(Equal("Normal ChildCreated Created ConfigMap created")))
+			})
+
+			It("only records planned creates on the plan", func() {
+				plan := &kotclient.Plan{}
+				ctx = action.NewContext(kotclient.WithPlan(ctx, plan))
+				rec.Client = kotclient.Decorate(client)
+				client.EXPECT().Scheme().Return(scheme.Scheme).AnyTimes()
+				client.EXPECT().List(gomock.Any(), gomock.Any(), gomock.Any())
+				creates := metrics.ChildOperations.WithLabelValues("", "", "v1", "ConfigMap", string(kotclient.SyncCreate), metrics.ResultSuccess)
+				before := testutil.ToFloat64(creates)
+
+				_, err := rec.Run(ctx)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(plan.Changes()).To(HaveLen(1))
+				Expect(recorder.Events).NotTo(Receive())
+				Expect(testutil.ToFloat64(creates)).To(Equal(before))
 			})
 
 			It("bubbles up error if creation fails", func() {
@@ -91,6 +117,7 @@ var _ = Describe("OneReconciler", func() {
 				res, err := rec.Run(ctx)
 				Expect(err).To(MatchError("failed to create child object: boom"))
 				Expect(res).To(Equal(action.Result{}))
+				Expect(recorder.Events).To(Receive(HavePrefix("Warning ChildCreateFailed")))
 			})
 
 			It("does not error if expected to be deleted", func() {
@@ -119,16 +146,75 @@ var _ = Describe("OneReconciler", func() {
 				client.EXPECT().List(gomock.Any(), gomock.Any(), gomock.Any()).
 					SetArg(1, corev1.ConfigMapList{Items: []corev1.ConfigMap{*existingCm}})
 
-				client.EXPECT().Update(gomock.Any(), gomock.Any()).Do(func(_ interface{}, obj runtimeclient.Object, _ ...interface{}) error {
+				client.EXPECT().PatchObject(gomock.Any(), gomock.Any(), gomock.Any(), kotclient.PatchOptions{}).Do(func(_ interface{}, before, obj runtimeclient.Object, _ kotclient.PatchOptions) error {
+					Expect(before.(*corev1.ConfigMap).Data).To(BeEmpty())
+
 					cm := obj.(*corev1.ConfigMap)
 					Expect(cm.Data).To(Equal(map[string]string{"foo": "bar"}))
 					Expect(cm.GetOwnerReferences()).To(HaveLen(1))
+					cm.Name = "existing"
 					return nil
 				})
 
 				res, err := rec.Run(ctx)
 				Expect(err).NotTo(HaveOccurred())
 				Expect(res).To(Equal(action.Result{}))
+				Expect(recorder.Events).To(Receive(Equal("Normal ChildUpdated Updated ConfigMap existing")))
+			})
+
+			It("triggers an update if fields are removed from the child resource", func() {
+				existingCm.Data = map[string]string{"foo": "bar", "removed": "true"}
+				existingCm.Labels = map[string]string{"removed": "true"}
+				sa.Name, sa.UID = "sa", "sa-uid"
+				Expect(ctrl.SetControllerReference(sa, existingCm, scheme.Scheme)).To(Succeed())
+				rec.Reconcile = func(ctx action.Context, obj runtimeclient.Object) (action.Result, error) {
+					cm := obj.(*corev1.ConfigMap)
+					delete(cm.Data, "removed")
+					delete(cm.Labels, "removed")
+					return action.Result{}, nil
+				}
+
+				client.EXPECT().List(gomock.Any(), gomock.Any(), gomock.Any()).
+					SetArg(1, corev1.ConfigMapList{Items: []corev1.ConfigMap{*existingCm}})
+				client.EXPECT().PatchObject(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Do(func(_ interface{}, _, obj runtimeclient.Object, _ kotclient.PatchOptions) error {
+					cm := obj.(*corev1.ConfigMap)
+					Expect(cm.Data).To(Equal(map[string]string{"foo": "bar"}))
+					Expect(cm.Labels).To(BeEmpty())
+					return nil
+				})
+
+				_, err := rec.Run(ctx)
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			It("skips the update if only fields it does not set differ with a field manager", func() {
+				rec.Drift = kotclient.DriftOptions{FieldManager: "kot"}
+				existingCm.Data = map[string]string{"foo": "bar"}
+				existingCm.Labels = map[string]string{"defaulted": "true"}
+				sa.Name, sa.UID = "sa", "sa-uid"
+				Expect(ctrl.SetControllerReference(sa, existingCm, scheme.Scheme)).To(Succeed())
+				rec.Reconcile = func(ctx action.Context, obj runtimeclient.Object) (action.Result, error) {
+					cm := obj.(*corev1.ConfigMap)
+					cm.Labels = nil
+					cm.Data = map[string]string{"foo": "bar"}
+					return action.Result{}, nil
+				}
+
+				client.EXPECT().List(gomock.Any(), gomock.Any(), gomock.Any()).
+					SetArg(1, corev1.ConfigMapList{Items: []corev1.ConfigMap{*existingCm}})
+
+				_, err := rec.Run(ctx)
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			It("does not compare ignored fields", func() {
+				rec.Drift = kotclient.DriftOptions{Ignore: []string{"data", "metadata.ownerReferences"}}
+
+				client.EXPECT().List(gomock.Any(), gomock.Any(), gomock.Any()).
+					SetArg(1, corev1.ConfigMapList{Items: []corev1.ConfigMap{*existingCm}})
+
+				_, err := rec.Run(ctx)
+				Expect(err).NotTo(HaveOccurred())
 			})
 
 			It("ignores child resources being deleted", func() {
@@ -146,7 +232,7 @@ var _ = Describe("OneReconciler", func() {
 				client.EXPECT().List(gomock.Any(), gomock.Any(), gomock.Any()).
 					SetArg(1, corev1.ConfigMapList{Items: []corev1.ConfigMap{*existingCm}})
 
-				client.EXPECT().Update(gomock.Any(), gomock.Any()).Return(errors.New("boom"))
+				client.EXPECT().PatchObject(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(errors.New("boom"))
 
 				res, err := rec.Run(ctx)
 				Expect(err).To(MatchError("failed to update child object: boom"))
@@ -167,6 +253,45 @@ var _ = Describe("OneReconciler", func() {
 			})
 		})
 
+		Context("server side apply", func() {
+			BeforeEach(func() {
+				rec.Apply = &kotclient.ApplyOptions{Force: true}
+			})
+
+			It("applies the child resource with only the fields that were set", func() {
+				existingCm := &corev1.ConfigMap{
+					ObjectMeta: ctrl.ObjectMeta{UID: "foo", Name: "cm", Namespace: "ns", Labels: map[string]string{"set-by": "someone-else"}},
+				}
+				client.EXPECT().List(gomock.Any(), gomock.Any(), gomock.Any()).
+					SetArg(1, corev1.ConfigMapList{Items: []corev1.ConfigMap{*existingCm}})
+
+				client.EXPECT().Apply(gomock.Any(), gomock.Any(), kotclient.ApplyOptions{Force: true}).Do(func(_ interface{}, obj runtimeclient.Object, _ kotclient.ApplyOptions) error {
+					cm := obj.(*corev1.ConfigMap)
+					Expect(cm.Name).To(Equal("cm"))
+					Expect(cm.Namespace).To(Equal("ns"))
+					Expect(cm.Labels).To(BeEmpty())
+					Expect(cm.Data).To(Equal(map[string]string{"foo": "bar"}))
+					Expect(cm.GetOwnerReferences()).To(HaveLen(1))
+					cm.Name = "applied"
+					return nil
+				})
+
+				res, err := rec.Run(ctx)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(res).To(Equal(action.Result{}))
+				Expect(recorder.Events).To(Receive(Equal("Normal ChildUpdated Updated ConfigMap applied")))
+			})
+
+			It("bubbles up error if apply fails", func() {
+				client.EXPECT().List(gomock.Any(), gomock.Any(), gomock.Any())
+				client.EXPECT().Apply(gomock.Any(), gomock.Any(), gomock.Any()).Return(errors.New("boom"))
+
+				res, err := rec.Run(ctx)
+				Expect(err).To(MatchError("failed to apply child object: boom"))
+				Expect(res).To(Equal(action.Result{}))
+			})
+		})
+
 		Context("parent resource is being deleted", func() {
 			It("does not reconcile", func() {
 				sa.DeletionTimestamp = &now
@@ -177,6 +302,135 @@ var _ = Describe("OneReconciler", func() {
 			})
 		})
 
+		Context("child owned through labels", func() {
+			It("creates the child resource without owner references", func() {
+				rec.Ownership = ownership.Labels
+				sa.UID = "sa-uid"
+
+				client.EXPECT().List(gomock.Any(), gomock.Any(), gomock.Any())
+				client.EXPECT().Create(gomock.Any(), gomock.Any()).Do(func(_ interface{}, obj runtimeclient.Object, _ ...interface{}) error {
+					Expect(obj.GetOwnerReferences()).To(BeEmpty())
+					Expect(obj.GetLabels()).To(HaveKeyWithValue(ownership.OwnerUIDKey, "sa-uid"))
+					return nil
+				})
+
+				_, err := rec.Run(ctx)
+				Expect(err).NotTo(HaveOccurred())
+			})
+		})
+
+		Context("adopting existing children", func() {
+			BeforeEach(func() {
+				rec.Adopt = ownership.AdoptIfUnowned
+				rec.Reconcile = func(ctx action.Context, obj runtimeclient.Object) (action.Result, error) {
+					cm := obj.(*corev1.ConfigMap)
+					cm.Name, cm.Namespace = "cm", "ns"
+					cm.Data = map[string]string{"foo": "bar"}
+					return action.Result{}, nil
+				}
+				sa.Name, sa.Namespace, sa.UID = "sa", "ns", "sa-uid"
+			})
+
+			It("adopts an unowned object with the name of the child", func() {
+				existingCm := corev1.ConfigMap{
+					ObjectMeta: metav1.ObjectMeta{Name: "cm", Namespace: "ns", UID: "cm-uid"},
+					Data:       map[string]string{"foo": "baz"},
+				}
+
+				reconcileCalls := 0
+				reconcile := rec.Reconcile
+				rec.Reconcile = func(ctx action.Context, obj runtimeclient.Object) (action.Result, error) {
+					reconcileCalls++
+					return reconcile(ctx, obj)
+				}
+
+				client.EXPECT().List(gomock.Any(), gomock.Any(), gomock.Any())
+				client.EXPECT().Get(gomock.Any(), kotclient.Key{Namespace: "ns", Name: "cm"}, gomock.Any()).SetArg(2, existingCm)
+				client.EXPECT().PatchObject(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Do(func(_ interface{}, before, after runtimeclient.Object, _ interface{}) error {
+					Expect(before.GetUID()).To(BeEquivalentTo("cm-uid"))
+					Expect(after.GetUID()).To(BeEquivalentTo("cm-uid"))
+					Expect(after.(*corev1.ConfigMap).Data).To(Equal(map[string]string{"foo": "bar"}))
+					Expect(metav1.GetControllerOf(after).UID).To(BeEquivalentTo("sa-uid"))
+					return nil
+				})
+
+				_, err := rec.Run(ctx)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(reconcileCalls).To(Equal(1))
+			})
+
+			It("creates the child if there is nothing to adopt", func() {
+				client.EXPECT().List(gomock.Any(), gomock.Any(), gomock.Any())
+				client.EXPECT().Get(gomock.Any(), gomock.Any(), gomock.Any()).Return(kotclient.NewNotFound(kotclient.GR{}, "cm"))
+				client.EXPECT().Create(gomock.Any(), gomock.Any())
+
+				_, err := rec.Run(ctx)
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			It("refuses to adopt objects controlled by someone else", func() {
+				existingCm := corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "cm", Namespace: "ns", UID: "cm-uid"}}
+				other := &corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "ns", UID: "other-uid"}}
+				Expect(ctrl.SetControllerReference(other, &existingCm, scheme.Scheme)).To(Succeed())
+
+				client.EXPECT().List(gomock.Any(), gomock.Any(), gomock.Any())
+				client.EXPECT().Get(gomock.Any(), gomock.Any(), gomock.Any()).SetArg(2, existingCm)
+
+				_, err := rec.Run(ctx)
+				Expect(err).To(MatchError("failed to adopt child object: refusing to adopt ns/cm: it is controlled by ServiceAccount other"))
+			})
+
+			It("does not look up existing objects if adoption is disabled", func() {
+				rec.Adopt = ownership.AdoptNever
+
+				client.EXPECT().List(gomock.Any(), gomock.Any(), gomock.Any())
+				client.EXPECT().Create(gomock.Any(), gomock.Any())
+
+				_, err := rec.Run(ctx)
+				Expect(err).NotTo(HaveOccurred())
+			})
+		})
+
+		Context("child kind is not registered on the scheme", func() {
+			BeforeEach(func() {
+				rec.GVK = kotclient.GVK{Group: "example.com", Version: "v1", Kind: "Widget"}
+				rec.Reconcile = func(ctx action.Context, obj runtimeclient.Object) (action.Result, error) {
+					return action.Result{}, unstructured.SetNestedField(obj.(*unstructured.Unstructured).Object, "bar", "spec", "foo")
+				}
+			})
+
+			It("creates an unstructured child resource", func() {
+				client.EXPECT().List(gomock.Any(), gomock.Any(), gomock.Any()).Do(func(_ interface{}, list runtimeclient.ObjectList, _ ...interface{}) error {
+					Expect(list.GetObjectKind().GroupVersionKind().Kind).To(Equal("WidgetList"))
+					return nil
+				})
+				client.EXPECT().Create(gomock.Any(), gomock.Any()).Do(func(_ interface{}, obj runtimeclient.Object, _ ...interface{}) error {
+					widget := obj.(*unstructured.Unstructured)
+					Expect(widget.GroupVersionKind()).To(Equal(rec.GVK))
+					Expect(widget.Object).To(HaveKeyWithValue("spec", map[string]interface{}{"foo": "bar"}))
+					Expect(widget.GetOwnerReferences()).To(HaveLen(1))
+					return nil
+				})
+
+				_, err := rec.Run(ctx)
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			It("deletes the unstructured child resource", func() {
+				sa.Name = "delete"
+				existing := unstructured.Unstructured{}
+				existing.SetGroupVersionKind(rec.GVK)
+				existing.SetUID("foo")
+
+				client.EXPECT().List(gomock.Any(), gomock.Any(), gomock.Any()).
+					SetArg(1, unstructured.UnstructuredList{Items: []unstructured.Unstructured{existing}})
+				client.EXPECT().Delete(gomock.Any(), &existing)
+
+				_, err := rec.Run(ctx)
+				Expect(err).NotTo(HaveOccurred())
+			})
+		})
+
 		Context("multiple resources exist", func() {
 			It("returns an error", func() {
 				existingCms := corev1.ConfigMapList{Items: []corev1.ConfigMap{
@@ -191,6 +445,44 @@ var _ = Describe("OneReconciler", func() {
 				Expect(err).To(MatchError("resource has 2 of '/v1, Kind=ConfigMap' children, expected at most one"))
 				Expect(res).To(Equal(action.Result{}))
 			})
+
+			It("only reconciles the child picked by the discriminator", func() {
+				rec.Discriminator = reconcile.ByRole("main")
+				existingCms := corev1.ConfigMapList{Items: []corev1.ConfigMap{
+					{ObjectMeta: ctrl.ObjectMeta{UID: "foo", Labels: map[string]string{reconcile.RoleLabel: "other"}}},
+					{ObjectMeta: ctrl.ObjectMeta{UID: "bar", Labels: map[string]string{reconcile.RoleLabel: "main"}}},
+				}}
+
+				client.EXPECT().List(gomock.Any(), gomock.Any(), gomock.Any()).
+					SetArg(1, existingCms)
+				client.EXPECT().PatchObject(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Do(func(_ interface{}, before, after runtimeclient.Object, _ interface{}) error {
+					Expect(before.GetUID()).To(BeEquivalentTo("bar"))
+					Expect(after.GetLabels()).To(HaveKeyWithValue(reconcile.RoleLabel, "main"))
+					return nil
+				})
+
+				_, err := rec.Run(ctx)
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			It("deletes only the child picked by the discriminator", func() {
+				sa.Name = "delete"
+				rec.Discriminator = reconcile.ByName("main")
+				existingCms := corev1.ConfigMapList{Items: []corev1.ConfigMap{
+					{ObjectMeta: ctrl.ObjectMeta{UID: "foo", Name: "other"}},
+					{ObjectMeta: ctrl.ObjectMeta{UID: "bar", Name: "main"}},
+				}}
+
+				client.EXPECT().List(gomock.Any(), gomock.Any(), gomock.Any()).
+					SetArg(1, existingCms)
+				client.EXPECT().Delete(gomock.Any(), gomock.Any()).Do(func(_ interface{}, obj runtimeclient.Object, _ ...interface{}) error {
+					Expect(obj.GetName()).To(Equal("main"))
+					return nil
+				})
+
+				_, err := rec.Run(ctx)
+				Expect(err).NotTo(HaveOccurred())
+			})
 		})
 	})
 
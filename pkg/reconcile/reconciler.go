@@ -2,14 +2,16 @@ package reconcile
 
 import (
 	"fmt"
+	"reflect"
 
 	"github.com/fgrehm/kot/pkg/action"
 	"github.com/fgrehm/kot/pkg/deps"
 	wkdeps "github.com/fgrehm/kot/pkg/deps/wellknown"
 	"github.com/fgrehm/kot/pkg/indexing"
 	"github.com/fgrehm/kot/pkg/kotclient"
+	"github.com/fgrehm/kot/pkg/metrics"
+	"github.com/fgrehm/kot/pkg/ownership"
 	"github.com/pkg/errors"
-	"k8s.io/apimachinery/pkg/api/equality"
 	apiruntime "k8s.io/apimachinery/pkg/runtime"
 	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
 )
@@ -27,6 +29,85 @@ type ResourceReconciler interface {
 	InjectDeps(ctn deps.Container)
 }
 
+// OwningReconciler is implemented by reconcilers that mark the objects they
+// manage as owned by the resource being reconciled.
+type OwningReconciler interface {
+	ResourceReconciler
+	OwnershipStrategy() ownership.Strategy
+}
+
+// NamedReconciler is implemented by reconcilers that can be identified by name
+type NamedReconciler interface {
+	Reconciler
+	ReconcilerName() string
+}
+
+// ReconcilerName returns the name of a reconciler, falling back to the name of
+// its type for reconcilers that are not named.
+func ReconcilerName(r Reconciler) string {
+	if named, ok := r.(NamedReconciler); ok {
+		return named.ReconcilerName()
+	}
+	t := reflect.TypeOf(r)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.Name()
+}
+
+// DiscriminatedReconciler is implemented by reconcilers that can tell their
+// children apart from the ones of other reconcilers of the same GVK.
+type DiscriminatedReconciler interface {
+	ResourceReconciler
+	ChildDiscriminator() Discriminator
+}
+
+// ValidateDiscriminators checks that reconcilers managing the same GVK tell
+// their children apart with distinct discriminators and names.
+func ValidateDiscriminators(reconcilers []Reconciler) error {
+	byGVK := map[kotclient.GVK][]ResourceReconciler{}
+	gvks := []kotclient.GVK{}
+	for _, r := range reconcilers {
+		rec, ok := r.(ResourceReconciler)
+		if !ok {
+			continue
+		}
+		gvk := rec.OwnedGVK()
+		if _, ok := byGVK[gvk]; !ok {
+			gvks = append(gvks, gvk)
+		}
+		byGVK[gvk] = append(byGVK[gvk], rec)
+	}
+
+	for _, gvk := range gvks {
+		recs := byGVK[gvk]
+		if len(recs) < 2 {
+			continue
+		}
+		names := map[string]bool{}
+		discriminators := map[string]bool{}
+		for _, rec := range recs {
+			name := ReconcilerName(rec)
+			var d Discriminator
+			if discriminated, ok := rec.(DiscriminatedReconciler); ok {
+				d = discriminated.ChildDiscriminator()
+			}
+			if d == nil {
+				return fmt.Errorf("reconciler %q needs a discriminator, more than one reconciler manages '%s'", name, gvk)
+			}
+			if discriminators[d.String()] {
+				return fmt.Errorf("reconciler %q has the same discriminator as another reconciler of '%s': %s", name, gvk, d)
+			}
+			if names[name] {
+				return fmt.Errorf("reconciler %q has the same name as another reconciler of '%s'", name, gvk)
+			}
+			discriminators[d.String()] = true
+			names[name] = true
+		}
+	}
+	return nil
+}
+
 type ReconcilerConfig interface {
 	Validate() (bool, error)
 }
@@ -46,29 +127,54 @@ func (d *resourceReconcilerMixin) InjectDeps(ctn deps.Container) {
 }
 
 func (d *resourceReconcilerMixin) newObject(gvk kotclient.GVK) (runtimeclient.Object, error) {
-	apiruntimeObj, err := d.Scheme.New(gvk)
+	obj, err := kotclient.NewObject(d.Scheme, gvk)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to initialize new object")
 	}
+	return obj, nil
+}
+
+func (d *resourceReconcilerMixin) newObjectList(gvk kotclient.GVK) (runtimeclient.ObjectList, error) {
+	return kotclient.NewObjectList(d.Scheme, gvk)
+}
 
-	runtimeclientObj, ok := apiruntimeObj.(runtimeclient.Object)
-	if !ok {
-		return nil, errors.New("could not cast to a runtimeclient.Object")
+// newApplyObject initializes an object that only carries the identity of the
+// existing one, if any.
+func (d *resourceReconcilerMixin) newApplyObject(gvk kotclient.GVK, existing runtimeclient.Object) (runtimeclient.Object, error) {
+	obj, err := d.newObject(gvk)
+	if err != nil {
+		return nil, err
 	}
-	return runtimeclientObj, nil
+	obj.SetName(existing.GetName())
+	obj.SetNamespace(existing.GetNamespace())
+	obj.SetUID(existing.GetUID())
+	return obj, nil
 }
 
-func (d *resourceReconcilerMixin) newObjectList(scheme *apiruntime.Scheme, gvk kotclient.GVK) (runtimeclient.ObjectList, error) {
-	gvk.Kind = fmt.Sprintf("%sList", gvk.Kind)
-	apiruntimeList, err := d.Scheme.New(gvk)
+func (d *resourceReconcilerMixin) newApplyObjectList(gvk kotclient.GVK, existing runtimeclient.ObjectList) (runtimeclient.ObjectList, error) {
+	list, err := d.newObjectList(gvk)
 	if err != nil {
 		return nil, err
 	}
-	runtimeclientObj, ok := apiruntimeList.(runtimeclient.ObjectList)
-	if !ok {
-		return nil, errors.New("could not cast to a runtimeclient.ObjectList")
+
+	existingObjs, err := kotclient.ExtractList(existing)
+	if err != nil {
+		return nil, err
 	}
-	return runtimeclientObj, nil
+
+	objs := []runtimeclient.Object{}
+	for _, existingObj := range existingObjs {
+		obj, err := d.newApplyObject(gvk, existingObj)
+		if err != nil {
+			return nil, err
+		}
+		objs = append(objs, obj)
+	}
+
+	if err := kotclient.SetList(list, objs); err != nil {
+		return nil, err
+	}
+	return list, nil
 }
 
 func (d *resourceReconcilerMixin) listChildren(ctx action.Context, gvk kotclient.GVK, objList runtimeclient.ObjectList) error {
@@ -77,7 +183,7 @@ func (d *resourceReconcilerMixin) listChildren(ctx action.Context, gvk kotclient
 }
 
 func (d *resourceReconcilerMixin) fetchChildren(ctx action.Context, gvk kotclient.GVK) ([]runtimeclient.Object, error) {
-	objList, err := d.newObjectList(d.Scheme, gvk)
+	objList, err := d.newObjectList(gvk)
 	if err != nil {
 		return nil, err
 	}
@@ -94,26 +200,67 @@ func (d *resourceReconcilerMixin) fetchChildren(ctx action.Context, gvk kotclien
 	return children, nil
 }
 
-func (d *resourceReconcilerMixin) upsertIfChanged(ctx action.Context, objBefore, objAfter runtimeclient.Object) error {
-	if equality.Semantic.DeepEqual(objAfter, objAfter) {
-		return nil
+// deleteChildren deletes the given children of a GVK, skipping the ones that
+// are already being deleted.
+func (d *resourceReconcilerMixin) deleteChildren(ctx action.Context, gvk kotclient.GVK, children []runtimeclient.Object, opts ...runtimeclient.DeleteOption) error {
+	for _, child := range children {
+		if !child.GetDeletionTimestamp().IsZero() {
+			continue
+		}
+		ctx.Logger().Info("deleting child resource", "owned-gvk", gvk.String(), "child", child.GetName())
+		err := kotclient.IgnoreNotFound(d.Client.Delete(ctx, child, opts...))
+		childWritten(ctx, kotclient.SyncDelete, gvk, child, err)
+		if err != nil {
+			return errors.Wrap(err, "failed to delete child object")
+		}
 	}
+	return nil
+}
 
-	log := ctx.Logger()
-	client := d.Client
+// findAdoptable looks up an existing object with the identity of desired,
+// which is not a child of the resource being reconciled yet. It returns nil if
+// adoption is disabled or there is no such object, and fails if the object
+// can't be adopted.
+func (d *resourceReconcilerMixin) findAdoptable(ctx action.Context, policy ownership.AdoptionPolicy, gvk kotclient.GVK, desired runtimeclient.Object) (runtimeclient.Object, error) {
+	if !policy.Enabled() || desired.GetName() == "" {
+		return nil, nil
+	}
 
-	if objBefore.GetUID() == "" {
-		log.Info("creating child resource")
-		if err := client.Create(ctx, objAfter); err != nil {
-			return errors.Wrap(err, "failed to create child object")
+	existing, err := d.newObject(gvk)
+	if err != nil {
+		return nil, err
+	}
+	if err := d.Client.Get(ctx, runtimeclient.ObjectKeyFromObject(desired), existing); err != nil {
+		if kotclient.IsNotFound(err) {
+			return nil, nil
 		}
-		return nil
+		return nil, errors.Wrap(err, "failed to look up existing child object")
+	}
+
+	if err := ownership.CheckAdoption(policy, ctx.Resource(), existing); err != nil {
+		return nil, err
 	}
+	return existing, nil
+}
 
-	log.Info("updating child resource")
-	if err := client.Update(ctx, objAfter); err != nil {
-		return errors.Wrap(err, "failed to update child object")
+// driftOptions defaults the field manager of server side apply requests as
+// the one whose fields are compared.
+func driftOptions(ctx action.Context, opts kotclient.DriftOptions, apply *kotclient.ApplyOptions) kotclient.DriftOptions {
+	if apply != nil && opts.FieldManager == "" {
+		opts.FieldManager = apply.FieldManager
+		if opts.FieldManager == "" {
+			opts.FieldManager = kotclient.FieldManagerFrom(ctx)
+		}
 	}
+	return opts
+}
 
-	return nil
+// childWritten reports a write made to a child object on events and metrics.
+// Planned writes are only recorded on the plan.
+func childWritten(ctx action.Context, op kotclient.SyncOperation, gvk kotclient.GVK, child runtimeclient.Object, err error) {
+	if kotclient.PlanFrom(ctx) != nil {
+		return
+	}
+	RecordChildEvent(ctx, op, gvk, child, err)
+	metrics.ObserveChildOperation(ctx, gvk, string(op), err)
 }
@@ -0,0 +1,161 @@
+package reconcile
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/fgrehm/kot/pkg/deps"
+	wkdeps "github.com/fgrehm/kot/pkg/deps/wellknown"
+	"github.com/fgrehm/kot/pkg/indexing"
+	"github.com/fgrehm/kot/pkg/kotclient"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+	runtimepredicate "sigs.k8s.io/controller-runtime/pkg/predicate"
+	runtimereconcile "sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// IndexingWatcher is implemented by watchers that rely on a field index of the
+// resources reconciled by the controller they are registered on.
+type IndexingWatcher interface {
+	Watcher
+	Indexer() indexing.Indexer
+}
+
+// ReferenceWatcher enqueues the resources reconciled by the controller that
+// reference a watched resource, looking them up through a field index.
+type ReferenceWatcher struct {
+	*ResourceWatcher
+	config *ReferenceWatcherConfig
+	client kotclient.Client
+	scheme *runtime.Scheme
+	field  string
+}
+
+var _ ParentWatcher = &ReferenceWatcher{}
+var _ IndexingWatcher = &ReferenceWatcher{}
+var _ WatcherConfig = &ReferenceWatcherConfig{}
+var _ deps.DepsInjector = &ReferenceWatcher{}
+
+func (w *ReferenceWatcher) InjectDeps(ctn deps.Container) {
+	w.ResourceWatcher.InjectDeps(ctn)
+	w.client = wkdeps.Client(ctn)
+	w.scheme = wkdeps.Scheme(ctn)
+
+	w.field = w.config.Field
+	if w.field == "" {
+		w.field = fmt.Sprintf(".kot.references.%s", strings.ToLower(w.watchedGVK.GroupKind().String()))
+	}
+}
+
+// Indexer indexes parents by the keys of the resources they reference, it is
+// only complete after deps are injected.
+func (w *ReferenceWatcher) Indexer() indexing.Indexer {
+	return indexing.Indexer{
+		GVK:     w.parentGVK,
+		Field:   w.field,
+		IndexFn: w.indexReferences,
+	}
+}
+
+func (w *ReferenceWatcher) indexReferences(parent runtimeclient.Object) []string {
+	keys := []string{}
+	for _, ref := range w.config.References(parent) {
+		namespace := ref.Namespace
+		if w.config.ClusterScoped {
+			namespace = ""
+		} else if namespace == "" {
+			namespace = parent.GetNamespace()
+		}
+		keys = append(keys, referenceKey(namespace, ref.Name))
+	}
+	return keys
+}
+
+func (w *ReferenceWatcher) enqueue(ctx context.Context, _ deps.Container, obj runtimeclient.Object) ([]runtimereconcile.Request, error) {
+	parents, err := kotclient.NewObjectList(w.scheme, w.parentGVK)
+	if err != nil {
+		return nil, err
+	}
+	filter := kotclient.MatchingFields{w.field: referenceKey(obj.GetNamespace(), obj.GetName())}
+	if err := w.client.List(ctx, parents, filter); err != nil {
+		return nil, errors.Wrap(err, "failed to list referencing resources")
+	}
+	objs, err := kotclient.ExtractList(parents)
+	if err != nil {
+		return nil, err
+	}
+
+	reqs := make([]runtimereconcile.Request, len(objs))
+	for i, parent := range objs {
+		reqs[i].NamespacedName = runtimeclient.ObjectKeyFromObject(parent)
+	}
+	return reqs, nil
+}
+
+// referenceKey identifies namespaced resources by "namespace/name" and cluster
+// scoped ones by name.
+func referenceKey(namespace, name string) string {
+	if namespace == "" {
+		return name
+	}
+	return namespace + "/" + name
+}
+
+type ReferenceWatcherConfig struct {
+	// Watches is the type of the referenced resources.
+	Watches runtimeclient.Object
+	// References returns the keys of the resources referenced by a parent. A
+	// blank namespace stands for the namespace of the parent.
+	References func(parent runtimeclient.Object) []runtimeclient.ObjectKey
+	// ClusterScoped is set when the referenced resources are not namespaced.
+	ClusterScoped bool
+	// When defaults to enqueuing on resource version changes.
+	When runtimepredicate.Predicate
+	// Timeout, Retries and EnqueueAllOnError are handled as in
+	// ResourceWatcherConfig.
+	Timeout           time.Duration
+	Retries           int
+	EnqueueAllOnError bool
+	// Field names the index, defaults to one derived from the referenced kind.
+	// It has to be set when references to the same kind are watched twice by a
+	// controller.
+	Field string
+}
+
+func (c *ReferenceWatcherConfig) Validate() (bool, error) {
+	if c.Watches == nil {
+		return false, errors.New("resource type to watch is not set")
+	}
+	if c.References == nil {
+		return false, errors.New("references are not set")
+	}
+	if c.Timeout < 0 {
+		return false, errors.New("timeout can't be negative")
+	}
+	if c.Retries < 0 {
+		return false, errors.New("retries can't be negative")
+	}
+
+	return true, nil
+}
+
+func (c *ReferenceWatcherConfig) buildWatcher() (Watcher, error) {
+	when := c.When
+	if when == nil {
+		when = runtimepredicate.ResourceVersionChangedPredicate{}
+	}
+
+	w := &ReferenceWatcher{config: c}
+	w.ResourceWatcher = &ResourceWatcher{ResourceWatcherConfig: &ResourceWatcherConfig{
+		Watches:           c.Watches,
+		When:              when,
+		Enqueue:           w.enqueue,
+		Timeout:           c.Timeout,
+		Retries:           c.Retries,
+		EnqueueAllOnError: c.EnqueueAllOnError,
+	}}
+	return w, nil
+}
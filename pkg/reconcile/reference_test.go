@@ -0,0 +1,111 @@
+package reconcile_test
+
+import (
+	"github.com/fgrehm/kot/pkg/deps"
+	wkdeps "github.com/fgrehm/kot/pkg/deps/wellknown"
+	"github.com/fgrehm/kot/pkg/kotclient"
+	"github.com/fgrehm/kot/pkg/kottesting/gomock"
+	"github.com/fgrehm/kot/pkg/reconcile"
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/workqueue"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+	runtimeevent "sigs.k8s.io/controller-runtime/pkg/event"
+	runtimereconcile "sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+var _ = Describe("ReferenceWatcher", func() {
+	var (
+		mCtrl  *gomock.Controller
+		client *kotmocks.MockClient
+		ctn    deps.Container
+	)
+
+	var secretRefs = func(parent runtimeclient.Object) []runtimeclient.ObjectKey {
+		refs := []runtimeclient.ObjectKey{}
+		for _, env := range parent.(*corev1.Pod).Spec.Containers[0].Env {
+			refs = append(refs, runtimeclient.ObjectKey{Namespace: env.Name, Name: env.Value})
+		}
+		return refs
+	}
+
+	var podWithRefs = func(ns string, refs ...corev1.EnvVar) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Namespace: ns, Name: "pod"},
+			Spec:       corev1.PodSpec{Containers: []corev1.Container{{Env: refs}}},
+		}
+	}
+
+	var build = func(cfg *reconcile.ReferenceWatcherConfig) reconcile.IndexingWatcher {
+		watcher := reconcile.MustCreateWatcher(cfg).(reconcile.IndexingWatcher)
+		watcher.(reconcile.ParentWatcher).WatchParents(corev1.SchemeGroupVersion.WithKind("Pod"))
+		deps.Inject(ctn, watcher)
+		return watcher
+	}
+
+	BeforeEach(func() {
+		mCtrl = gomock.NewController(GinkgoT())
+		mockedEnv := kotmocks.NewEnv(mCtrl, GinkgoWriter)
+		client = mockedEnv.Client
+		wkdeps.SetClient(client)
+		wkdeps.SetScheme(mockedEnv.Scheme)
+		ctn = deps.Build()
+	})
+
+	AfterEach(func() {
+		mCtrl.Finish()
+		deps.Clear()
+	})
+
+	Describe("Indexer", func() {
+		It("indexes parents by the keys of namespaced references", func() {
+			indexer := build(&reconcile.ReferenceWatcherConfig{Watches: &corev1.Secret{}, References: secretRefs}).Indexer()
+
+			Expect(indexer.GVK).To(Equal(corev1.SchemeGroupVersion.WithKind("Pod")))
+			Expect(indexer.Field).To(Equal(".kot.references.secret"))
+			pod := podWithRefs("default", corev1.EnvVar{Name: "other", Value: "a"}, corev1.EnvVar{Value: "b"})
+			Expect(indexer.IndexFn(pod)).To(Equal([]string{"other/a", "default/b"}))
+		})
+
+		It("indexes parents by the names of cluster scoped references", func() {
+			indexer := build(&reconcile.ReferenceWatcherConfig{
+				Watches:       &corev1.Namespace{},
+				References:    secretRefs,
+				ClusterScoped: true,
+				Field:         ".namespaces",
+			}).Indexer()
+
+			Expect(indexer.Field).To(Equal(".namespaces"))
+			Expect(indexer.IndexFn(podWithRefs("default", corev1.EnvVar{Value: "a"}))).To(Equal([]string{"a"}))
+		})
+	})
+
+	Describe("Handler", func() {
+		It("enqueues parents that reference the resource", func() {
+			watcher := build(&reconcile.ReferenceWatcherConfig{Watches: &corev1.Secret{}, References: secretRefs})
+			client.EXPECT().List(gomock.Any(), gomock.Any(), kotclient.MatchingFields{".kot.references.secret": "default/a"}).
+				SetArg(1, corev1.PodList{Items: []corev1.Pod{*podWithRefs("default")}})
+
+			queue := workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter())
+			defer queue.ShutDown()
+			secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "a"}}
+			watcher.Handler().Generic(runtimeevent.GenericEvent{Object: secret}, queue)
+
+			item, _ := queue.Get()
+			Expect(item).To(Equal(runtimereconcile.Request{NamespacedName: runtimeclient.ObjectKey{Namespace: "default", Name: "pod"}}))
+		})
+	})
+
+	Describe("Validate", func() {
+		It("requires the referenced type and references", func() {
+			_, err := reconcile.CreateWatcher(&reconcile.ReferenceWatcherConfig{References: secretRefs})
+			Expect(err).To(MatchError("resource type to watch is not set"))
+
+			_, err = reconcile.CreateWatcher(&reconcile.ReferenceWatcherConfig{Watches: &corev1.Secret{}})
+			Expect(err).To(MatchError("references are not set"))
+		})
+	})
+})
@@ -0,0 +1,61 @@
+package reconcile
+
+import (
+	"context"
+	"sync"
+
+	"github.com/fgrehm/kot/pkg/kotclient"
+)
+
+// SyncReports collects the reports of lists synced during a reconciliation
+// pass, keyed by the name of the reconciler.
+type SyncReports struct {
+	mu      sync.Mutex
+	reports map[string]*kotclient.SyncListReport
+}
+
+func NewSyncReports() *SyncReports {
+	return &SyncReports{reports: map[string]*kotclient.SyncListReport{}}
+}
+
+func (r *SyncReports) Record(reconcilerName string, report *kotclient.SyncListReport) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.reports[reconcilerName] = report
+}
+
+// Get returns the report recorded by a reconciler, nil if there is none.
+func (r *SyncReports) Get(reconcilerName string) *kotclient.SyncListReport {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.reports[reconcilerName]
+}
+
+// syncReportsCtxKey is how we find the SyncReports in a context.Context
+type syncReportsCtxKey struct{}
+
+// NewSyncReportsContext returns a new Context, derived from ctx, which carries
+// the provided SyncReports.
+func NewSyncReportsContext(ctx context.Context, reports *SyncReports) context.Context {
+	return context.WithValue(ctx, syncReportsCtxKey{}, reports)
+}
+
+// SyncReportsFrom returns the SyncReports carried by ctx, or nil if reports are
+// not being collected.
+func SyncReportsFrom(ctx context.Context) *SyncReports {
+	if v, ok := ctx.Value(syncReportsCtxKey{}).(*SyncReports); ok {
+		return v
+	}
+	return nil
+}
+
+// ListSyncReport returns the report of the list synced by a reconciler during
+// the current reconciliation pass, nil if there is none.
+func ListSyncReport(ctx context.Context, reconcilerName string) *kotclient.SyncListReport {
+	if reports := SyncReportsFrom(ctx); reports != nil {
+		return reports.Get(reconcilerName)
+	}
+	return nil
+}
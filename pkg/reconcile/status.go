@@ -2,8 +2,11 @@ package reconcile
 
 import (
 	"github.com/fgrehm/kot/pkg/action"
+	"github.com/fgrehm/kot/pkg/conditions"
 	"github.com/fgrehm/kot/pkg/kotclient"
+	"github.com/fgrehm/kot/pkg/metrics"
 	"k8s.io/apimachinery/pkg/api/equality"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 type StatusResolver = action.Action
@@ -11,6 +14,13 @@ type StatusResolver = action.Action
 type StatusUpdater struct {
 	client kotclient.Client
 	action action.Action
+	patch  kotclient.PatchOptions
+}
+
+// WithPatchOptions configures how status changes are sent to the API.
+func (r *StatusUpdater) WithPatchOptions(opts kotclient.PatchOptions) *StatusUpdater {
+	r.patch = opts
+	return r
 }
 
 func (r *StatusUpdater) Run(ctx action.Context) (action.Result, error) {
@@ -22,6 +32,7 @@ func (r *StatusUpdater) Run(ctx action.Context) (action.Result, error) {
 	if err := r.client.Reload(ctx, parent); err != nil {
 		return finalResult, err
 	}
+	parentBefore := parent.DeepCopyObject().(runtimeclient.Object)
 	statusBefore, err := kotclient.ObjectField(parent, "status")
 	if err != nil {
 		return finalResult, err
@@ -33,6 +44,12 @@ func (r *StatusUpdater) Run(ctx action.Context) (action.Result, error) {
 		return finalResult, err
 	}
 
+	if tracker := conditions.FromContext(ctx); tracker != nil {
+		if obj, ok := parent.(conditions.Object); ok {
+			tracker.Apply(obj)
+		}
+	}
+
 	statusAfter, err := kotclient.ObjectField(parent, "status")
 	if err != nil {
 		return finalResult, err
@@ -40,7 +57,11 @@ func (r *StatusUpdater) Run(ctx action.Context) (action.Result, error) {
 
 	if !equality.Semantic.DeepEqual(statusBefore, statusAfter) {
 		log.Info("status changed, updating")
-		if err := r.client.UpdateStatus(ctx, parent); err != nil {
+		err := r.client.PatchStatus(ctx, parentBefore, parent, r.patch)
+		if kotclient.PlanFrom(ctx) == nil {
+			metrics.ObserveStatusUpdate(ctx, err)
+		}
+		if err != nil {
 			return finalResult, err
 		}
 	}
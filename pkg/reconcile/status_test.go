@@ -4,15 +4,22 @@ import (
 	"context"
 	"errors"
 
+	testapi "github.com/fgrehm/kot/internal/testapi/v1"
 	"github.com/fgrehm/kot/pkg/action"
+	"github.com/fgrehm/kot/pkg/conditions"
 	"github.com/fgrehm/kot/pkg/deps"
 	wkdeps "github.com/fgrehm/kot/pkg/deps/wellknown"
+	"github.com/fgrehm/kot/pkg/kotclient"
 	"github.com/fgrehm/kot/pkg/kottesting/gomock"
+	"github.com/fgrehm/kot/pkg/metrics"
 	"github.com/fgrehm/kot/pkg/reconcile"
 	"github.com/golang/mock/gomock"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	corev1 "k8s.io/api/core/v1"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 var _ = Describe("StatusUpdater", func() {
@@ -75,13 +82,34 @@ var _ = Describe("StatusUpdater", func() {
 			)
 
 			client.EXPECT().Reload(gomock.Any(), gomock.Any())
-			client.EXPECT().UpdateStatus(gomock.Any(), gomock.Any())
+			client.EXPECT().PatchStatus(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any())
 
 			res, err := updater.Run(ctx)
 			Expect(err).NotTo(HaveOccurred())
 			Expect(res).To(Equal(action.Result{}))
 		})
 
+		It("does not count planned status updates", func() {
+			updater = reconcile.CreateStatusUpdater(
+				depsCtn,
+				action.ActionFn(func(r action.Context) (action.Result, error) {
+					ns := r.Resource().(*corev1.Namespace)
+					ns.Status.Phase = corev1.NamespaceTerminating
+					return action.Result{}, nil
+				}),
+			)
+			ctx = action.NewContext(kotclient.WithPlan(ctx, &kotclient.Plan{}))
+			updates := metrics.StatusUpdates.WithLabelValues("", metrics.ResultSuccess)
+			before := testutil.ToFloat64(updates)
+
+			client.EXPECT().Reload(gomock.Any(), gomock.Any())
+			client.EXPECT().PatchStatus(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any())
+
+			_, err := updater.Run(ctx)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(testutil.ToFloat64(updates)).To(Equal(before))
+		})
+
 		It("allows halting", func() {
 			called := false
 			updater = reconcile.CreateStatusUpdater(
@@ -121,13 +149,37 @@ var _ = Describe("StatusUpdater", func() {
 			)
 
 			client.EXPECT().Reload(gomock.Any(), gomock.Any())
-			client.EXPECT().UpdateStatus(gomock.Any(), gomock.Any())
+			client.EXPECT().PatchStatus(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any())
 
 			res, err := updater.Run(ctx)
 			Expect(err).NotTo(HaveOccurred())
 			Expect(res).To(Equal(action.Result{}))
 		})
 
+		It("sets conditions tracked during the reconciliation pass", func() {
+			parent := &testapi.SimpleCRD{}
+			tracker := conditions.NewTracker()
+			tracker.Record("ConfigMap", action.Result{}, nil)
+			ctx = action.NewContext(conditions.NewContext(context.Background(), tracker)).WithResource(parent)
+
+			updater = reconcile.CreateStatusUpdater(
+				depsCtn,
+				action.ActionFn(func(r action.Context) (action.Result, error) {
+					conditions.Set(r, metav1.Condition{Type: "Synced", Status: metav1.ConditionTrue, Reason: "Synced"})
+					return action.Result{}, nil
+				}),
+			)
+
+			client.EXPECT().Reload(gomock.Any(), gomock.Any())
+			client.EXPECT().PatchStatus(gomock.Any(), gomock.Any(), parent, gomock.Any())
+
+			_, err := updater.Run(ctx)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(apimeta.IsStatusConditionTrue(parent.Status.Conditions, "ConfigMapReconciled")).To(BeTrue())
+			Expect(apimeta.IsStatusConditionTrue(parent.Status.Conditions, "Synced")).To(BeTrue())
+			Expect(apimeta.IsStatusConditionTrue(parent.Status.Conditions, conditions.TypeReady)).To(BeTrue())
+		})
+
 		It("fails fast if any resolver returns error, and does not update status", func() {
 			expectedErr := errors.New("boom")
 			updater = reconcile.CreateStatusUpdater(
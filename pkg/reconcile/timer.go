@@ -0,0 +1,225 @@
+package reconcile
+
+import (
+	"context"
+	"math/rand"
+	"time"
+
+	"github.com/fgrehm/kot/pkg/deps"
+	wkdeps "github.com/fgrehm/kot/pkg/deps/wellknown"
+	"github.com/fgrehm/kot/pkg/kotclient"
+	"github.com/pkg/errors"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/utils/clock"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	runtimehandler "sigs.k8s.io/controller-runtime/pkg/handler"
+	ctrllog "sigs.k8s.io/controller-runtime/pkg/log"
+	runtimepredicate "sigs.k8s.io/controller-runtime/pkg/predicate"
+	runtimereconcile "sigs.k8s.io/controller-runtime/pkg/reconcile"
+	runtimesource "sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+// ParentWatcher is implemented by watchers that need to know the GVK of the
+// resources reconciled by the controller they are registered on.
+type ParentWatcher interface {
+	Watcher
+	WatchParents(gvk kotclient.GVK)
+}
+
+// TimerWatcher enqueues the resources reconciled by the controller on a fixed
+// interval or on a cron schedule.
+type TimerWatcher struct {
+	*TimerWatcherConfig
+	ctn       deps.Container
+	client    kotclient.Client
+	parentGVK kotclient.GVK
+	schedule  schedule
+}
+
+var _ ParentWatcher = &TimerWatcher{}
+var _ WatcherConfig = &TimerWatcherConfig{}
+var _ deps.DepsInjector = &TimerWatcher{}
+
+func (w *TimerWatcher) InjectDeps(ctn deps.Container) {
+	w.ctn = ctn
+	w.client = wkdeps.Client(ctn)
+}
+
+func (w *TimerWatcher) WatchParents(gvk kotclient.GVK) {
+	w.parentGVK = gvk
+}
+
+func (w *TimerWatcher) Source() runtimesource.Source {
+	return &timerSource{watcher: w}
+}
+
+func (w *TimerWatcher) Handler() runtimehandler.EventHandler {
+	return &jitteredHandler{jitter: w.Jitter, clock: w.clock()}
+}
+
+func (w *TimerWatcher) Predicate() runtimepredicate.Predicate {
+	return runtimepredicate.Funcs{}
+}
+
+func (w *TimerWatcher) clock() clock.Clock {
+	if w.Clock == nil {
+		return clock.RealClock{}
+	}
+	return w.Clock
+}
+
+// enqueue sends a generic event for every parent picked by the selector.
+func (w *TimerWatcher) enqueue(ctx context.Context, handler runtimehandler.EventHandler, queue workqueue.RateLimitingInterface, prct []runtimepredicate.Predicate) error {
+	parents, err := kotclient.NewObjectList(wkdeps.Scheme(w.ctn), w.parentGVK)
+	if err != nil {
+		return err
+	}
+	if err := w.client.List(ctx, parents); err != nil {
+		return errors.Wrap(err, "failed to list resources")
+	}
+	objs, err := kotclient.ExtractList(parents)
+	if err != nil {
+		return err
+	}
+
+	for _, obj := range objs {
+		if w.Select != nil && !w.Select(w.ctn, obj) {
+			continue
+		}
+		evt := event.GenericEvent{Object: obj}
+		filtered := false
+		for _, p := range prct {
+			if !p.Generic(evt) {
+				filtered = true
+				break
+			}
+		}
+		if !filtered {
+			handler.Generic(evt, queue)
+		}
+	}
+	return nil
+}
+
+type TimerWatcherConfig struct {
+	// Every enqueues resources on a fixed interval.
+	Every time.Duration
+	// Cron enqueues resources on a schedule like "*/15 * * * *" or "@daily",
+	// evaluated in UTC. It can't be set along with Every.
+	Cron string
+	// Select picks the resources to enqueue, all of them are enqueued by
+	// default.
+	Select func(deps deps.Container, obj runtimeclient.Object) bool
+	// Jitter delays each resource by a random duration of up to this long, so
+	// that they are not all reconciled at once.
+	Jitter time.Duration
+	// Clock defaults to the real one, tests can drive a fake one.
+	Clock clock.Clock
+}
+
+func (c *TimerWatcherConfig) Validate() (bool, error) {
+	if _, err := c.buildSchedule(); err != nil {
+		return false, err
+	}
+	if c.Jitter < 0 {
+		return false, errors.New("jitter can't be negative")
+	}
+
+	return true, nil
+}
+
+func (c *TimerWatcherConfig) buildSchedule() (schedule, error) {
+	switch {
+	case c.Every < 0:
+		return nil, errors.New("interval must be positive")
+	case c.Every > 0 && c.Cron != "":
+		return nil, errors.New("only one of interval and cron schedule can be set")
+	case c.Every > 0:
+		return intervalSchedule(c.Every), nil
+	case c.Cron != "":
+		return parseCron(c.Cron)
+	}
+	return nil, errors.New("interval or cron schedule is not set")
+}
+
+func (c *TimerWatcherConfig) buildWatcher() (Watcher, error) {
+	s, err := c.buildSchedule()
+	if err != nil {
+		return nil, err
+	}
+	return &TimerWatcher{TimerWatcherConfig: c, schedule: s}, nil
+}
+
+// timerSource fires on the schedule of the watcher until the manager stops.
+type timerSource struct {
+	watcher *TimerWatcher
+}
+
+var _ runtimesource.Source = &timerSource{}
+
+func (s *timerSource) Start(ctx context.Context, handler runtimehandler.EventHandler, queue workqueue.RateLimitingInterface, prct ...runtimepredicate.Predicate) error {
+	if s.watcher.client == nil {
+		return errors.New("timer watcher does not have deps injected")
+	}
+	if s.watcher.parentGVK.Empty() {
+		return errors.New("timer watcher does not know which resources to enqueue")
+	}
+
+	go s.run(ctx, handler, queue, prct)
+	return nil
+}
+
+func (s *timerSource) run(ctx context.Context, handler runtimehandler.EventHandler, queue workqueue.RateLimitingInterface, prct []runtimepredicate.Predicate) {
+	var (
+		log = ctrllog.FromContext(ctx).WithValues("parent-gvk", s.watcher.parentGVK.String())
+		clk = s.watcher.clock()
+	)
+
+	for {
+		now := clk.Now()
+		next := s.watcher.schedule.Next(now)
+		if next.IsZero() {
+			log.Info("timer schedule never fires again, stopping")
+			return
+		}
+
+		timer := clk.NewTimer(next.Sub(now))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C():
+		}
+
+		if err := s.watcher.enqueue(ctx, handler, queue, prct); err != nil {
+			log.Error(err, "failed to enqueue resources on timer")
+		}
+	}
+}
+
+// jitteredHandler enqueues objects of generic events after a random delay,
+// measured on the clock of the watcher.
+type jitteredHandler struct {
+	jitter time.Duration
+	clock  clock.Clock
+}
+
+var _ runtimehandler.EventHandler = &jitteredHandler{}
+
+func (h *jitteredHandler) Create(event.CreateEvent, workqueue.RateLimitingInterface) {}
+func (h *jitteredHandler) Update(event.UpdateEvent, workqueue.RateLimitingInterface) {}
+func (h *jitteredHandler) Delete(event.DeleteEvent, workqueue.RateLimitingInterface) {}
+
+func (h *jitteredHandler) Generic(evt event.GenericEvent, queue workqueue.RateLimitingInterface) {
+	req := runtimereconcile.Request{NamespacedName: runtimeclient.ObjectKeyFromObject(evt.Object)}
+	if h.jitter <= 0 {
+		queue.Add(req)
+		return
+	}
+	timer := h.clock.NewTimer(time.Duration(rand.Int63n(int64(h.jitter))))
+	go func() {
+		<-timer.C()
+		queue.Add(req)
+	}()
+}
@@ -0,0 +1,140 @@
+package reconcile_test
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/fgrehm/kot/pkg/deps"
+	wkdeps "github.com/fgrehm/kot/pkg/deps/wellknown"
+	"github.com/fgrehm/kot/pkg/kottesting/gomock"
+	"github.com/fgrehm/kot/pkg/reconcile"
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/workqueue"
+	clocktesting "k8s.io/utils/clock/testing"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+	runtimeevent "sigs.k8s.io/controller-runtime/pkg/event"
+	runtimereconcile "sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+var _ = Describe("TimerWatcher", func() {
+	var (
+		mCtrl  *gomock.Controller
+		client *kotmocks.MockClient
+		ctn    deps.Container
+		clock  *clocktesting.FakeClock
+		queue  workqueue.RateLimitingInterface
+		ctx    context.Context
+		cancel context.CancelFunc
+	)
+
+	BeforeEach(func() {
+		mCtrl = gomock.NewController(GinkgoT())
+		mockedEnv := kotmocks.NewEnv(mCtrl, GinkgoWriter)
+		client = mockedEnv.Client
+		wkdeps.SetClient(client)
+		wkdeps.SetScheme(mockedEnv.Scheme)
+		ctn = deps.Build()
+
+		clock = clocktesting.NewFakeClock(time.Date(2022, 8, 1, 10, 2, 0, 0, time.UTC))
+		queue = workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter())
+		ctx, cancel = context.WithCancel(context.Background())
+
+		client.EXPECT().List(gomock.Any(), gomock.Any()).SetArg(1, corev1.NamespaceList{Items: []corev1.Namespace{
+			{ObjectMeta: metav1.ObjectMeta{Name: "a"}},
+			{ObjectMeta: metav1.ObjectMeta{Name: "b"}},
+		}}).AnyTimes()
+	})
+
+	AfterEach(func() {
+		cancel()
+		queue.ShutDown()
+		mCtrl.Finish()
+		deps.Clear()
+	})
+
+	var start = func(cfg *reconcile.TimerWatcherConfig) {
+		cfg.Clock = clock
+		watcher := reconcile.MustCreateWatcher(cfg)
+		watcher.(reconcile.ParentWatcher).WatchParents(corev1.SchemeGroupVersion.WithKind("Namespace"))
+		deps.Inject(ctn, watcher)
+
+		Expect(watcher.Source().Start(ctx, watcher.Handler(), queue, watcher.Predicate())).To(Succeed())
+		Eventually(clock.HasWaiters).Should(BeTrue())
+	}
+
+	It("enqueues all resources on a fixed interval", func() {
+		start(&reconcile.TimerWatcherConfig{Every: time.Minute})
+
+		clock.Step(30 * time.Second)
+		Consistently(queue.Len, "50ms").Should(Equal(0))
+
+		clock.Step(30 * time.Second)
+		Eventually(queue.Len).Should(Equal(2))
+	})
+
+	It("enqueues resources picked by the selector", func() {
+		start(&reconcile.TimerWatcherConfig{
+			Every: time.Minute,
+			Select: func(_ deps.Container, obj runtimeclient.Object) bool {
+				return obj.GetName() == "b"
+			},
+		})
+
+		clock.Step(time.Minute)
+		Eventually(queue.Len).Should(Equal(1))
+		item, _ := queue.Get()
+		Expect(item).To(Equal(runtimereconcile.Request{NamespacedName: runtimeclient.ObjectKey{Name: "b"}}))
+	})
+
+	It("enqueues resources on a cron schedule", func() {
+		start(&reconcile.TimerWatcherConfig{Cron: "*/5 * * * *"})
+
+		clock.Step(2 * time.Minute)
+		Consistently(queue.Len, "50ms").Should(Equal(0))
+
+		clock.Step(time.Minute)
+		Eventually(queue.Len).Should(Equal(2))
+	})
+
+	It("delays resources by up to the jitter", func() {
+		watcher := reconcile.MustCreateWatcher(&reconcile.TimerWatcherConfig{Every: time.Minute, Jitter: time.Second, Clock: clock})
+
+		for i := 0; i < 10; i++ {
+			ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("ns-%d", i)}}
+			watcher.Handler().Generic(runtimeevent.GenericEvent{Object: ns}, queue)
+		}
+		Consistently(queue.Len, "50ms").Should(Equal(0))
+
+		clock.Step(time.Second)
+		Eventually(queue.Len).Should(Equal(10))
+	})
+
+	Describe("Validate", func() {
+		It("requires a schedule", func() {
+			_, err := reconcile.CreateWatcher(&reconcile.TimerWatcherConfig{})
+			Expect(err).To(MatchError("interval or cron schedule is not set"))
+
+			_, err = reconcile.CreateWatcher(&reconcile.TimerWatcherConfig{Every: time.Minute, Cron: "@daily"})
+			Expect(err).To(MatchError("only one of interval and cron schedule can be set"))
+
+			_, err = reconcile.CreateWatcher(&reconcile.TimerWatcherConfig{Every: -time.Minute, Cron: "@daily"})
+			Expect(err).To(MatchError("interval must be positive"))
+		})
+
+		It("checks cron expressions", func() {
+			_, err := reconcile.CreateWatcher(&reconcile.TimerWatcherConfig{Cron: "0 3 * * 1-5"})
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = reconcile.CreateWatcher(&reconcile.TimerWatcherConfig{Cron: "* * *"})
+			Expect(err).To(MatchError(`invalid cron expression "* * *": expected 5 fields, got 3`))
+
+			_, err = reconcile.CreateWatcher(&reconcile.TimerWatcherConfig{Cron: "60 * * * *"})
+			Expect(err).To(MatchError(`invalid cron expression "60 * * * *": minute must be between 0 and 59, got "60"`))
+		})
+	})
+})
@@ -0,0 +1,295 @@
+package reconcile
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/fgrehm/kot/pkg/action"
+	"github.com/fgrehm/kot/pkg/deps"
+	wkdeps "github.com/fgrehm/kot/pkg/deps/wellknown"
+	"github.com/fgrehm/kot/pkg/kotclient"
+	"github.com/fgrehm/kot/pkg/ownership"
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	apiruntime "k8s.io/apimachinery/pkg/runtime"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	runtimepredicate "sigs.k8s.io/controller-runtime/pkg/predicate"
+	runtimereconcile "sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// reconcilerBuilder is implemented by configs that build their own reconciler,
+// like the typed ones.
+type reconcilerBuilder interface {
+	ReconcilerConfig
+	buildReconciler() Reconciler
+}
+
+// watcherBuilder is implemented by configs that build their own watcher.
+type watcherBuilder interface {
+	WatcherConfig
+	buildWatcher() (Watcher, error)
+}
+
+// New initializes an empty object of type T, which must be a pointer to a
+// struct.
+func New[T any]() T {
+	var obj T
+	t := reflect.TypeOf(obj)
+	if t == nil || t.Kind() != reflect.Ptr {
+		panic(fmt.Sprintf("%T is not a pointer to a struct", obj))
+	}
+	return reflect.New(t.Elem()).Interface().(T)
+}
+
+// TypedOneConfig is a type-safe variant of OneReconcilerConfig, the GVK of the
+// child is derived from the scheme.
+type TypedOneConfig[P, C runtimeclient.Object] struct {
+	Name      string
+	If        func(ctx action.Context, parent P) (bool, error)
+	Reconcile func(ctx action.Context, parent P, child C) (action.Result, error)
+	Finalize  Finalizer
+
+	Apply         *kotclient.ApplyOptions
+	Patch         kotclient.PatchOptions
+	Drift         kotclient.DriftOptions
+	DependsOn     []Dependency
+	Ownership     ownership.Strategy
+	Adopt         ownership.AdoptionPolicy
+	Discriminator Discriminator
+}
+
+var _ reconcilerBuilder = &TypedOneConfig[runtimeclient.Object, runtimeclient.Object]{}
+
+func (c *TypedOneConfig[P, C]) Validate() (bool, error) {
+	if c.Reconcile == nil {
+		return false, errors.New("Reconcile func is not set")
+	}
+	if err := validateDependencies(c.DependsOn); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (c *TypedOneConfig[P, C]) buildReconciler() Reconciler {
+	cfg := &OneReconcilerConfig{
+		Name:          c.Name,
+		Finalize:      c.Finalize,
+		Apply:         c.Apply,
+		Patch:         c.Patch,
+		Drift:         c.Drift,
+		DependsOn:     c.DependsOn,
+		Ownership:     c.Ownership,
+		Adopt:         c.Adopt,
+		Discriminator: c.Discriminator,
+		Reconcile: func(ctx action.Context, childObj runtimeclient.Object) (action.Result, error) {
+			parent, err := action.ResourceAs[P](ctx)
+			if err != nil {
+				return action.Result{}, err
+			}
+			child, ok := childObj.(C)
+			if !ok {
+				return action.Result{}, fmt.Errorf("expected child of type %T, got %T", child, childObj)
+			}
+			return c.Reconcile(ctx, parent, child)
+		},
+	}
+	if c.If != nil {
+		cfg.If = typedIf(c.If)
+	}
+
+	return &typedReconciler[C]{inner: &OneReconciler{OneReconcilerConfig: cfg}, gvk: &cfg.GVK}
+}
+
+func typedIf[P runtimeclient.Object](fn func(ctx action.Context, parent P) (bool, error)) ReconcileIfFunc {
+	return func(ctx action.Context) (bool, error) {
+		parent, err := action.ResourceAs[P](ctx)
+		if err != nil {
+			return false, err
+		}
+		return fn(ctx, parent)
+	}
+}
+
+// TypedListConfig is a type-safe variant of ListReconcilerConfig, the GVK of
+// the children is derived from the scheme.
+type TypedListConfig[P runtimeclient.Object, L runtimeclient.ObjectList] struct {
+	Name      string
+	If        func(ctx action.Context, parent P) (bool, error)
+	Reconcile func(ctx action.Context, parent P, children L) (action.Result, error)
+	Finalize  Finalizer
+
+	Apply         *kotclient.ApplyOptions
+	Patch         kotclient.PatchOptions
+	Drift         kotclient.DriftOptions
+	DependsOn     []Dependency
+	Ownership     ownership.Strategy
+	Adopt         ownership.AdoptionPolicy
+	Discriminator Discriminator
+
+	// DeletePropagation is used when children are deleted because If returned
+	// false.
+	DeletePropagation metav1.DeletionPropagation
+}
+
+var _ reconcilerBuilder = &TypedListConfig[runtimeclient.Object, runtimeclient.ObjectList]{}
+
+func (c *TypedListConfig[P, L]) Validate() (bool, error) {
+	if c.Reconcile == nil {
+		return false, errors.New("Reconcile func is not set")
+	}
+	if err := validateDependencies(c.DependsOn); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (c *TypedListConfig[P, L]) buildReconciler() Reconciler {
+	cfg := &ListReconcilerConfig{
+		Name:              c.Name,
+		Finalize:          c.Finalize,
+		Apply:             c.Apply,
+		Patch:             c.Patch,
+		Drift:             c.Drift,
+		DependsOn:         c.DependsOn,
+		Ownership:         c.Ownership,
+		Adopt:             c.Adopt,
+		Discriminator:     c.Discriminator,
+		DeletePropagation: c.DeletePropagation,
+		Reconcile: func(ctx action.Context, childList runtimeclient.ObjectList) (action.Result, error) {
+			parent, err := action.ResourceAs[P](ctx)
+			if err != nil {
+				return action.Result{}, err
+			}
+			children, ok := childList.(L)
+			if !ok {
+				return action.Result{}, fmt.Errorf("expected children of type %T, got %T", children, childList)
+			}
+			return c.Reconcile(ctx, parent, children)
+		},
+	}
+	if c.If != nil {
+		cfg.If = typedIf(c.If)
+	}
+
+	return &typedReconciler[L]{inner: &ListReconciler{ListReconcilerConfig: cfg}, gvk: &cfg.GVK}
+}
+
+// typedReconciler derives the GVK of the reconciled objects from the scheme
+// once deps are injected.
+type typedReconciler[T apiruntime.Object] struct {
+	inner interface {
+		OwningReconciler
+		DiscriminatedReconciler
+		NamedReconciler
+		DependentReconciler
+		action.SpanNamer
+	}
+	gvk       *kotclient.GVK
+	injectErr error
+}
+
+var _ ResourceReconciler = &typedReconciler[runtimeclient.Object]{}
+var _ InjectionValidator = &typedReconciler[runtimeclient.Object]{}
+var _ DiscriminatedReconciler = &typedReconciler[runtimeclient.Object]{}
+var _ deps.DepsInjector = &typedReconciler[runtimeclient.Object]{}
+
+func (r *typedReconciler[T]) InjectDeps(ctn deps.Container) {
+	r.inner.InjectDeps(ctn)
+
+	gvk, err := apiutil.GVKForObject(New[T](), wkdeps.Scheme(ctn))
+	if err != nil {
+		r.injectErr = errors.Wrap(err, "failed to find GVK of reconciled objects")
+		return
+	}
+	r.injectErr = nil
+	if _, isList := apiruntime.Object(New[T]()).(runtimeclient.ObjectList); isList {
+		gvk.Kind = strings.TrimSuffix(gvk.Kind, "List")
+	}
+	*r.gvk = gvk
+}
+
+func (r *typedReconciler[T]) InjectionErr() error {
+	return r.injectErr
+}
+
+func (r *typedReconciler[T]) Run(ctx action.Context) (action.Result, error) {
+	return r.inner.Run(ctx)
+}
+
+func (r *typedReconciler[T]) Finalizer() Finalizer {
+	return r.inner.Finalizer()
+}
+
+func (r *typedReconciler[T]) OwnedGVK() kotclient.GVK {
+	return r.inner.OwnedGVK()
+}
+
+func (r *typedReconciler[T]) OwnershipStrategy() ownership.Strategy {
+	return r.inner.OwnershipStrategy()
+}
+
+func (r *typedReconciler[T]) ChildDiscriminator() Discriminator {
+	return r.inner.ChildDiscriminator()
+}
+
+func (r *typedReconciler[T]) ReconcilerName() string {
+	return r.inner.ReconcilerName()
+}
+
+func (r *typedReconciler[T]) SpanName() string {
+	return r.inner.SpanName()
+}
+
+func (r *typedReconciler[T]) Dependencies() []Dependency {
+	return r.inner.Dependencies()
+}
+
+// TypedResourceWatcherConfig is a type-safe variant of ResourceWatcherConfig,
+// the type of the watched resource is given by T.
+type TypedResourceWatcherConfig[T runtimeclient.Object] struct {
+	When              runtimepredicate.Predicate
+	Enqueue           func(ctx context.Context, deps deps.Container, obj T) ([]runtimereconcile.Request, error)
+	Timeout           time.Duration
+	Retries           int
+	EnqueueAllOnError bool
+}
+
+var _ watcherBuilder = &TypedResourceWatcherConfig[runtimeclient.Object]{}
+
+func (c *TypedResourceWatcherConfig[T]) Validate() (bool, error) {
+	if valid, err := c.untyped().Validate(); !valid || err != nil {
+		return valid, err
+	}
+	if c.Enqueue == nil {
+		return false, errors.New("enqueuer is not set")
+	}
+
+	return true, nil
+}
+
+func (c *TypedResourceWatcherConfig[T]) buildWatcher() (Watcher, error) {
+	return &ResourceWatcher{ResourceWatcherConfig: c.untyped()}, nil
+}
+
+func (c *TypedResourceWatcherConfig[T]) untyped() *ResourceWatcherConfig {
+	return &ResourceWatcherConfig{
+		Watches: New[T](),
+		When:    c.When,
+		Enqueue: func(ctx context.Context, ctn deps.Container, obj runtimeclient.Object) ([]runtimereconcile.Request, error) {
+			typedObj, ok := obj.(T)
+			if !ok {
+				return nil, fmt.Errorf("expected object of type %T, got %T", typedObj, obj)
+			}
+			return c.Enqueue(ctx, ctn, typedObj)
+		},
+		Timeout:           c.Timeout,
+		Retries:           c.Retries,
+		EnqueueAllOnError: c.EnqueueAllOnError,
+	}
+}
@@ -0,0 +1,130 @@
+package reconcile_test
+
+import (
+	"context"
+
+	"github.com/fgrehm/kot/pkg/action"
+	"github.com/fgrehm/kot/pkg/deps"
+	wkdeps "github.com/fgrehm/kot/pkg/deps/wellknown"
+	"github.com/fgrehm/kot/pkg/kottesting/gomock"
+	"github.com/fgrehm/kot/pkg/reconcile"
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+	runtimeevent "sigs.k8s.io/controller-runtime/pkg/event"
+	runtimepredicate "sigs.k8s.io/controller-runtime/pkg/predicate"
+	runtimesource "sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+var _ = Describe("Typed reconcilers", func() {
+	var (
+		ctx   action.Context
+		mCtrl *gomock.Controller
+
+		client *kotmocks.MockClient
+		ctn    deps.Container
+	)
+
+	BeforeEach(func() {
+		mCtrl = gomock.NewController(GinkgoT())
+
+		mockedEnv := kotmocks.NewEnv(mCtrl, GinkgoWriter)
+		client = mockedEnv.Client
+		wkdeps.SetClient(client)
+		wkdeps.SetScheme(mockedEnv.Scheme)
+		ctn = deps.Build()
+
+		ctx = action.NewBackgroundContext().WithResource(&corev1.ServiceAccount{
+			ObjectMeta: ctrl.ObjectMeta{Name: "sa"},
+		})
+	})
+
+	AfterEach(func() {
+		mCtrl.Finish()
+		deps.Clear()
+	})
+
+	Describe("TypedOneConfig", func() {
+		var rec reconcile.Reconciler
+
+		BeforeEach(func() {
+			rec = reconcile.MustCreateReconciler(&reconcile.TypedOneConfig[*corev1.ServiceAccount, *corev1.ConfigMap]{
+				If: func(ctx action.Context, sa *corev1.ServiceAccount) (bool, error) {
+					return sa.Name != "delete", nil
+				},
+				Reconcile: func(ctx action.Context, sa *corev1.ServiceAccount, cm *corev1.ConfigMap) (action.Result, error) {
+					cm.Data = map[string]string{"owner": sa.Name}
+					return action.Result{}, nil
+				},
+			})
+			deps.Inject(ctn, rec)
+		})
+
+		It("derives the GVK of the child from the scheme", func() {
+			Expect(rec.(reconcile.ResourceReconciler).OwnedGVK()).To(Equal(corev1.SchemeGroupVersion.WithKind("ConfigMap")))
+			Expect(reconcile.ReconcilerName(rec)).To(Equal("ConfigMap"))
+		})
+
+		It("hands the typed parent and child to the reconcile func", func() {
+			client.EXPECT().List(gomock.Any(), gomock.Any(), gomock.Any())
+			client.EXPECT().Create(gomock.Any(), gomock.Any()).Do(func(_ interface{}, obj runtimeclient.Object, _ ...interface{}) error {
+				Expect(obj.(*corev1.ConfigMap).Data).To(Equal(map[string]string{"owner": "sa"}))
+				return nil
+			})
+
+			res, err := rec.Run(ctx)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(res).To(Equal(action.Result{}))
+		})
+
+		It("fails if the parent is of a different type", func() {
+			client.EXPECT().List(gomock.Any(), gomock.Any(), gomock.Any())
+
+			_, err := rec.Run(ctx.WithResource(&corev1.Secret{}))
+			Expect(err).To(MatchError(ContainSubstring("expected resource of type *v1.ServiceAccount, got *v1.Secret")))
+		})
+	})
+
+	Describe("TypedListConfig", func() {
+		It("hands the typed parent and children to the reconcile func", func() {
+			var received *corev1.ConfigMapList
+			rec := reconcile.MustCreateReconciler(&reconcile.TypedListConfig[*corev1.ServiceAccount, *corev1.ConfigMapList]{
+				Reconcile: func(ctx action.Context, sa *corev1.ServiceAccount, cms *corev1.ConfigMapList) (action.Result, error) {
+					received = cms
+					return action.Result{}, nil
+				},
+			})
+			deps.Inject(ctn, rec)
+			Expect(rec.(reconcile.ResourceReconciler).OwnedGVK()).To(Equal(corev1.SchemeGroupVersion.WithKind("ConfigMap")))
+
+			client.EXPECT().List(gomock.Any(), gomock.Any(), gomock.Any())
+			client.EXPECT().SyncList(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any())
+
+			_, err := rec.Run(ctx)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(received).NotTo(BeNil())
+		})
+	})
+
+	Describe("TypedResourceWatcherConfig", func() {
+		It("watches resources of the given type", func() {
+			var received *corev1.Pod
+			watcher := reconcile.MustCreateWatcher(&reconcile.TypedResourceWatcherConfig[*corev1.Pod]{
+				When: runtimepredicate.NewPredicateFuncs(func(runtimeclient.Object) bool { return true }),
+				Enqueue: func(_ context.Context, ctn deps.Container, pod *corev1.Pod) ([]ctrl.Request, error) {
+					received = pod
+					return []ctrl.Request{}, nil
+				},
+			})
+			deps.Inject(ctn, watcher)
+			Expect(watcher.Source()).To(Equal(&runtimesource.Kind{Type: &corev1.Pod{}}))
+
+			pod := &corev1.Pod{ObjectMeta: ctrl.ObjectMeta{Name: "pod"}}
+			watcher.Handler().Create(runtimeevent.CreateEvent{Object: pod}, nil)
+			Expect(received).To(Equal(pod))
+		})
+	})
+})
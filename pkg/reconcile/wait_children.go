@@ -0,0 +1,81 @@
+package reconcile
+
+import (
+	"time"
+
+	"github.com/fgrehm/kot/pkg/action"
+	"github.com/fgrehm/kot/pkg/deps"
+	"github.com/fgrehm/kot/pkg/kotclient"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	DefaultWaitForChildrenName    = "kot.io/wait-for-children"
+	DefaultWaitForChildrenRequeue = 5 * time.Second
+)
+
+// WaitForChildrenFinalizer deletes the children of the given GVKs when the
+// parent is deleted, and keeps the parent around until they are gone.
+type WaitForChildrenFinalizer struct {
+	// GVKs of the children, in the order they get deleted: children of a GVK
+	// are only deleted once the ones of the previous GVKs are gone.
+	GVKs []kotclient.GVK
+	// PropagationPolicy used when deleting children, defaults to Foreground.
+	PropagationPolicy metav1.DeletionPropagation
+	// RequeueAfter sets how often children are checked while they are being
+	// deleted, defaults to DefaultWaitForChildrenRequeue.
+	RequeueAfter time.Duration
+	// Name registered on the parent, defaults to DefaultWaitForChildrenName.
+	Name string
+
+	resourceReconcilerMixin
+}
+
+var _ NamedFinalizer = &WaitForChildrenFinalizer{}
+var _ deps.DepsInjector = &WaitForChildrenFinalizer{}
+
+func (f *WaitForChildrenFinalizer) FinalizerName() string {
+	if f.Name == "" {
+		return DefaultWaitForChildrenName
+	}
+	return f.Name
+}
+
+func (f *WaitForChildrenFinalizer) Enabled(ctx action.Context) (bool, error) {
+	return len(f.GVKs) > 0, nil
+}
+
+func (f *WaitForChildrenFinalizer) Finalize(ctx action.Context) (bool, action.Result, error) {
+	for _, gvk := range f.GVKs {
+		children, err := f.fetchChildren(ctx, gvk)
+		if err != nil {
+			return false, action.Result{}, err
+		}
+		if len(children) == 0 {
+			continue
+		}
+
+		if err := f.deleteChildren(ctx, gvk, children, runtimeclient.PropagationPolicy(f.propagationPolicy())); err != nil {
+			return false, action.Result{}, err
+		}
+		ctx.Logger().Info("waiting for children to be deleted", "owned-gvk", gvk.String(), "count", len(children))
+		return false, action.Result{RequeueAfter: f.requeueAfter()}, nil
+	}
+
+	return true, action.Result{}, nil
+}
+
+func (f *WaitForChildrenFinalizer) propagationPolicy() metav1.DeletionPropagation {
+	if f.PropagationPolicy == "" {
+		return metav1.DeletePropagationForeground
+	}
+	return f.PropagationPolicy
+}
+
+func (f *WaitForChildrenFinalizer) requeueAfter() time.Duration {
+	if f.RequeueAfter <= 0 {
+		return DefaultWaitForChildrenRequeue
+	}
+	return f.RequeueAfter
+}
@@ -0,0 +1,101 @@
+package reconcile_test
+
+import (
+	"time"
+
+	"github.com/fgrehm/kot/pkg/action"
+	"github.com/fgrehm/kot/pkg/deps"
+	wkdeps "github.com/fgrehm/kot/pkg/deps/wellknown"
+	"github.com/fgrehm/kot/pkg/kotclient"
+	"github.com/fgrehm/kot/pkg/kottesting/gomock"
+	"github.com/fgrehm/kot/pkg/reconcile"
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var _ = Describe("WaitForChildrenFinalizer", func() {
+	var (
+		ctx   action.Context
+		mCtrl *gomock.Controller
+
+		client    *kotmocks.MockClient
+		finalizer *reconcile.WaitForChildrenFinalizer
+	)
+
+	BeforeEach(func() {
+		mCtrl = gomock.NewController(GinkgoT())
+
+		mockedEnv := kotmocks.NewEnv(mCtrl, GinkgoWriter)
+		client = mockedEnv.Client
+		wkdeps.SetClient(client)
+		wkdeps.SetScheme(mockedEnv.Scheme)
+
+		finalizer = &reconcile.WaitForChildrenFinalizer{
+			GVKs: []kotclient.GVK{
+				corev1.SchemeGroupVersion.WithKind("Namespace"),
+				corev1.SchemeGroupVersion.WithKind("ConfigMap"),
+			},
+		}
+		deps.Inject(deps.Build(), finalizer)
+		ctx = action.NewBackgroundContext().WithResource(&corev1.ServiceAccount{})
+	})
+
+	AfterEach(func() {
+		mCtrl.Finish()
+		deps.Clear()
+	})
+
+	It("is named and enabled when GVKs are set", func() {
+		Expect(finalizer.FinalizerName()).To(Equal("kot.io/wait-for-children"))
+		Expect(finalizer.Enabled(ctx)).To(BeTrue())
+
+		Expect((&reconcile.WaitForChildrenFinalizer{}).Enabled(ctx)).To(BeFalse())
+	})
+
+	It("deletes children of the first GVK that has any and waits for them", func() {
+		now := metav1.Now()
+		client.EXPECT().List(gomock.Any(), gomock.AssignableToTypeOf(&corev1.NamespaceList{}), gomock.Any()).
+			SetArg(1, corev1.NamespaceList{Items: []corev1.Namespace{
+				{ObjectMeta: metav1.ObjectMeta{Name: "deleting", DeletionTimestamp: &now}},
+				{ObjectMeta: metav1.ObjectMeta{Name: "child"}},
+			}})
+		client.EXPECT().Delete(gomock.Any(), gomock.Any(), runtimeclient.PropagationPolicy(metav1.DeletePropagationForeground)).
+			Do(func(_ interface{}, obj runtimeclient.Object, _ ...interface{}) error {
+				Expect(obj.GetName()).To(Equal("child"))
+				return nil
+			})
+
+		finalized, res, err := finalizer.Finalize(ctx)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(finalized).To(BeFalse())
+		Expect(res).To(Equal(action.Result{RequeueAfter: reconcile.DefaultWaitForChildrenRequeue}))
+	})
+
+	It("moves on to the next GVK once children are gone", func() {
+		finalizer.PropagationPolicy = metav1.DeletePropagationBackground
+		finalizer.RequeueAfter = time.Second
+
+		client.EXPECT().List(gomock.Any(), gomock.AssignableToTypeOf(&corev1.NamespaceList{}), gomock.Any())
+		client.EXPECT().List(gomock.Any(), gomock.AssignableToTypeOf(&corev1.ConfigMapList{}), gomock.Any()).
+			SetArg(1, corev1.ConfigMapList{Items: []corev1.ConfigMap{{ObjectMeta: metav1.ObjectMeta{Name: "child"}}}})
+		client.EXPECT().Delete(gomock.Any(), gomock.Any(), runtimeclient.PropagationPolicy(metav1.DeletePropagationBackground))
+
+		finalized, res, err := finalizer.Finalize(ctx)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(finalized).To(BeFalse())
+		Expect(res).To(Equal(action.Result{RequeueAfter: time.Second}))
+	})
+
+	It("is done when all children are gone", func() {
+		client.EXPECT().List(gomock.Any(), gomock.Any(), gomock.Any()).Times(2)
+
+		finalized, res, err := finalizer.Finalize(ctx)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(finalized).To(BeTrue())
+		Expect(res).To(Equal(action.Result{}))
+	})
+})
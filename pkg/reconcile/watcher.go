@@ -1,28 +1,59 @@
 package reconcile
 
 import (
+	"context"
+	"time"
+
 	"github.com/fgrehm/kot/pkg/deps"
+	wkdeps "github.com/fgrehm/kot/pkg/deps/wellknown"
+	"github.com/fgrehm/kot/pkg/kotclient"
+	"github.com/fgrehm/kot/pkg/metrics"
+	"github.com/go-logr/logr"
 	"github.com/pkg/errors"
+	"k8s.io/client-go/util/workqueue"
 	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
 	runtimehandler "sigs.k8s.io/controller-runtime/pkg/handler"
+	ctrllog "sigs.k8s.io/controller-runtime/pkg/log"
 	runtimepredicate "sigs.k8s.io/controller-runtime/pkg/predicate"
 	runtimereconcile "sigs.k8s.io/controller-runtime/pkg/reconcile"
 	runtimesource "sigs.k8s.io/controller-runtime/pkg/source"
 )
 
+// DefaultEnqueueTimeout bounds calls of enqueue functions when watchers don't
+// set a timeout.
+const DefaultEnqueueTimeout = 30 * time.Second
+
+// enqueueRetryDelay is how long the first retry of a failed enqueue function
+// waits, it doubles on each retry.
+var enqueueRetryDelay = 100 * time.Millisecond
+
 type Watcher interface {
 	Source() runtimesource.Source
 	Handler() runtimehandler.EventHandler
 	Predicate() runtimepredicate.Predicate
 }
 
+// ControllerWatcher is implemented by watchers that label their metrics with
+// the name of the controller they are registered on.
+type ControllerWatcher interface {
+	Watcher
+	WatchForController(name string)
+}
+
 type WatcherConfig interface {
 	Validate() (bool, error)
 }
 
 type ResourceWatcher struct {
 	*ResourceWatcherConfig
-	ctn deps.Container
+	ctn        deps.Container
+	log        logr.Logger
+	watchedGVK kotclient.GVK
+	parentGVK  kotclient.GVK
+	controller string
+	injectErr  error
 }
 
 func (w *ResourceWatcher) Source() runtimesource.Source {
@@ -30,13 +61,7 @@ func (w *ResourceWatcher) Source() runtimesource.Source {
 }
 
 func (w *ResourceWatcher) Handler() runtimehandler.EventHandler {
-	return runtimehandler.EnqueueRequestsFromMapFunc(func(obj runtimeclient.Object) []runtimereconcile.Request {
-		reqs, err := w.Enqueue(w.ctn, obj)
-		if err != nil {
-			return []runtimereconcile.Request{}
-		}
-		return reqs
-	})
+	return &enqueueHandler{watcher: w}
 }
 
 func (w *ResourceWatcher) Predicate() runtimepredicate.Predicate {
@@ -45,17 +70,174 @@ func (w *ResourceWatcher) Predicate() runtimepredicate.Predicate {
 
 func (w *ResourceWatcher) InjectDeps(ctn deps.Container) {
 	w.ctn = ctn
-	// TODO: Inject logger too
+	w.log = ctrllog.Log.WithName("watcher")
+
+	gvk, err := apiutil.GVKForObject(w.Watches, wkdeps.Scheme(ctn))
+	if err != nil {
+		w.injectErr = errors.Wrap(err, "failed to find GVK of watched objects")
+		return
+	}
+	w.injectErr = nil
+	w.watchedGVK = gvk
+	w.log = w.log.WithValues("watched-gvk", gvk.String())
+}
+
+func (w *ResourceWatcher) InjectionErr() error {
+	return w.injectErr
+}
+
+func (w *ResourceWatcher) WatchParents(gvk kotclient.GVK) {
+	w.parentGVK = gvk
+}
+
+func (w *ResourceWatcher) WatchForController(name string) {
+	w.controller = name
+}
+
+// enqueue maps an object with the enqueue function of the watcher and adds
+// the resulting requests to the queue. Failed calls are retried in the
+// background so that informers are not blocked, falling back to enqueuing all
+// parents once retries run out if the watcher is set to.
+func (w *ResourceWatcher) enqueue(queue workqueue.RateLimitingInterface, obj runtimeclient.Object) {
+	w.enqueueAttempt(queue, obj, 0, time.Now().Add(w.timeout()))
 }
 
-var _ Watcher = &ResourceWatcher{}
+func (w *ResourceWatcher) enqueueAttempt(queue workqueue.RateLimitingInterface, obj runtimeclient.Object, attempt int, deadline time.Time) {
+	log := w.log.WithValues("namespace", obj.GetNamespace(), "name", obj.GetName())
+	ctx, cancel := context.WithDeadline(w.context(log), deadline)
+	defer cancel()
+
+	reqs, err := w.Enqueue(ctx, w.ctn, obj)
+	if err == nil {
+		addRequests(queue, reqs)
+		return
+	}
+
+	delay := enqueueRetryDelay << attempt
+	if attempt < w.Retries && time.Now().Add(delay).Before(deadline) {
+		log.V(1).Info("retrying enqueue", "error", err.Error(), "attempt", attempt+1)
+		time.AfterFunc(delay, func() {
+			if !queue.ShuttingDown() {
+				w.enqueueAttempt(queue, obj, attempt+1, deadline)
+			}
+		})
+		return
+	}
+
+	metrics.ObserveEnqueueError(ctx, w.gvkOf(obj))
+	if !w.EnqueueAllOnError {
+		log.Error(err, "failed to map watched resource, dropping event")
+		return
+	}
+
+	log.Error(err, "failed to map watched resource, enqueuing all resources")
+	ctx, cancel = context.WithTimeout(w.context(log), w.timeout())
+	defer cancel()
+	if reqs, err = w.enqueueAll(ctx); err != nil {
+		log.Error(err, "failed to enqueue all resources")
+		return
+	}
+	addRequests(queue, reqs)
+}
+
+// context carries the logger of an enqueue attempt and the controller name
+// used to label metrics.
+func (w *ResourceWatcher) context(log logr.Logger) context.Context {
+	return metrics.WithController(ctrllog.IntoContext(context.Background(), log), w.controller)
+}
+
+// gvkOf falls back to the GVK of the object for watchers that map objects of
+// any type, like channel watchers.
+func (w *ResourceWatcher) gvkOf(obj runtimeclient.Object) kotclient.GVK {
+	if !w.watchedGVK.Empty() {
+		return w.watchedGVK
+	}
+	gvk, _ := apiutil.GVKForObject(obj, wkdeps.Scheme(w.ctn))
+	return gvk
+}
+
+func (w *ResourceWatcher) timeout() time.Duration {
+	if w.Timeout == 0 {
+		return DefaultEnqueueTimeout
+	}
+	return w.Timeout
+}
+
+func addRequests(queue workqueue.RateLimitingInterface, reqs []runtimereconcile.Request) {
+	for _, req := range reqs {
+		queue.Add(req)
+	}
+}
+
+// enqueueAll lists every resource reconciled by the controller.
+func (w *ResourceWatcher) enqueueAll(ctx context.Context) ([]runtimereconcile.Request, error) {
+	if w.parentGVK.Empty() {
+		return nil, errors.New("watcher does not know which resources to enqueue")
+	}
+	parents, err := kotclient.NewObjectList(wkdeps.Scheme(w.ctn), w.parentGVK)
+	if err != nil {
+		return nil, err
+	}
+	if err := wkdeps.Client(w.ctn).List(ctx, parents); err != nil {
+		return nil, errors.Wrap(err, "failed to list resources")
+	}
+	objs, err := kotclient.ExtractList(parents)
+	if err != nil {
+		return nil, err
+	}
+
+	reqs := make([]runtimereconcile.Request, len(objs))
+	for i, parent := range objs {
+		reqs[i].NamespacedName = runtimeclient.ObjectKeyFromObject(parent)
+	}
+	return reqs, nil
+}
+
+// enqueueHandler enqueues the requests a watcher maps events to.
+type enqueueHandler struct {
+	watcher *ResourceWatcher
+}
+
+var _ runtimehandler.EventHandler = &enqueueHandler{}
+
+func (h *enqueueHandler) Create(evt event.CreateEvent, queue workqueue.RateLimitingInterface) {
+	h.watcher.enqueue(queue, evt.Object)
+}
+
+func (h *enqueueHandler) Update(evt event.UpdateEvent, queue workqueue.RateLimitingInterface) {
+	h.watcher.enqueue(queue, evt.ObjectOld)
+	h.watcher.enqueue(queue, evt.ObjectNew)
+}
+
+func (h *enqueueHandler) Delete(evt event.DeleteEvent, queue workqueue.RateLimitingInterface) {
+	h.watcher.enqueue(queue, evt.Object)
+}
+
+func (h *enqueueHandler) Generic(evt event.GenericEvent, queue workqueue.RateLimitingInterface) {
+	h.watcher.enqueue(queue, evt.Object)
+}
+
+var _ ParentWatcher = &ResourceWatcher{}
+var _ ControllerWatcher = &ResourceWatcher{}
+var _ InjectionValidator = &ResourceWatcher{}
 var _ WatcherConfig = &ResourceWatcherConfig{}
 var _ deps.DepsInjector = &ResourceWatcher{}
 
 type ResourceWatcherConfig struct {
 	Watches runtimeclient.Object
 	When    runtimepredicate.Predicate
-	Enqueue func(deps deps.Container, obj runtimeclient.Object) ([]runtimereconcile.Request, error)
+	// Enqueue maps watched objects to the requests to enqueue, ctx has a
+	// deadline and carries a logger scoped to the watched object.
+	Enqueue func(ctx context.Context, deps deps.Container, obj runtimeclient.Object) ([]runtimereconcile.Request, error)
+	// Timeout bounds calls of Enqueue, retries included, and defaults to
+	// DefaultEnqueueTimeout.
+	Timeout time.Duration
+	// Retries is how many more times Enqueue is called when it fails, retries
+	// run in the background after an exponentially growing delay.
+	Retries int
+	// EnqueueAllOnError enqueues every resource reconciled by the controller
+	// when Enqueue fails, so that a transient error doesn't lose a reconcile.
+	EnqueueAllOnError bool
 }
 
 func (c *ResourceWatcherConfig) Validate() (bool, error) {
@@ -68,6 +250,12 @@ func (c *ResourceWatcherConfig) Validate() (bool, error) {
 	if c.Enqueue == nil {
 		return false, errors.New("enqueuer is not set")
 	}
+	if c.Timeout < 0 {
+		return false, errors.New("timeout can't be negative")
+	}
+	if c.Retries < 0 {
+		return false, errors.New("retries can't be negative")
+	}
 
 	return true, nil
 }
@@ -1,62 +1,170 @@
 package reconcile_test
 
 import (
-	// "errors"
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
 
 	"github.com/fgrehm/kot/pkg/deps"
+	wkdeps "github.com/fgrehm/kot/pkg/deps/wellknown"
+	"github.com/fgrehm/kot/pkg/kottesting/gomock"
+	"github.com/fgrehm/kot/pkg/metrics"
 	"github.com/fgrehm/kot/pkg/reconcile"
+	"github.com/go-logr/logr"
 	"github.com/golang/mock/gomock"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/workqueue"
 	ctrl "sigs.k8s.io/controller-runtime"
 	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
 	runtimeevent "sigs.k8s.io/controller-runtime/pkg/event"
+	runtimepredicate "sigs.k8s.io/controller-runtime/pkg/predicate"
 	runtimesource "sigs.k8s.io/controller-runtime/pkg/source"
 )
 
 var _ = Describe("Watcher", func() {
 	var (
-		mCtrl *gomock.Controller
+		mCtrl  *gomock.Controller
+		client *kotmocks.MockClient
+		ctn    deps.Container
 	)
 
 	BeforeEach(func() {
 		mCtrl = gomock.NewController(GinkgoT())
+		mockedEnv := kotmocks.NewEnv(mCtrl, GinkgoWriter)
+		client = mockedEnv.Client
+		wkdeps.SetClient(client)
+		wkdeps.SetScheme(mockedEnv.Scheme)
+		ctn = deps.Build()
 	})
 
 	AfterEach(func() {
 		mCtrl.Finish()
+		deps.Clear()
 	})
 
 	Describe("ResourceWatcher", func() {
 		var (
 			watcher *reconcile.ResourceWatcher
+			queue   workqueue.RateLimitingInterface
+			pod     *corev1.Pod
 		)
 
 		BeforeEach(func() {
-			watcher = &reconcile.ResourceWatcher{ResourceWatcherConfig: &reconcile.ResourceWatcherConfig{}}
+			watcher = &reconcile.ResourceWatcher{ResourceWatcherConfig: &reconcile.ResourceWatcherConfig{
+				Watches: &corev1.Pod{},
+				When:    runtimepredicate.ResourceVersionChangedPredicate{},
+			}}
+			queue = workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter())
+			pod = &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "pod"}}
+		})
+
+		AfterEach(func() {
+			queue.ShutDown()
 		})
 
 		Describe("Source", func() {
 			It("builds a source.Kind for the given type", func() {
-				watcher.Watches = &corev1.Pod{}
 				Expect(watcher.Source()).To(Equal(&runtimesource.Kind{Type: &corev1.Pod{}}))
 			})
 		})
 
 		Describe("Handler", func() {
-			It("calls the enqueue function", func() {
-				called := false
-				watcher.Enqueue = func(ctn deps.Container, obj runtimeclient.Object) ([]ctrl.Request, error) {
-					called = true
-					return []ctrl.Request{}, nil
+			It("calls the enqueue function with a deadline and a logger", func() {
+				watcher.Enqueue = func(ctx context.Context, ctn deps.Container, obj runtimeclient.Object) ([]ctrl.Request, error) {
+					_, hasDeadline := ctx.Deadline()
+					Expect(hasDeadline).To(BeTrue())
+					_, err := logr.FromContext(ctx)
+					Expect(err).NotTo(HaveOccurred())
+					return []ctrl.Request{{NamespacedName: runtimeclient.ObjectKeyFromObject(obj)}}, nil
+				}
+				deps.Inject(ctn, watcher)
+
+				watcher.Handler().Create(runtimeevent.CreateEvent{Object: pod}, queue)
+				Expect(queue.Len()).To(Equal(1))
+			})
+
+			It("counts errors and drops the event", func() {
+				watcher.Enqueue = func(context.Context, deps.Container, runtimeclient.Object) ([]ctrl.Request, error) {
+					return nil, errors.New("boom")
 				}
+				watcher.WatchForController("test")
+				deps.Inject(ctn, watcher)
+				errs := metrics.WatcherEnqueueErrors.WithLabelValues("test", "", "v1", "Pod")
+				before := testutil.ToFloat64(errs)
+
+				watcher.Handler().Create(runtimeevent.CreateEvent{Object: pod}, queue)
+				Expect(queue.Len()).To(Equal(0))
+				Expect(testutil.ToFloat64(errs)).To(Equal(before + 1))
+			})
 
-				ctn := deps.Build()
+			It("retries failed calls without blocking the handler", func() {
+				var calls int32
+				watcher.Retries = 2
+				watcher.Enqueue = func(context.Context, deps.Container, runtimeclient.Object) ([]ctrl.Request, error) {
+					if atomic.AddInt32(&calls, 1) < 3 {
+						return nil, errors.New("boom")
+					}
+					return []ctrl.Request{{}}, nil
+				}
 				deps.Inject(ctn, watcher)
 
-				watcher.Handler().Create(runtimeevent.CreateEvent{}, nil)
-				Expect(called).To(BeTrue())
+				watcher.Handler().Create(runtimeevent.CreateEvent{Object: pod}, queue)
+				Expect(atomic.LoadInt32(&calls)).To(Equal(int32(1)))
+				Expect(queue.Len()).To(Equal(0))
+
+				Eventually(queue.Len).Should(Equal(1))
+				Expect(atomic.LoadInt32(&calls)).To(Equal(int32(3)))
+			})
+
+			It("stops retrying once the timeout is reached", func() {
+				calls := 0
+				watcher.Retries = 5
+				watcher.Timeout = 50 * time.Millisecond
+				watcher.Enqueue = func(context.Context, deps.Container, runtimeclient.Object) ([]ctrl.Request, error) {
+					calls++
+					return nil, errors.New("boom")
+				}
+				deps.Inject(ctn, watcher)
+
+				watcher.Handler().Create(runtimeevent.CreateEvent{Object: pod}, queue)
+				Consistently(func() int { return calls }, 200*time.Millisecond).Should(Equal(1))
+			})
+
+			It("enqueues all parents when set to and the enqueue function fails", func() {
+				watcher.EnqueueAllOnError = true
+				watcher.Enqueue = func(context.Context, deps.Container, runtimeclient.Object) ([]ctrl.Request, error) {
+					return nil, errors.New("boom")
+				}
+				watcher.WatchParents(corev1.SchemeGroupVersion.WithKind("Namespace"))
+				deps.Inject(ctn, watcher)
+				client.EXPECT().List(gomock.Any(), gomock.Any()).SetArg(1, corev1.NamespaceList{Items: []corev1.Namespace{
+					{ObjectMeta: metav1.ObjectMeta{Name: "a"}},
+					{ObjectMeta: metav1.ObjectMeta{Name: "b"}},
+				}})
+
+				watcher.Handler().Create(runtimeevent.CreateEvent{Object: pod}, queue)
+				Expect(queue.Len()).To(Equal(2))
+			})
+		})
+
+		Describe("Validate", func() {
+			It("rejects negative timeouts and retries", func() {
+				watcher.Enqueue = func(context.Context, deps.Container, runtimeclient.Object) ([]ctrl.Request, error) {
+					return nil, nil
+				}
+				watcher.Retries = -1
+				_, err := reconcile.CreateWatcher(watcher.ResourceWatcherConfig)
+				Expect(err).To(MatchError("retries can't be negative"))
+
+				watcher.Retries = 0
+				watcher.Timeout = -time.Second
+				_, err = reconcile.CreateWatcher(watcher.ResourceWatcherConfig)
+				Expect(err).To(MatchError("timeout can't be negative"))
 			})
 		})
 	})
@@ -21,14 +21,15 @@ func Run(cfg Config) {
 	wkdeps.SetManager(cfg.Manager)
 	ctn := deps.Build()
 
+	// Controllers are completed first so that GVKs derived from the scheme are
+	// known when indexing
 	idxCtrls := []indexing.Controller{}
+	indexers := append([]indexing.Indexer{}, cfg.Indexers...)
 	for _, c := range cfg.Controllers {
+		c.MustComplete(ctn)
 		idxCtrls = append(idxCtrls, c)
+		indexers = append(indexers, c.Indexers()...)
 	}
 	indexing.MustIndexControllers(cfg.Ctx, cfg.Manager, idxCtrls...)
-	indexing.MustIndexAll(cfg.Ctx, cfg.Manager, cfg.Indexers...)
-
-	for _, c := range cfg.Controllers {
-		c.MustComplete(ctn)
-	}
+	indexing.MustIndexAll(cfg.Ctx, cfg.Manager, indexers...)
 }